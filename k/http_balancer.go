@@ -0,0 +1,259 @@
+package k
+
+// http_balancer.go —— 客户端负载均衡：多个 baseURL（副本 / 多区域）之间分发请求
+//
+// 设计目标：
+//   - 策略：轮询 / 随机 / 最少进行中请求 / 按请求 key 一致性哈希
+//   - 每个端点一个独立熔断器，连续失败或失败率超标的端点自动摘除，冷却后半开探测恢复
+//   - 重试时故障转移：同一请求的后续尝试优先选择尚未尝试过的端点
+//   - 缓存、指标、日志使用第一个端点拼接的 URL，与实际发往的端点无关
+//
+// 示例：
+//
+//	lb := NewLoadBalancer([]string{
+//	    "https://cn-east.api.example.com/v1",
+//	    "https://cn-north.api.example.com/v1",
+//	}, BalanceLeastInflight, nil)
+//	client, _ := NewClient("").LoadBalancer(lb).Retry(WithMaxRetries(2)).Build()
+//	client.Get("/users/42", R().HashKey("42")) // 一致性哈希策略下按 key 选择端点
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type BalanceStrategy string
+
+const (
+	BalanceRoundRobin     BalanceStrategy = "round_robin"     // 轮询
+	BalanceRandom         BalanceStrategy = "random"          // 随机
+	BalanceLeastInflight  BalanceStrategy = "least_inflight"  // 选取进行中请求最少的端点
+	BalanceConsistentHash BalanceStrategy = "consistent_hash" // 按 R().HashKey 一致性哈希，未设置时使用请求路径
+)
+
+// ErrNoHealthyEndpoint 所有端点的熔断器均处于开启状态
+var ErrNoHealthyEndpoint = errors.New("load balancer: no healthy endpoint")
+
+// hashReplicas 一致性哈希环上每个端点的虚拟节点数
+const hashReplicas = 100
+
+// endpoint 负载均衡中的一个后端
+type endpoint struct {
+	base      string
+	breaker   *CircuitBreaker
+	inflight  atomic.Int64
+	successes atomic.Int64
+	failures  atomic.Int64
+}
+
+// resolve 将相对路径拼接到端点地址，保留调用方已编码的 query
+func (e *endpoint) resolve(path, rawQuery string) (*url.URL, error) {
+	raw := e.base
+	if path != "" {
+		raw += "/" + strings.TrimLeft(path, "/")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = rawQuery
+	return u, nil
+}
+
+// EndpointStats 单个端点的统计快照
+type EndpointStats struct {
+	URL       string
+	State     string // 熔断器状态："closed"、"open"、"half-open"
+	Inflight  int64  // 进行中请求数
+	Successes int64
+	Failures  int64 // 网络错误或 5xx 次数
+}
+
+// hashNode 一致性哈希环上的虚拟节点
+type hashNode struct {
+	hash uint32
+	ep   *endpoint
+}
+
+// LoadBalancer 多端点负载均衡器（并发安全），通过 NewLoadBalancer 创建后注入 ClientBuilder.LoadBalancer。
+type LoadBalancer struct {
+	strategy  BalanceStrategy
+	endpoints []*endpoint
+	ring      []hashNode
+	cursor    atomic.Uint64 // 轮询游标
+	invalid   error         // 构造时解析失败的端点，Build 时返回
+}
+
+// NewLoadBalancer 创建负载均衡器。
+//
+// 参数：
+//   - urls:     端点地址列表，与 NewClient 的 baseURL 格式相同，例如 "https://a.example.com/v1"
+//   - strategy: 端点选取策略
+//   - factory:  为每个端点创建熔断器，为 nil 时使用 NewCircuitBreaker；
+//     可设置 OnStateChange 监控端点摘除与恢复
+func NewLoadBalancer(urls []string, strategy BalanceStrategy, factory func() *CircuitBreaker) *LoadBalancer {
+	if factory == nil {
+		factory = NewCircuitBreaker
+	}
+	lb := &LoadBalancer{strategy: strategy}
+	for _, raw := range urls {
+		base := strings.TrimRight(raw, "/")
+		if u, err := url.ParseRequestURI(base); err != nil || u.Host == "" {
+			if lb.invalid == nil {
+				lb.invalid = fmt.Errorf("invalid endpoint %q", raw)
+			}
+			continue
+		}
+		ep := &endpoint{base: base, breaker: factory()}
+		lb.endpoints = append(lb.endpoints, ep)
+		for i := 0; i < hashReplicas; i++ {
+			lb.ring = append(lb.ring, hashNode{hash: crc32.ChecksumIEEE([]byte(base + "#" + strconv.Itoa(i))), ep: ep})
+		}
+	}
+	sort.Slice(lb.ring, func(i, j int) bool { return lb.ring[i].hash < lb.ring[j].hash })
+	return lb
+}
+
+// Stats 返回每个端点的统计快照，顺序与创建时一致
+func (lb *LoadBalancer) Stats() []EndpointStats {
+	stats := make([]EndpointStats, len(lb.endpoints))
+	for i, ep := range lb.endpoints {
+		stats[i] = EndpointStats{
+			URL:       ep.base,
+			State:     ep.breaker.State(),
+			Inflight:  ep.inflight.Load(),
+			Successes: ep.successes.Load(),
+			Failures:  ep.failures.Load(),
+		}
+	}
+	return stats
+}
+
+// validate 在 Build 时校验配置
+func (lb *LoadBalancer) validate() error {
+	if lb.invalid != nil {
+		return lb.invalid
+	}
+	if len(lb.endpoints) == 0 {
+		return errors.New("load balancer has no endpoints")
+	}
+	return nil
+}
+
+// candidates 按策略返回端点的候选顺序
+func (lb *LoadBalancer) candidates(key string) []*endpoint {
+	n := len(lb.endpoints)
+	out := make([]*endpoint, 0, n)
+	switch lb.strategy {
+	case BalanceConsistentHash:
+		// 从 key 的位置顺时针遍历哈希环，依次收集不同端点，故障转移时落到环上的下一个端点
+		h := crc32.ChecksumIEEE([]byte(key))
+		start := sort.Search(len(lb.ring), func(i int) bool { return lb.ring[i].hash >= h })
+		seen := make(map[*endpoint]bool, n)
+		for i := 0; i < len(lb.ring) && len(out) < n; i++ {
+			ep := lb.ring[(start+i)%len(lb.ring)].ep
+			if !seen[ep] {
+				seen[ep] = true
+				out = append(out, ep)
+			}
+		}
+		return out
+	case BalanceRandom:
+		for _, i := range rand.Perm(n) {
+			out = append(out, lb.endpoints[i])
+		}
+		return out
+	}
+
+	// 轮询与最少进行中请求均从游标位置开始，后者再按进行中请求数稳定排序，数量相同时仍为轮询
+	start := int(lb.cursor.Add(1) - 1)
+	for i := 0; i < n; i++ {
+		out = append(out, lb.endpoints[(start+i)%n])
+	}
+	if lb.strategy == BalanceLeastInflight {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].inflight.Load() < out[j].inflight.Load() })
+	}
+	return out
+}
+
+// pick 选取一个熔断器放行的端点，优先选择本次请求尚未尝试过的端点
+func (lb *LoadBalancer) pick(key string, tried map[*endpoint]bool) (*endpoint, error) {
+	cands := lb.candidates(key)
+	for _, retry := range []bool{false, true} {
+		for _, ep := range cands {
+			if tried[ep] == retry && ep.breaker.Allow() {
+				return ep, nil
+			}
+		}
+	}
+	return nil, ErrNoHealthyEndpoint
+}
+
+// done 记录一次发往端点的结果
+func (lb *LoadBalancer) done(ep *endpoint, req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	if err != nil && req.Context().Err() != nil {
+		lb.release(ep) // 调用方取消，不归咎于端点
+		return
+	}
+	ep.inflight.Add(-1)
+	failed := err != nil || resp.StatusCode >= 500
+	if failed {
+		ep.failures.Add(1)
+	} else {
+		ep.successes.Add(1)
+	}
+	ep.breaker.record(failed, elapsed)
+}
+
+// release 请求未发出（或被调用方取消）时归还端点的进行中计数与半开探测名额
+func (lb *LoadBalancer) release(ep *endpoint) {
+	ep.inflight.Add(-1)
+	ep.breaker.release()
+}
+
+// LoadBalancer 注入多端点负载均衡器，请求路径拼接到所选端点之后。
+// 使用时 NewClient 的 baseURL 须为空；配置重试后，失败的尝试会转移到其他端点。
+//
+// 参数：
+//   - lb: 通过 NewLoadBalancer 创建
+func (b *ClientBuilder) LoadBalancer(lb *LoadBalancer) *ClientBuilder {
+	b.balancer = lb
+	return b
+}
+
+// HashKey 设置一致性哈希策略使用的请求 key（例如用户 ID），相同 key 的请求落到同一端点
+func (r *RequestBuilder) HashKey(key string) *RequestBuilder {
+	r.cfg.hashKey = key
+	return r
+}
+
+// balancedRequest 为本次尝试选取端点，返回指向该端点的请求副本（共享 header 与 body）
+func (c *HTTPClient) balancedRequest(req *http.Request, cfg *requestConfig, tried map[*endpoint]bool) (*http.Request, *endpoint, error) {
+	key := cfg.hashKey
+	if key == "" {
+		key = cfg.path
+	}
+	ep, err := c.builder.balancer.pick(key, tried)
+	if err != nil {
+		return nil, nil, err
+	}
+	ep.inflight.Add(1)
+	u, err := ep.resolve(cfg.path, req.URL.RawQuery)
+	if err != nil {
+		c.builder.balancer.release(ep)
+		return nil, nil, err
+	}
+	tried[ep] = true
+	r := req.WithContext(req.Context())
+	r.URL, r.Host = u, ""
+	return r, ep, nil
+}
@@ -0,0 +1,246 @@
+package k
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newEchoServer 返回响应体为 name + 请求 URI 的测试服务器
+func newEchoServer(name string, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, name+" "+r.URL.RequestURI())
+	}))
+}
+
+func readBody(t *testing.T, resp *http.Response, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+// 测试轮询分发，端点路径前缀与 query 保留
+func TestLoadBalancer_RoundRobin(t *testing.T) {
+	var ha, hb atomic.Int32
+	a, b := newEchoServer("a", &ha), newEchoServer("b", &hb)
+	defer a.Close()
+	defer b.Close()
+
+	lb := NewLoadBalancer([]string{a.URL + "/v1", b.URL + "/v1/"}, BalanceRoundRobin, nil)
+	client, err := NewClient("").LoadBalancer(lb).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("/users?x=1", R().QueryParams(map[string]string{"y": "2"}))
+		bodies = append(bodies, readBody(t, resp, err))
+	}
+	if ha.Load() != 2 || hb.Load() != 2 {
+		t.Errorf("hits a=%d b=%d", ha.Load(), hb.Load())
+	}
+	if !strings.HasSuffix(bodies[0], " /v1/users?x=1&y=2") {
+		t.Errorf("body = %q", bodies[0])
+	}
+}
+
+// 测试一致性哈希：相同 key 落到同一端点
+func TestLoadBalancer_ConsistentHash(t *testing.T) {
+	var hits [3]atomic.Int32
+	var urls []string
+	for i := range hits {
+		s := newEchoServer(fmt.Sprint(i), &hits[i])
+		defer s.Close()
+		urls = append(urls, s.URL)
+	}
+	client, _ := NewClient("").LoadBalancer(NewLoadBalancer(urls, BalanceConsistentHash, nil)).Build()
+
+	owner := map[string]string{}
+	for round := 0; round < 3; round++ {
+		for u := 0; u < 20; u++ {
+			key := fmt.Sprint("user-", u)
+			resp, err := client.Get("/profile", R().HashKey(key))
+			name := strings.Fields(readBody(t, resp, err))[0]
+			if prev, ok := owner[key]; ok && prev != name {
+				t.Fatalf("key %s moved from %s to %s", key, prev, name)
+			}
+			owner[key] = name
+		}
+	}
+	used := map[string]bool{}
+	for _, v := range owner {
+		used[v] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("keys not spread across endpoints: %v", owner)
+	}
+}
+
+// 测试失败端点被摘除，重试时转移到其他端点
+func TestLoadBalancer_FailoverAndEject(t *testing.T) {
+	var badHits, goodHits atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badHits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	good := newEchoServer("good", &goodHits)
+	defer good.Close()
+
+	var mu sync.Mutex
+	var transitions []string
+	lb := NewLoadBalancer([]string{bad.URL, good.URL}, BalanceRoundRobin, func() *CircuitBreaker {
+		cb := NewCircuitBreaker()
+		cb.MaxFailures = 2
+		cb.OpenTimeout = time.Minute
+		cb.OnStateChange = func(from, to string) {
+			mu.Lock()
+			transitions = append(transitions, from+"->"+to)
+			mu.Unlock()
+		}
+		return cb
+	})
+	client, _ := NewClient("").LoadBalancer(lb).Retry(WithMaxRetries(2), WithRetryDelay(time.Millisecond)).Build()
+
+	for i := 0; i < 10; i++ {
+		resp, err := client.Get("/")
+		if body := readBody(t, resp, err); !strings.HasPrefix(body, "good") {
+			t.Fatalf("request %d: body = %q", i, body)
+		}
+	}
+	if n := badHits.Load(); n != 2 {
+		t.Errorf("bad endpoint hits = %d, want 2 before ejection", n)
+	}
+	stats := lb.Stats()
+	if stats[0].State != "open" || stats[0].Failures != 2 || stats[1].Successes != 10 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(transitions) != 1 || transitions[0] != "closed->open" {
+		t.Errorf("transitions = %v", transitions)
+	}
+}
+
+// 测试 BreakerGroup 与指标按每次尝试所选端点的 host 区分
+func TestLoadBalancer_PerEndpointBreakerAndMetrics(t *testing.T) {
+	var badHits, goodHits atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+	good := newEchoServer("good", &goodHits)
+	defer good.Close()
+
+	group := NewBreakerGroup(BreakerPerHost, func() *CircuitBreaker {
+		cb := NewCircuitBreaker()
+		cb.MaxFailures = 1
+		cb.OpenTimeout = time.Minute
+		return cb
+	})
+	mc := NewMetricsCollector()
+	lb := NewLoadBalancer([]string{bad.URL, good.URL}, BalanceRoundRobin, nil)
+	client, _ := NewClient("").LoadBalancer(lb).BreakerGroup(group).MetricsCollector(mc).
+		Retry(WithMaxRetries(2), WithRetryDelay(time.Millisecond)).Build()
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("/", R().Route("/"))
+		if body := readBody(t, resp, err); !strings.HasPrefix(body, "good") {
+			t.Fatalf("request %d: body = %q", i, body)
+		}
+	}
+	if n := badHits.Load(); n != 1 {
+		t.Errorf("bad endpoint hits = %d, want 1 before its breaker opens", n)
+	}
+	badHost, goodHost := strings.TrimPrefix(bad.URL, "http://"), strings.TrimPrefix(good.URL, "http://")
+	if states := group.States(); states[badHost] != "open" || states[goodHost] != "closed" {
+		t.Errorf("states = %v", states)
+	}
+
+	var out strings.Builder
+	mc.WritePrometheus(&out)
+	wants := []string{
+		`http_client_requests_total{method="GET",host="` + goodHost + `",route="/",code="200"} 4`,
+		`http_client_in_flight_requests{host="` + badHost + `"} 0`,
+		`http_client_in_flight_requests{host="` + goodHost + `"} 0`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics output missing %q\n%s", want, out.String())
+		}
+	}
+}
+
+// 测试全部端点熔断时返回 ErrNoHealthyEndpoint
+func TestLoadBalancer_NoHealthyEndpoint(t *testing.T) {
+	var hits atomic.Int32
+	s := newEchoServer("a", &hits)
+	defer s.Close()
+	lb := NewLoadBalancer([]string{s.URL}, BalanceRandom, nil)
+	lb.endpoints[0].breaker.MaxFailures = 1
+	lb.endpoints[0].breaker.RecordFailure()
+
+	client, _ := NewClient("").LoadBalancer(lb).Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).Build()
+	if _, err := client.Get("/"); !errors.Is(err, ErrNoHealthyEndpoint) {
+		t.Fatalf("err = %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("hits = %d", hits.Load())
+	}
+}
+
+// 测试最少进行中请求：慢端点上有请求时新请求发往空闲端点
+func TestLoadBalancer_LeastInflight(t *testing.T) {
+	release := make(chan struct{})
+	var slowHits, fastHits atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowHits.Add(1)
+		<-release
+	}))
+	defer slow.Close()
+	fast := newEchoServer("fast", &fastHits)
+	defer fast.Close()
+
+	client, _ := NewClient("").LoadBalancer(NewLoadBalancer([]string{slow.URL, fast.URL}, BalanceLeastInflight, nil)).Build()
+	done := make(chan struct{})
+	go func() {
+		resp, err := client.Get("/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		close(done)
+	}()
+	for slowHits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get("/")
+		readBody(t, resp, err)
+	}
+	close(release)
+	<-done
+	if slowHits.Load() != 1 || fastHits.Load() != 5 {
+		t.Errorf("slow=%d fast=%d", slowHits.Load(), fastHits.Load())
+	}
+}
+
+// 测试配置校验
+func TestLoadBalancer_Validate(t *testing.T) {
+	if _, err := NewClient("").LoadBalancer(NewLoadBalancer([]string{"not a url"}, BalanceRoundRobin, nil)).Build(); err == nil {
+		t.Error("expected invalid endpoint error")
+	}
+	if _, err := NewClient("http://a").LoadBalancer(NewLoadBalancer([]string{"http://b"}, BalanceRoundRobin, nil)).Build(); err == nil {
+		t.Error("expected baseURL conflict error")
+	}
+}
@@ -0,0 +1,201 @@
+package k
+
+// http_batch.go —— 批量并发请求
+//
+// 设计目标：
+//   - 结果按输入顺序返回，与完成顺序无关
+//   - 有界并发，每个请求都经过客户端完整流水线（限速、熔断、重试、缓存、指标）
+//   - 可选 fail-fast：首个失败后取消进行中的请求，未开始的请求不再发送
+//   - 整批截止时间，错误以 errors.Join 聚合，逐个可通过 errors.As 取出 *BatchError
+//
+// 示例：
+//
+//	reqs := make([]BatchRequest, len(ids))
+//	for i, id := range ids {
+//	    reqs[i] = BatchRequest{Method: http.MethodGet, Path: "/users/" + id}
+//	}
+//	results, err := client.Batch(reqs, &BatchOptions{Concurrency: 20, Timeout: 30 * time.Second})
+//	for _, r := range results {
+//	    if r.Err == nil {
+//	        fmt.Println(r.StatusCode, string(r.Body))
+//	    }
+//	}
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchRequest 批量请求中的一项
+type BatchRequest struct {
+	Method      string          // HTTP 方法，默认 GET
+	Path        string          // 请求路径
+	Body        io.Reader       // 请求体，可为 nil
+	ContentType string          // 请求体类型
+	Request     *RequestBuilder // 附加的请求级配置，其中的 Context 会被批次 context 替代
+}
+
+// BatchResult 批量请求中一项的结果，响应体已读取完毕并关闭
+type BatchResult struct {
+	Index      int            // 在输入中的下标
+	StatusCode int            // 响应状态码，出错时为 0
+	Header     http.Header    // 响应头
+	Body       []byte         // 响应体，已按 Content-Encoding 解压，文本响应已转为 UTF-8
+	Response   *http.Response // 原始响应，Body 已替换为解码后的内存副本，可再次读取
+	Err        error          // 该项的错误（网络错误、ErrCircuitOpen、*HTTPError、context 取消等）
+}
+
+// BatchError 聚合错误中的单项错误
+type BatchError struct {
+	Index  int
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch[%d] %s %s: %v", e.Index, e.Method, e.Path, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchOptions Batch 的可选参数，传 nil 使用默认值。
+type BatchOptions struct {
+	// Context 批次的父 context，默认 context.Background()
+	Context context.Context
+	// Concurrency 最大并发数，默认 10
+	Concurrency int
+	// FailFast 为 true 时首个失败会取消其余请求
+	FailFast bool
+	// Timeout 整批截止时间，为 0 时不限制
+	Timeout time.Duration
+}
+
+// Batch 以有界并发发送一组请求，结果与 reqs 一一对应。
+//
+// 返回的 error 为所有失败项的 *BatchError 经 errors.Join 聚合，全部成功时为 nil；
+// 4xx 响应不视为失败（除非通过 R().ExpectStatus 声明），以 StatusCode 体现；
+// 5xx 与客户端其他方法一致，按可重试错误处理，重试耗尽后计为失败。
+// FailFast 时因取消而未完成的请求，其 Err 为 context.Canceled，不计入聚合错误。
+//
+// 参数：
+//   - reqs: 请求列表
+//   - opts: 批量选项，可为 nil
+func (c *HTTPClient) Batch(reqs []BatchRequest, opts *BatchOptions) ([]BatchResult, error) {
+	o := BatchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 10
+	}
+
+	ctx, cancel := context.WithCancel(o.Context)
+	defer cancel()
+	if o.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, o.Timeout)
+		defer cancelTimeout()
+	}
+
+	results := make([]BatchResult, len(reqs))
+	var (
+		mu       sync.Mutex
+		failures []error
+		failed   bool
+	)
+	fail := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// fail-fast 取消后其余请求产生的 context.Canceled 是连带结果，不计入
+		if o.FailFast && failed && errors.Is(err, context.Canceled) {
+			return
+		}
+		failed = true
+		failures = append(failures, &BatchError{Index: i, Method: batchMethod(reqs[i]), Path: reqs[i].Path, Err: err})
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(o.Concurrency, len(reqs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.batchOne(ctx, i, reqs[i])
+				if results[i].Err != nil {
+					fail(i, results[i].Err)
+					if o.FailFast {
+						cancel()
+					}
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range reqs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			// 批次已取消或超时，未开始的请求直接标记
+			for j := i; j < len(reqs); j++ {
+				results[j] = BatchResult{Index: j, Err: ctx.Err()}
+				fail(j, ctx.Err())
+			}
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 按下标排序，使聚合错误的顺序稳定
+	sort.Slice(failures, func(a, b int) bool {
+		return failures[a].(*BatchError).Index < failures[b].(*BatchError).Index
+	})
+	return results, errors.Join(failures...)
+}
+
+// batchOne 发送单个请求并经 ReadBody 读取完整响应体（解压、转码、大小限制）
+func (c *HTTPClient) batchOne(ctx context.Context, i int, br BatchRequest) BatchResult {
+	res := BatchResult{Index: i}
+	r := br.Request.clone()
+	r.cfg.ctx = ctx
+
+	resp, err := c.do(batchMethod(br), br.Path, br.Body, br.ContentType, r)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	body, err := c.ReadBody(resp)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	// 内存副本已解压，与标准库自动解压一致去掉编码相关的头
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength, resp.Uncompressed = int64(len(body)), true
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	res.StatusCode, res.Header, res.Body, res.Response = resp.StatusCode, resp.Header, body, resp
+	return res
+}
+
+func batchMethod(br BatchRequest) string {
+	if br.Method == "" {
+		return http.MethodGet
+	}
+	return br.Method
+}
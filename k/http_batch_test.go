@@ -0,0 +1,152 @@
+package k
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试结果顺序与并发上限
+func TestBatch_OrderAndConcurrency(t *testing.T) {
+	var cur, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := cur.Add(1)
+		defer cur.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// 下标越小越慢，保证完成顺序与输入顺序相反
+		var i int
+		fmt.Sscanf(r.URL.Path, "/items/%d", &i)
+		time.Sleep(time.Duration(20-i) * time.Millisecond)
+		if i == 7 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, i)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	reqs := make([]BatchRequest, 20)
+	for i := range reqs {
+		reqs[i] = BatchRequest{Path: fmt.Sprintf("/items/%d", i)}
+	}
+	results, err := client.Batch(reqs, &BatchOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Index != i || string(r.Body) != fmt.Sprint(i) {
+			t.Errorf("result %d: index=%d body=%q", i, r.Index, r.Body)
+		}
+	}
+	if results[7].StatusCode != http.StatusNotFound {
+		t.Errorf("4xx should be returned as status, got %d", results[7].StatusCode)
+	}
+	if p := peak.Load(); p > 4 {
+		t.Errorf("peak concurrency = %d", p)
+	}
+}
+
+// 测试 fail-fast 与聚合错误
+func TestBatch_FailFast(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	reqs := []BatchRequest{{Path: "/bad", Request: R().ExpectStatus(http.StatusOK)}}
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, BatchRequest{Path: "/slow"})
+	}
+	start := time.Now()
+	results, err := client.Batch(reqs, &BatchOptions{Concurrency: 2, FailFast: true})
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("fail-fast did not cancel in-flight requests")
+	}
+	var be *BatchError
+	var he *HTTPError
+	if !errors.As(err, &be) || be.Index != 0 || !errors.As(err, &he) || he.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Count(err.Error(), "batch[") != 1 {
+		t.Errorf("cancelled requests should not be aggregated: %v", err)
+	}
+	if !errors.Is(results[10].Err, context.Canceled) {
+		t.Errorf("pending request err = %v", results[10].Err)
+	}
+	if hits.Load() > 3 {
+		t.Errorf("requests after fail-fast were still sent: %d", hits.Load())
+	}
+}
+
+// 测试整批截止时间与熔断器
+func TestBatch_DeadlineAndBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	_, err := client.Batch([]BatchRequest{{Path: "/a"}, {Path: "/b"}}, &BatchOptions{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	cb := NewCircuitBreaker()
+	cb.MaxFailures = 1
+	client, _ = NewClient(srv.URL).CircuitBreaker(cb).Build()
+	results, err := client.Batch([]BatchRequest{{Path: "/down"}, {Path: "/down"}}, &BatchOptions{Concurrency: 1})
+	if err == nil || !errors.Is(results[1].Err, ErrCircuitOpen) {
+		t.Errorf("expected breaker to reject second request: %v", results[1].Err)
+	}
+}
+
+// 测试压缩的响应体解压后写入结果
+func TestBatch_DecodesCompressedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, r.URL.Path)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Compression().Build()
+	results, err := client.Batch([]BatchRequest{{Path: "/a"}, {Path: "/b"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"/a", "/b"} {
+		r := results[i]
+		again, _ := io.ReadAll(r.Response.Body)
+		if string(r.Body) != want || string(again) != want || r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("result %d: body=%q again=%q header=%v", i, r.Body, again, r.Header)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package k
+
+// http_bodylimit.go —— 响应体大小限制与解压炸弹防护
+//
+// 设计目标：
+//   - 客户端级 MaxBodySize 与请求级 R().MaxBodySize，超限时返回 *BodyTooLargeError（errors.Is(err, ErrBodyTooLarge)）
+//   - 限制同时作用于线上字节数与解压后的字节数：几 KB 的 gzip 炸弹解压到上限即中止，不会耗尽内存
+//   - 响应缓存、ReadBody / ReadJSON / ReadAs、HTTPError.Body 等所有读取路径共用同一限制
+//   - Content-Encoding 解码器可注册：内置 gzip、deflate，Brotli / zstd 等通过 Decompressor 接入，同样受限制约束
+//   - 流式请求（SSE、Download）默认不受客户端级限制，需要时通过 R().MaxBodySize 单独设置
+//
+// 示例：
+//
+//	client, _ := NewClient("https://api.example.com").
+//		Compression().
+//		MaxBodySize(10 << 20). // 10MB
+//		Decompressor("br", func(r io.Reader) (io.ReadCloser, error) {
+//			return io.NopCloser(brotli.NewReader(r)), nil
+//		}).
+//		Build()
+//
+//	resp, _ := client.Get("/export", R().MaxBodySize(200<<20)) // 单个请求放宽
+//	_, err := client.ReadBody(resp)
+//	if errors.Is(err, ErrBodyTooLarge) { ... }
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrBodyTooLarge 响应体超过 MaxBodySize，可用 errors.Is 判断；具体信息见 *BodyTooLargeError
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// ErrUnsupportedEncoding 响应的 Content-Encoding 没有对应的解码器
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// BodyTooLargeError 响应体超过限制时返回
+type BodyTooLargeError struct {
+	Limit        int64 // 生效的限制（字节）
+	Decompressed bool  // true 表示解压后的大小超限，false 表示线上字节数超限
+}
+
+func (e *BodyTooLargeError) Error() string {
+	if e.Decompressed {
+		return fmt.Sprintf("decompressed response body exceeds %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// Is 使 errors.Is(err, ErrBodyTooLarge) 成立
+func (e *BodyTooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
+
+// DecompressorFunc 按 Content-Encoding 解码响应体
+type DecompressorFunc func(r io.Reader) (io.ReadCloser, error)
+
+// builtinDecompressors 内置解码器，Decompressor 注册的同名编码优先
+var builtinDecompressors = map[string]DecompressorFunc{
+	"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"x-gzip":  func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"deflate": newDeflateReader,
+}
+
+// newDeflateReader HTTP 的 deflate 按规范为 zlib 格式，但不少服务端发送裸 deflate，按头部自动识别
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// bodyLimitKey 在请求 context 中携带生效的响应体大小限制，读取响应时通过 resp.Request 取回
+type bodyLimitKey struct{}
+
+// MaxBodySize 设置响应体大小上限（字节），同时限制线上字节数与解压后的字节数，超限时读取返回 *BodyTooLargeError。
+// 流式请求（SSE、Download）不受此限制，需要时使用 R().MaxBodySize。
+//
+// 参数：
+//   - n: 上限，<= 0 表示不限制（默认）
+func (b *ClientBuilder) MaxBodySize(n int64) *ClientBuilder {
+	b.maxBodySize = n
+	return b
+}
+
+// Decompressor 注册 Content-Encoding 解码器（如 "br"、"zstd"），并启用 Compression。
+// 注册的编码会加入 Accept-Encoding，解码后的大小同样受 MaxBodySize 限制。
+//
+// 参数：
+//   - encoding: 编码名称，不区分大小写
+//   - fn:       解码函数，返回的 ReadCloser 在响应读取完毕后关闭
+func (b *ClientBuilder) Decompressor(encoding string, fn DecompressorFunc) *ClientBuilder {
+	if b.decompressors == nil {
+		b.decompressors = make(map[string]DecompressorFunc)
+	}
+	b.decompressors[strings.ToLower(encoding)] = fn
+	b.compressed = true
+	return b
+}
+
+// MaxBodySize 设置本次请求的响应体大小上限，覆盖客户端设置。
+//
+// 参数：
+//   - n: 上限（字节），> 0 覆盖客户端设置，< 0 表示本次请求不限制，0 使用客户端设置
+func (r *RequestBuilder) MaxBodySize(n int64) *RequestBuilder {
+	r.cfg.maxBodySize = n
+	return r
+}
+
+// bodyLimit 计算请求生效的响应体上限，0 表示不限制
+func (c *HTTPClient) bodyLimit(cfg *requestConfig) int64 {
+	limit := c.builder.maxBodySize
+	if cfg.stream {
+		limit = 0
+	}
+	if cfg.maxBodySize != 0 {
+		limit = cfg.maxBodySize
+	}
+	return max(limit, 0)
+}
+
+// limitBody 按请求 context 中的限制包装响应体（线上字节数）
+func limitBody(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	if limit, _ := resp.Request.Context().Value(bodyLimitKey{}).(int64); limit > 0 {
+		resp.Body = &limitedBody{rc: resp.Body, limit: limit, remaining: limit, size: resp.ContentLength}
+	}
+}
+
+// limitedBody 读取超过 limit 字节时返回 *BodyTooLargeError，与 http.MaxBytesReader 类似但错误可识别
+type limitedBody struct {
+	rc           io.ReadCloser
+	limit        int64
+	remaining    int64
+	size         int64 // 已知的 Content-Length，超限时无需读取即失败
+	decompressed bool
+	err          error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.size > l.limit {
+		l.err = &BodyTooLargeError{Limit: l.limit, Decompressed: l.decompressed}
+		return 0, l.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1] // 多读 1 字节以区分"恰好等于上限"与"超过上限"
+	}
+	n, err := l.rc.Read(p)
+	if int64(n) <= l.remaining {
+		l.remaining -= int64(n)
+		return n, err
+	}
+	n = int(l.remaining)
+	l.remaining = 0
+	l.err = &BodyTooLargeError{Limit: l.limit, Decompressed: l.decompressed}
+	return n, l.err
+}
+
+func (l *limitedBody) Close() error {
+	return l.rc.Close()
+}
+
+// acceptEncoding 生成请求的 Accept-Encoding：注册的解码器优先，其次 deflate、gzip
+func (b *ClientBuilder) acceptEncoding() string {
+	var custom []string
+	for enc := range b.decompressors {
+		if _, ok := builtinDecompressors[enc]; !ok {
+			custom = append(custom, enc)
+		}
+	}
+	sort.Strings(custom)
+	return strings.Join(append(custom, "deflate", "gzip"), ", ")
+}
+
+// decodeBody 按 Content-Encoding 逐层（逆序）解码响应体，解压后的字节数受请求的 MaxBodySize 限制。
+// 返回的 ReadCloser 关闭时依次关闭各层解码器，不关闭 resp.Body。
+func (c *HTTPClient) decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	var encodings []string
+	for _, v := range resp.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	body := &decodedBody{Reader: resp.Body}
+	if len(encodings) == 0 {
+		return body, nil
+	}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		fn, ok := c.builder.decompressors[encodings[i]]
+		if !ok {
+			fn, ok = builtinDecompressors[encodings[i]]
+		}
+		if !ok {
+			body.Close()
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encodings[i])
+		}
+		rc, err := fn(body.Reader)
+		if err == io.EOF {
+			body.Close()
+			return &decodedBody{Reader: http.NoBody}, nil // HEAD / 204 等声明了编码但没有响应体
+		}
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		body.Reader = rc
+		body.closers = append(body.closers, rc)
+	}
+	if resp.Request != nil {
+		if limit, _ := resp.Request.Context().Value(bodyLimitKey{}).(int64); limit > 0 {
+			body.Reader = &limitedBody{rc: io.NopCloser(body.Reader), limit: limit, remaining: limit, size: -1, decompressed: true}
+		}
+	}
+	return body, nil
+}
+
+// decodedBody 解码后的响应体，Close 时由外向内关闭各层解码器
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	var errs []error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		errs = append(errs, d.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
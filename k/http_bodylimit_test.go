@@ -0,0 +1,164 @@
+package k
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试线上字节数超限（含 Content-Length 已知与分块传输）
+func TestMaxBodySize_Wire(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			for i := 0; i < 10; i++ {
+				io.WriteString(w, "0123456789")
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).MaxBodySize(50).Build()
+	for _, path := range []string{"/", "/chunked"} {
+		resp, err := client.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.ReadBody(resp)
+		var tooLarge *BodyTooLargeError
+		if !errors.Is(err, ErrBodyTooLarge) || !errors.As(err, &tooLarge) || tooLarge.Limit != 50 || tooLarge.Decompressed {
+			t.Errorf("%s: err = %v", path, err)
+		}
+	}
+
+	// 请求级覆盖：恰好等于上限可读取，负数不限制
+	resp, _ := client.Get("/", R().MaxBodySize(100))
+	if b, err := client.ReadBody(resp); err != nil || len(b) != 100 {
+		t.Errorf("limit 100: len=%d err=%v", len(b), err)
+	}
+	resp, _ = client.Get("/chunked", R().MaxBodySize(-1))
+	if b, err := client.ReadBody(resp); err != nil || len(b) != 100 {
+		t.Errorf("unlimited: len=%d err=%v", len(b), err)
+	}
+}
+
+// 测试解压炸弹：压缩后很小，解压后超限
+func TestMaxBodySize_DecompressionBomb(t *testing.T) {
+	var bomb bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&bomb, gzip.BestCompression)
+	zw.Write(make([]byte, 10<<20))
+	zw.Close()
+
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(bomb.Bytes())
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Compression().MaxBodySize(1 << 20).Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.ReadBody(resp)
+	var tooLarge *BodyTooLargeError
+	if !errors.As(err, &tooLarge) || !tooLarge.Decompressed {
+		t.Fatalf("err = %v", err)
+	}
+	if acceptEncoding != "deflate, gzip" {
+		t.Errorf("Accept-Encoding = %q", acceptEncoding)
+	}
+}
+
+// 测试 deflate（zlib / 裸 deflate）、多层编码与自定义解码器
+func TestDecompressor_Encodings(t *testing.T) {
+	const text = "hello, decompressor"
+	var zl, raw, gz bytes.Buffer
+	w1 := zlib.NewWriter(&zl)
+	w1.Write([]byte(text))
+	w1.Close()
+	w2, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	w2.Write([]byte(text))
+	w2.Close()
+	w3 := gzip.NewWriter(&gz)
+	w3.Write([]byte(base64.StdEncoding.EncodeToString([]byte(text))))
+	w3.Close()
+
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		switch r.URL.Path {
+		case "/zlib":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(zl.Bytes())
+		case "/raw":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(raw.Bytes())
+		case "/layered": // 先 b64 再 gzip
+			w.Header().Set("Content-Encoding", "b64, gzip")
+			w.Write(gz.Bytes())
+		case "/unknown":
+			w.Header().Set("Content-Encoding", "compress")
+			io.WriteString(w, "???")
+		}
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).
+		Decompressor("B64", func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+		}).
+		Build()
+	for _, path := range []string{"/zlib", "/raw", "/layered"} {
+		resp, err := client.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, err := client.ReadBodyString(resp); err != nil || s != text {
+			t.Errorf("%s: %q, %v", path, s, err)
+		}
+	}
+	if acceptEncoding != "b64, deflate, gzip" {
+		t.Errorf("Accept-Encoding = %q", acceptEncoding)
+	}
+
+	resp, _ := client.Get("/unknown")
+	if _, err := client.ReadBody(resp); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// 测试超限的可缓存响应不会写入缓存
+func TestMaxBodySize_ResponseCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	rc := NewResponseCache(time.Minute)
+	client, _ := NewClient(srv.URL).ResponseCache(rc).MaxBodySize(10).Build()
+	if _, err := client.Get("/"); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("err = %v", err)
+	}
+	resp, err := client.Get("/", R().MaxBodySize(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := client.ReadBody(resp); len(b) != 100 {
+		t.Errorf("len = %d", len(b))
+	}
+}
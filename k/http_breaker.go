@@ -0,0 +1,389 @@
+package k
+
+// http_breaker.go —— 熔断器：连续失败 / 滑动窗口失败率 / 慢调用率，按 host 或 route 隔离
+//
+// 设计目标：
+//   - 兼容原有的连续失败计数（MaxFailures）
+//   - 可选的滑动时间窗口：窗口内请求数达到 MinRequests 后，失败率或慢调用率超过阈值即熔断，
+//     间歇性失败（例如 50% 失败）不会因为偶尔一次成功而被清零
+//   - 半开状态限制并发探测数，避免冷却结束瞬间的流量洪峰压垮刚恢复的后端
+//   - OnStateChange 回调用于告警与监控
+//   - BreakerGroup 按 host 或 route 自动创建独立熔断器，一个后端故障不影响同一客户端的其他后端
+//
+// 示例：
+//
+//	cb := NewCircuitBreaker()
+//	cb.WindowSize = time.Minute
+//	cb.FailureRateThreshold = 0.5
+//	cb.SlowCallDuration = 2 * time.Second
+//	cb.SlowCallRateThreshold = 0.8
+//
+//	group := NewBreakerGroup(BreakerPerHost, func() *CircuitBreaker { return cb.Clone() })
+//	client, _ := NewClient("").BreakerGroup(group).Build()
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type circuitState int
+
+const (
+	stateClosed   circuitState = iota // 正常，所有请求放行
+	stateOpen                         // 熔断，所有请求拒绝
+	stateHalfOpen                     // 半开，放行探测请求
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case stateClosed:
+		return "closed"
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakerBuckets 滑动窗口的分桶数
+const breakerBuckets = 10
+
+// breakerBucket 滑动窗口中的一个时间片
+type breakerBucket struct {
+	epoch    int64 // 时间片序号，用于判断桶是否过期
+	total    int
+	failures int
+	slow     int
+}
+
+// CircuitBreaker 三态熔断器，状态流转：Closed → Open → HalfOpen → Closed。
+//
+// 创建后可直接修改公开字段调整阈值（应在投入使用前设置）：
+//
+//	cb := NewCircuitBreaker()
+//	cb.MaxFailures = 10          // 允许更多失败次数
+//	cb.OpenTimeout = time.Minute // 熔断持续更久
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	state     circuitState
+	failures  int // 连续失败次数
+	successes int // 半开状态的连续成功次数
+	probes    int // 半开状态进行中的探测请求数
+	openedAt  time.Time
+	buckets   [breakerBuckets]breakerBucket
+
+	// MaxFailures 连续失败多少次后开启熔断，默认 5；设为 0 禁用连续失败规则
+	MaxFailures int
+	// OpenTimeout 熔断持续时间，超过后进入半开状态，默认 30s
+	OpenTimeout time.Duration
+	// HalfOpenSuccesses 半开状态需要多少次连续成功才完全恢复，默认 2
+	HalfOpenSuccesses int
+	// HalfOpenMaxProbes 半开状态允许同时进行的探测请求数，默认 2；0 表示不限制
+	HalfOpenMaxProbes int
+
+	// WindowSize 滑动窗口时长，为 0 时不启用失败率 / 慢调用率规则
+	WindowSize time.Duration
+	// MinRequests 窗口内至少多少个请求才计算比率，默认 20
+	MinRequests int
+	// FailureRateThreshold 窗口内失败率达到该值（0~1）时熔断，为 0 时不启用
+	FailureRateThreshold float64
+	// SlowCallDuration 耗时超过该值的请求视为慢调用
+	SlowCallDuration time.Duration
+	// SlowCallRateThreshold 窗口内慢调用率达到该值（0~1）时熔断，为 0 时不启用
+	SlowCallRateThreshold float64
+
+	// OnStateChange 状态变化回调（在锁外同步执行），from / to 为 "closed"、"open"、"half-open"
+	OnStateChange func(from, to string)
+}
+
+// NewCircuitBreaker 创建使用默认阈值的熔断器。
+// 默认值：MaxFailures=5，OpenTimeout=30s，HalfOpenSuccesses=2，HalfOpenMaxProbes=2，MinRequests=20。
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		MaxFailures:       5,
+		OpenTimeout:       30 * time.Second,
+		HalfOpenSuccesses: 2,
+		HalfOpenMaxProbes: 2,
+		MinRequests:       20,
+	}
+}
+
+// Clone 复制阈值配置（不含运行状态），用于以同一模板创建多个独立熔断器
+func (cb *CircuitBreaker) Clone() *CircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return &CircuitBreaker{
+		MaxFailures:           cb.MaxFailures,
+		OpenTimeout:           cb.OpenTimeout,
+		HalfOpenSuccesses:     cb.HalfOpenSuccesses,
+		HalfOpenMaxProbes:     cb.HalfOpenMaxProbes,
+		WindowSize:            cb.WindowSize,
+		MinRequests:           cb.MinRequests,
+		FailureRateThreshold:  cb.FailureRateThreshold,
+		SlowCallDuration:      cb.SlowCallDuration,
+		SlowCallRateThreshold: cb.SlowCallRateThreshold,
+		OnStateChange:         cb.OnStateChange,
+	}
+}
+
+// Allow 判断当前是否允许发送请求。
+// 熔断开启且未过冷却期时返回 false；半开状态下探测数已满时返回 false。
+// 此方法由 HTTPClient 内部调用，通常不需要外部直接调用；
+// 外部调用时，每次返回 true 都应对应一次 RecordSuccess / RecordFailure。
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	var notify func()
+	defer func() {
+		cb.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+
+	switch cb.state {
+	case stateOpen:
+		if time.Since(cb.openedAt) <= cb.OpenTimeout {
+			return false
+		}
+		notify = cb.transitionLocked(stateHalfOpen)
+		cb.probes = 1
+		return true
+	case stateHalfOpen:
+		if cb.HalfOpenMaxProbes > 0 && cb.probes >= cb.HalfOpenMaxProbes {
+			return false
+		}
+		cb.probes++
+		return true
+	}
+	return true
+}
+
+// RecordSuccess 记录一次成功，由 HTTPClient 内部在请求成功后调用。
+// 半开状态下连续成功达到阈值时切换回 Closed。
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.record(false, 0)
+}
+
+// RecordFailure 记录一次失败，由 HTTPClient 内部在请求失败后调用。
+// 连续失败达到阈值、或窗口失败率达到阈值时切换到 Open 状态；半开状态下任一失败立即重新熔断。
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.record(true, 0)
+}
+
+// State 返回熔断器当前状态的字符串描述："closed"、"open" 或 "half-open"。
+// 可用于监控上报或调试日志。
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state.String()
+}
+
+// record 记录一次请求结果，elapsed 用于慢调用判定（为 0 时不计慢调用）
+func (cb *CircuitBreaker) record(failed bool, elapsed time.Duration) {
+	cb.mu.Lock()
+	var notify func()
+	defer func() {
+		cb.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+
+	slow := cb.SlowCallDuration > 0 && elapsed >= cb.SlowCallDuration
+	if cb.WindowSize > 0 {
+		b := cb.bucketLocked(time.Now())
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if slow {
+			b.slow++
+		}
+	}
+
+	switch cb.state {
+	case stateHalfOpen:
+		if cb.probes > 0 {
+			cb.probes--
+		}
+		if failed || (slow && cb.SlowCallRateThreshold > 0) {
+			notify = cb.openLocked()
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.HalfOpenSuccesses {
+			notify = cb.transitionLocked(stateClosed)
+		}
+	case stateClosed:
+		if failed {
+			cb.failures++
+		} else {
+			cb.failures = 0
+		}
+		if (cb.MaxFailures > 0 && cb.failures >= cb.MaxFailures) || cb.windowTrippedLocked() {
+			notify = cb.openLocked()
+		}
+	}
+}
+
+// release 归还 Allow 占用的半开探测名额（请求在发送前被取消时调用，不计入结果）
+func (cb *CircuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == stateHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+// windowTrippedLocked 判断滑动窗口内的失败率 / 慢调用率是否超过阈值
+func (cb *CircuitBreaker) windowTrippedLocked() bool {
+	if cb.WindowSize <= 0 || (cb.FailureRateThreshold <= 0 && cb.SlowCallRateThreshold <= 0) {
+		return false
+	}
+	total, failures, slow := cb.windowCountsLocked(time.Now())
+	if total == 0 || total < cb.MinRequests {
+		return false
+	}
+	if cb.FailureRateThreshold > 0 && float64(failures)/float64(total) >= cb.FailureRateThreshold {
+		return true
+	}
+	return cb.SlowCallRateThreshold > 0 && float64(slow)/float64(total) >= cb.SlowCallRateThreshold
+}
+
+// bucketLocked 返回 now 所在的桶，过期桶会被重置
+func (cb *CircuitBreaker) bucketLocked(now time.Time) *breakerBucket {
+	epoch := now.UnixNano() / int64(cb.bucketWidth())
+	b := &cb.buckets[epoch%breakerBuckets]
+	if b.epoch != epoch {
+		*b = breakerBucket{epoch: epoch}
+	}
+	return b
+}
+
+// windowCountsLocked 汇总窗口内未过期的桶
+func (cb *CircuitBreaker) windowCountsLocked(now time.Time) (total, failures, slow int) {
+	epoch := now.UnixNano() / int64(cb.bucketWidth())
+	for _, b := range cb.buckets {
+		if b.epoch > epoch-breakerBuckets && b.epoch <= epoch {
+			total += b.total
+			failures += b.failures
+			slow += b.slow
+		}
+	}
+	return
+}
+
+func (cb *CircuitBreaker) bucketWidth() time.Duration {
+	w := cb.WindowSize / breakerBuckets
+	if w <= 0 {
+		w = time.Millisecond
+	}
+	return w
+}
+
+// openLocked 切换到 Open 并记录开启时间
+func (cb *CircuitBreaker) openLocked() func() {
+	cb.openedAt = time.Now()
+	return cb.transitionLocked(stateOpen)
+}
+
+// transitionLocked 切换状态并重置计数，返回需要在锁外执行的回调
+func (cb *CircuitBreaker) transitionLocked(to circuitState) func() {
+	from := cb.state
+	cb.state = to
+	cb.successes = 0
+	cb.probes = 0
+	if to == stateClosed {
+		cb.failures = 0
+		cb.buckets = [breakerBuckets]breakerBucket{}
+	}
+	if from == to || cb.OnStateChange == nil {
+		return nil
+	}
+	fn := cb.OnStateChange
+	return func() { fn(from.String(), to.String()) }
+}
+
+// ─── 熔断器组 ──────────────────────────────────────────
+
+// BreakerScope 熔断器组的隔离粒度
+type BreakerScope int
+
+const (
+	BreakerPerHost  BreakerScope = iota // 按 host（含端口）隔离
+	BreakerPerRoute                     // 按 method + host + route 隔离，route 取 R().Route，未设置时取 URL path
+)
+
+// BreakerGroup 按请求自动创建并复用独立熔断器，并发安全。
+// BreakerPerRoute 且未设置 R().Route 时，路径中的 ID 会导致熔断器数量持续增长，应配合路由模板使用。
+type BreakerGroup struct {
+	// OnStateChange 任一熔断器状态变化时回调，key 为 host 或 "METHOD host route"
+	OnStateChange func(key, from, to string)
+
+	scope   BreakerScope
+	factory func() *CircuitBreaker
+	mu      sync.Mutex
+	items   map[string]*CircuitBreaker
+}
+
+// NewBreakerGroup 创建熔断器组。
+//
+// 参数：
+//   - scope:   隔离粒度
+//   - factory: 为新 key 创建熔断器，为 nil 时使用 NewCircuitBreaker
+func NewBreakerGroup(scope BreakerScope, factory func() *CircuitBreaker) *BreakerGroup {
+	if factory == nil {
+		factory = NewCircuitBreaker
+	}
+	return &BreakerGroup{scope: scope, factory: factory, items: make(map[string]*CircuitBreaker)}
+}
+
+// Get 返回 key 对应的熔断器，不存在时创建
+func (g *BreakerGroup) Get(key string) *CircuitBreaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cb, ok := g.items[key]
+	if !ok {
+		cb = g.factory()
+		if g.OnStateChange != nil {
+			inner, groupFn := cb.OnStateChange, g.OnStateChange
+			cb.OnStateChange = func(from, to string) {
+				if inner != nil {
+					inner(from, to)
+				}
+				groupFn(key, from, to)
+			}
+		}
+		g.items[key] = cb
+	}
+	return cb
+}
+
+// States 返回每个熔断器的当前状态快照
+func (g *BreakerGroup) States() map[string]string {
+	g.mu.Lock()
+	items := make(map[string]*CircuitBreaker, len(g.items))
+	for k, v := range g.items {
+		items[k] = v
+	}
+	g.mu.Unlock()
+
+	states := make(map[string]string, len(items))
+	for k, cb := range items {
+		states[k] = cb.State()
+	}
+	return states
+}
+
+// forRequest 按隔离粒度计算 key 并返回熔断器
+func (g *BreakerGroup) forRequest(req *http.Request, route string) *CircuitBreaker {
+	if g.scope == BreakerPerRoute {
+		if route == "" {
+			route = req.URL.Path
+		}
+		return g.Get(req.Method + " " + req.URL.Host + " " + route)
+	}
+	return g.Get(req.URL.Host)
+}
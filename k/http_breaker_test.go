@@ -0,0 +1,176 @@
+package k
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试失败率窗口：交替成功 / 失败时连续失败计数永远不会触发，失败率规则会触发
+func TestCircuitBreaker_FailureRate(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.WindowSize = time.Minute
+	cb.MinRequests = 10
+	cb.FailureRateThreshold = 0.5
+
+	var transitions []string
+	cb.OnStateChange = func(from, to string) { transitions = append(transitions, from+"->"+to) }
+
+	for i := 0; i < 9; i++ {
+		if i%2 == 0 {
+			cb.RecordFailure()
+		} else {
+			cb.RecordSuccess()
+		}
+	}
+	if cb.State() != "closed" {
+		t.Fatalf("should stay closed below MinRequests, got %s", cb.State())
+	}
+	cb.RecordSuccess() // 10 个请求，5 个失败
+	if cb.State() != "open" {
+		t.Fatalf("expected open at 50%% failure rate, got %s", cb.State())
+	}
+	if cb.Allow() {
+		t.Error("open breaker should reject")
+	}
+	if len(transitions) != 1 || transitions[0] != "closed->open" {
+		t.Errorf("transitions = %v", transitions)
+	}
+}
+
+// 测试慢调用率
+func TestCircuitBreaker_SlowCallRate(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.WindowSize = time.Minute
+	cb.MinRequests = 4
+	cb.SlowCallDuration = 100 * time.Millisecond
+	cb.SlowCallRateThreshold = 0.75
+
+	cb.record(false, 10*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		cb.record(false, time.Second)
+	}
+	if cb.State() != "open" {
+		t.Fatalf("expected open on slow calls, got %s", cb.State())
+	}
+}
+
+// 测试半开状态的并发探测上限与恢复
+func TestCircuitBreaker_HalfOpenProbes(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.MaxFailures = 1
+	cb.OpenTimeout = 10 * time.Millisecond
+	cb.HalfOpenMaxProbes = 2
+	cb.HalfOpenSuccesses = 2
+
+	cb.RecordFailure()
+	time.Sleep(20 * time.Millisecond)
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cb.Allow() {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 2 || cb.State() != "half-open" {
+		t.Fatalf("allowed = %d, state = %s", allowed, cb.State())
+	}
+	cb.RecordSuccess()
+	cb.RecordSuccess()
+	if cb.State() != "closed" {
+		t.Errorf("expected closed after probes succeed, got %s", cb.State())
+	}
+}
+
+// errBodyReader 读取即失败的请求体
+type errBodyReader struct{}
+
+func (errBodyReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+// 测试请求体读取失败时归还半开探测名额，不会让熔断器卡在半开状态
+func TestCircuitBreaker_ReleaseOnBodyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cb := NewCircuitBreaker()
+	cb.MaxFailures = 1
+	cb.OpenTimeout = 10 * time.Millisecond
+	cb.HalfOpenMaxProbes = 1
+	client, _ := NewClient(srv.URL).CircuitBreaker(cb).Build()
+
+	cb.RecordFailure()
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Post("/", errBodyReader{}, "text/plain"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+	for i := 0; i < cb.HalfOpenSuccesses; i++ {
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatalf("probe slot leaked: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if cb.State() != "closed" {
+		t.Errorf("state = %s", cb.State())
+	}
+}
+
+// 测试按 host 隔离：一个后端熔断不影响另一个
+func TestBreakerGroup_PerHost(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+
+	var opened []string
+	group := NewBreakerGroup(BreakerPerHost, func() *CircuitBreaker {
+		cb := NewCircuitBreaker()
+		cb.MaxFailures = 2
+		return cb
+	})
+	group.OnStateChange = func(key, from, to string) {
+		if to == "open" {
+			opened = append(opened, key)
+		}
+	}
+	client, _ := NewClient("").BreakerGroup(group).Build()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(bad.URL); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected server error, got %v", err)
+		}
+	}
+	if _, err := client.Get(bad.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	resp, err := client.Get(good.URL)
+	if err != nil {
+		t.Fatalf("healthy host should not be affected: %v", err)
+	}
+	resp.Body.Close()
+
+	badHost := strings.TrimPrefix(bad.URL, "http://")
+	if len(opened) != 1 || opened[0] != badHost {
+		t.Errorf("opened = %v", opened)
+	}
+	if states := group.States(); states[badHost] != "open" || states[strings.TrimPrefix(good.URL, "http://")] != "closed" {
+		t.Errorf("states = %v", states)
+	}
+}
@@ -0,0 +1,544 @@
+package k
+
+// http_cache.go —— 遵循 HTTP 缓存语义（RFC 9111）的私有响应缓存
+//
+// 行为概要：
+//   - 仅缓存 GET；请求带 Cache-Control: no-store 时完全绕过缓存
+//   - 响应 Cache-Control: no-store 或 Vary: * 不缓存；private 允许缓存（本缓存属于私有缓存）
+//   - 新鲜度：max-age > Expires - Date > NewResponseCache 的 ttl（启发式默认值，仅对 200 生效），并扣除 Age
+//   - 过期或 no-cache 的条目若带 ETag / Last-Modified，发送条件请求；服务端返回 304 时直接复用缓存内容
+//   - 缓存键包含 Authorization / Cookie 摘要，不同用户的响应互不可见；按 Vary 指定的请求头区分变体
+//   - 不安全方法（POST / PUT / PATCH / DELETE 等）收到 2xx / 3xx 后，删除目标 URI 及同源 Location / Content-Location 的缓存
+//   - 内存模式支持 MaxEntries / MaxBytes 的 LRU 淘汰；也可通过 NewResponseCacheWithStore 存入 store.AdapterCache（如 Redis）
+//
+// 示例：
+//
+//	rc := NewResponseCache(5 * time.Minute)
+//	rc.MaxEntries = 1000
+//	rc.MaxBytes = 64 << 20
+//	client, _ := NewClient("https://api.example.com").ResponseCache(rc).Build()
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// cacheEntry 单条缓存记录（同一 URL 的一个 Vary 变体）
+type cacheEntry struct {
+	respBody  []byte
+	headers   http.Header
+	status    int
+	expiresAt time.Time         // 新鲜度截止时间，之后需要重新验证
+	vary      map[string]string // Vary 指定的请求头及其取值，用于匹配变体
+}
+
+// hasValidator 是否带有可用于条件请求的验证器
+func (e *cacheEntry) hasValidator() bool {
+	return e.headers.Get("ETag") != "" || e.headers.Get("Last-Modified") != ""
+}
+
+// matches 判断请求头是否与该变体的 Vary 取值一致
+func (e *cacheEntry) matches(req *http.Request) bool {
+	for name, v := range e.vary {
+		if normalizeVaryValue(req.Header.Values(name)) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// addConditionalHeaders 为重新验证附加 If-None-Match / If-Modified-Since，调用方已自行设置时不覆盖
+func (e *cacheEntry) addConditionalHeaders(req *http.Request) {
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return
+	}
+	if etag := e.headers.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.headers.Get("Last-Modified"); lm != "" {
+		req.Header.Set("If-Modified-Since", lm)
+	}
+}
+
+// response 以缓存内容构造响应，每次返回独立的 Header 与 Body
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode:    e.status,
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.headers.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.respBody)),
+		ContentLength: int64(len(e.respBody)),
+		Request:       req,
+	}
+}
+
+// cacheSlot 同一主键（URL + 身份）下的全部变体，是 LRU 的淘汰单位
+type cacheSlot struct {
+	key      string
+	variants []*cacheEntry
+	size     int64
+}
+
+// ResponseCache 遵循 HTTP 缓存语义的私有响应缓存，读写均并发安全。
+//
+// 创建后可直接修改公开字段（须在注入客户端之前）：
+//
+//	rc := NewResponseCache(5 * time.Minute)
+//	rc.MaxEntries = 1000  // 最多缓存 1000 个 URL
+//	rc.MaxBytes = 64 << 20 // 响应体合计不超过 64MB
+type ResponseCache struct {
+	// MaxEntries 内存模式下最多缓存的 URL 数（含其全部 Vary 变体），0 表示不限制
+	MaxEntries int
+	// MaxBytes 内存模式下响应体总字节数上限，0 表示不限制
+	MaxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // 主键 → LRU 节点（值为 *cacheSlot）
+	lru     *list.List               // 队首为最近使用
+	size    int64
+	ttl     time.Duration
+	backend store.AdapterCache
+}
+
+// NewResponseCache 创建内存响应缓存。
+//
+// 参数：
+//   - ttl: 响应未声明 max-age / Expires 时 200 响应的默认有效期，例如 5*time.Minute。
+//     带验证器的条目过期后还会再保留 ttl 用于条件请求。
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	c := &ResponseCache{entries: make(map[string]*list.Element), lru: list.New(), ttl: ttl}
+	go c.evictLoop()
+	return c
+}
+
+// NewResponseCacheWithStore 创建以 store.AdapterCache 为存储后端的响应缓存，
+// 适合多副本共享缓存（如 Redis）。条目过期由后端负责，MaxEntries / MaxBytes 不生效。
+//
+// 参数：
+//   - ttl: 同 NewResponseCache
+//   - s:   缓存后端，条目以 JSON 字符串写入，key 前缀为 "http_cache:"
+func NewResponseCacheWithStore(ttl time.Duration, s store.AdapterCache) *ResponseCache {
+	return &ResponseCache{entries: make(map[string]*list.Element), lru: list.New(), ttl: ttl, backend: s}
+}
+
+// evictLoop 后台定期清理不再可用的条目，随 ResponseCache 生命周期运行
+func (c *ResponseCache) evictLoop() {
+	interval := c.ttl
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		now := time.Now()
+		c.mu.Lock()
+		for el := c.lru.Back(); el != nil; {
+			prev := el.Prev()
+			slot := el.Value.(*cacheSlot)
+			if c.slotExpired(slot, now) {
+				c.removeLocked(el)
+			}
+			el = prev
+		}
+		c.mu.Unlock()
+	}
+}
+
+// slotExpired 所有变体都既不新鲜、也无法再重新验证时返回 true
+func (c *ResponseCache) slotExpired(slot *cacheSlot, now time.Time) bool {
+	for _, e := range slot.variants {
+		if !c.entryExpired(e, now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ResponseCache) entryExpired(e *cacheEntry, now time.Time) bool {
+	if !now.After(e.expiresAt) {
+		return false
+	}
+	return !e.hasValidator() || now.After(e.expiresAt.Add(c.ttl))
+}
+
+// ─── 供 HTTPClient.execute 调用的高层方法 ───────────────
+
+// lookup 查找与请求匹配的变体。
+// fresh 为 true 时可直接使用；entry 非 nil 但 fresh 为 false 时需要发送条件请求重新验证。
+// key 为 cacheKey 的结果，lookup / store / revalidated 使用发送前计算的同一个 key。
+func (c *ResponseCache) lookup(key string, req *http.Request) (entry *cacheEntry, fresh bool) {
+	reqCC := parseCacheControl(req.Header.Values("Cache-Control"))
+	now := time.Now()
+	for _, e := range c.loadSlot(key) {
+		if !e.matches(req) {
+			continue
+		}
+		if c.entryExpired(e, now) {
+			return nil, false
+		}
+		_, noCache := reqCC["no-cache"]
+		if maxAge, ok := reqCC["max-age"]; ok && maxAge == "0" {
+			noCache = true
+		}
+		return e, !noCache && !now.After(e.expiresAt)
+	}
+	return nil, false
+}
+
+// store 判断响应是否可缓存，可缓存时写入并返回新条目
+func (c *ResponseCache) store(key string, req *http.Request, resp *http.Response, body []byte) *cacheEntry {
+	if !cacheableStatus[resp.StatusCode] {
+		return nil
+	}
+	respCC := parseCacheControl(resp.Header.Values("Cache-Control"))
+	if _, ok := respCC["no-store"]; ok {
+		return nil
+	}
+	vary := make(map[string]string)
+	for _, field := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				vary[name] = normalizeVaryValue(req.Header.Values(name))
+			}
+		}
+	}
+	e := &cacheEntry{respBody: body, headers: resp.Header.Clone(), status: resp.StatusCode, vary: vary}
+	lifetime, explicit := c.freshnessLifetime(resp.Header, respCC)
+	if !explicit && resp.StatusCode != http.StatusOK {
+		return nil // 非 200 响应只在服务端显式声明新鲜度时缓存
+	}
+	e.expiresAt = time.Now().Add(lifetime)
+	if lifetime <= 0 && !e.hasValidator() {
+		return nil // 立即过期且无法重新验证，缓存无意义
+	}
+	c.storeEntry(key, e)
+	return e
+}
+
+// revalidated 用 304 响应的头部刷新条目的新鲜度，返回更新后的条目
+func (c *ResponseCache) revalidated(key string, e *cacheEntry, notModified *http.Response) *cacheEntry {
+	updated := &cacheEntry{respBody: e.respBody, headers: e.headers.Clone(), status: e.status, vary: e.vary}
+	for name, values := range notModified.Header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		updated.headers[name] = values
+	}
+	lifetime, _ := c.freshnessLifetime(updated.headers, parseCacheControl(updated.headers.Values("Cache-Control")))
+	updated.expiresAt = time.Now().Add(lifetime)
+	c.storeEntry(key, updated)
+	return updated
+}
+
+// freshnessLifetime 计算新鲜度时长（已扣除 Age），explicit 表示服务端显式声明了新鲜度
+func (c *ResponseCache) freshnessLifetime(h http.Header, cc map[string]string) (time.Duration, bool) {
+	var (
+		lifetime time.Duration
+		explicit = true
+	)
+	if _, ok := cc["no-cache"]; ok {
+		return 0, true
+	}
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, true
+		}
+		lifetime = time.Duration(secs) * time.Second
+	} else if exp := h.Get("Expires"); exp != "" {
+		expires, err := http.ParseTime(exp)
+		if err != nil {
+			return 0, true // 非法 Expires 视为已过期
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		lifetime = expires.Sub(date)
+	} else {
+		lifetime, explicit = c.ttl, false
+	}
+	if age, err := strconv.ParseInt(h.Get("Age"), 10, 64); err == nil && age > 0 {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	return lifetime, explicit
+}
+
+// invalidate 不安全方法收到非错误响应（2xx / 3xx）后，删除目标 URI 以及同源的 Location / Content-Location
+// 对应的缓存（RFC 9111 §4.4）。内存模式删除该 URI 下所有身份的条目；store 后端的键经过哈希无法枚举，
+// 只删除当前调用方身份的条目。
+func (c *ResponseCache) invalidate(req *http.Request, resp *http.Response, jar http.CookieJar) {
+	if safeMethods[req.Method] || resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return
+	}
+	targets := []*url.URL{req.URL}
+	for _, h := range []string{"Location", "Content-Location"} {
+		if v := resp.Header.Get(h); v != "" {
+			if u, err := req.URL.Parse(v); err == nil && u.Scheme == req.URL.Scheme && u.Host == req.URL.Host {
+				targets = append(targets, u)
+			}
+		}
+	}
+	for _, u := range targets {
+		r := *req
+		r.URL = u
+		c.remove(cacheKey(&r, jar), u.String())
+	}
+}
+
+// ─── 底层存取（按主键读写全部变体） ─────────────────────
+
+// get 按主键返回第一个新鲜的变体
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	now := time.Now()
+	for _, e := range c.loadSlot(key) {
+		if !now.After(e.expiresAt) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// set 写入条目，未设置 expiresAt 时使用默认 ttl
+func (c *ResponseCache) set(key string, e *cacheEntry) {
+	if e.expiresAt.IsZero() {
+		e.expiresAt = time.Now().Add(c.ttl)
+	}
+	if e.headers == nil {
+		e.headers = make(http.Header)
+	}
+	c.storeEntry(key, e)
+}
+
+// loadSlot 读取主键下的全部变体，内存模式同时刷新 LRU 位置
+func (c *ResponseCache) loadSlot(key string) []*cacheEntry {
+	if c.backend != nil {
+		raw, err := c.backend.Get(backendCacheKey(key))
+		if err != nil || raw == "" {
+			return nil
+		}
+		var stored []storedCacheEntry
+		if json.Unmarshal([]byte(raw), &stored) != nil {
+			return nil
+		}
+		variants := make([]*cacheEntry, 0, len(stored))
+		for _, s := range stored {
+			variants = append(variants, s.entry())
+		}
+		return variants
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheSlot).variants
+}
+
+// storeEntry 写入或替换主键下 Vary 取值相同的变体
+func (c *ResponseCache) storeEntry(key string, e *cacheEntry) {
+	if c.backend != nil {
+		c.storeBackend(key, e)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		el = c.lru.PushFront(&cacheSlot{key: key})
+		c.entries[key] = el
+	}
+	c.lru.MoveToFront(el)
+	slot := el.Value.(*cacheSlot)
+	slot.variants = replaceVariant(slot.variants, e)
+	c.size -= slot.size
+	slot.size = 0
+	for _, v := range slot.variants {
+		slot.size += int64(len(v.respBody))
+	}
+	c.size += slot.size
+	for c.lru.Len() > 0 && ((c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries) || (c.MaxBytes > 0 && c.size > c.MaxBytes)) {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *ResponseCache) storeBackend(key string, e *cacheEntry) {
+	variants := replaceVariant(c.loadSlot(key), e)
+	now := time.Now()
+	stored := make([]storedCacheEntry, 0, len(variants))
+	var keepUntil time.Time
+	for _, v := range variants {
+		if c.entryExpired(v, now) {
+			continue
+		}
+		stored = append(stored, newStoredCacheEntry(v))
+		until := v.expiresAt
+		if v.hasValidator() {
+			until = until.Add(c.ttl)
+		}
+		if until.After(keepUntil) {
+			keepUntil = until
+		}
+	}
+	if len(stored) == 0 {
+		_ = c.backend.Del(backendCacheKey(key))
+		return
+	}
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	expire := int(time.Until(keepUntil).Seconds()) + 1
+	_ = c.backend.Set(backendCacheKey(key), string(raw), expire)
+}
+
+// remove 删除主键 key 的条目；内存模式同时删除 uri 下其他身份的条目
+func (c *ResponseCache) remove(key, uri string) {
+	if c.backend != nil {
+		_ = c.backend.Del(backendCacheKey(key))
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, el := range c.entries {
+		if k == uri || strings.HasPrefix(k, uri+"#") {
+			c.removeLocked(el)
+		}
+	}
+}
+
+func (c *ResponseCache) removeLocked(el *list.Element) {
+	slot := el.Value.(*cacheSlot)
+	c.lru.Remove(el)
+	delete(c.entries, slot.key)
+	c.size -= slot.size
+}
+
+// replaceVariant 用 e 替换 Vary 取值相同的旧变体，不存在时追加
+func replaceVariant(variants []*cacheEntry, e *cacheEntry) []*cacheEntry {
+	out := make([]*cacheEntry, 0, len(variants)+1)
+	for _, v := range variants {
+		if !sameVary(v.vary, e.vary) {
+			out = append(out, v)
+		}
+	}
+	return append(out, e)
+}
+
+func sameVary(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ─── 工具函数 ──────────────────────────────────────────
+
+// cacheableStatus 默认可缓存的状态码（RFC 9110 §15.1）
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// safeMethods RFC 9110 §9.2.1 定义的安全方法，其余方法成功后使缓存失效
+var safeMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// cacheKey 以 URL 加调用方身份（Authorization / Cookie 摘要）作为主键，避免跨用户串数据。
+// Cookie Jar 中的 Cookie 由 http.Client 在发送时才写入请求头，因此单独从 jar 中取出计入身份，
+// 使共享缓存的多个 Session 之间互不命中。
+func cacheKey(req *http.Request, jar http.CookieJar) string {
+	key := req.URL.String()
+	auth, cookie := req.Header.Get("Authorization"), req.Header.Get("Cookie")
+	if jar != nil {
+		for _, c := range jar.Cookies(req.URL) {
+			cookie += "; " + c.Name + "=" + c.Value
+		}
+	}
+	if auth == "" && cookie == "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte(auth + "\x00" + cookie))
+	return key + "#" + hex.EncodeToString(sum[:8])
+}
+
+func backendCacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "http_cache:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeVaryValue 合并同名请求头的多个值，去掉多余空白
+func normalizeVaryValue(values []string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseCacheControl 解析 Cache-Control 指令，key 统一小写，无值的指令 value 为空串
+func parseCacheControl(values []string) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range values {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, val, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return cc
+}
+
+// storedCacheEntry cacheEntry 的 JSON 序列化形式，用于 store 后端
+type storedCacheEntry struct {
+	Status    int               `json:"status"`
+	Headers   http.Header       `json:"headers"`
+	Body      []byte            `json:"body"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Vary      map[string]string `json:"vary,omitempty"`
+}
+
+func newStoredCacheEntry(e *cacheEntry) storedCacheEntry {
+	return storedCacheEntry{Status: e.status, Headers: e.headers, Body: e.respBody, ExpiresAt: e.expiresAt, Vary: e.vary}
+}
+
+func (s storedCacheEntry) entry() *cacheEntry {
+	return &cacheEntry{respBody: s.Body, headers: s.Headers, status: s.Status, expiresAt: s.ExpiresAt, vary: s.Vary}
+}
@@ -0,0 +1,215 @@
+package k
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// getBody 发送 GET 并读取响应体
+func getBody(t *testing.T, client *HTTPClient, path string, headers map[string]string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(path, R().Headers(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+// 测试 ETag 条件请求：过期后发送 If-None-Match，304 时复用缓存内容
+func TestResponseCache_Revalidate(t *testing.T) {
+	calls, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).ResponseCache(NewResponseCache(time.Minute)).Build()
+	for i := 0; i < 3; i++ {
+		code, body := getBody(t, client, "/r", nil)
+		if code != http.StatusOK || body != "payload" {
+			t.Fatalf("round %d: code=%d body=%q", i, code, body)
+		}
+	}
+	if calls != 3 || notModified != 2 {
+		t.Errorf("calls=%d notModified=%d", calls, notModified)
+	}
+}
+
+// 测试不安全方法成功后删除目标 URI 与同源 Location 的缓存，失败或跨源时保留
+func TestResponseCache_UnsafeInvalidates(t *testing.T) {
+	var mu sync.Mutex
+	versions := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Cache-Control", "max-age=60")
+			fmt.Fprintf(w, "%s v%d", r.URL.Path, versions[r.URL.Path])
+		case http.MethodPut:
+			versions[r.URL.Path]++
+		case http.MethodPost:
+			versions["/users/2"]++
+			w.Header().Set("Location", "/users/2")
+			w.Header().Set("Content-Location", "http://other.example/users/3")
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			versions[r.URL.Path]++
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).ResponseCache(NewResponseCache(time.Minute)).Build()
+	alice, bob := map[string]string{"Authorization": "alice"}, map[string]string{"Authorization": "bob"}
+	for _, path := range []string{"/users/1", "/users/2", "/users/3"} {
+		getBody(t, client, path, alice)
+		getBody(t, client, path, bob)
+	}
+	for _, send := range []func() (*http.Response, error){
+		func() (*http.Response, error) { return client.Put("/users/1", nil, "", R().Headers(alice)) },
+		func() (*http.Response, error) { return client.Post("/users", nil, "", R().Headers(alice)) },
+	} {
+		resp, err := send()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Delete("/users/3", R().Headers(alice)); err == nil {
+		t.Fatal("expected server error")
+	}
+
+	wants := map[string]string{"/users/1": "/users/1 v1", "/users/2": "/users/2 v1", "/users/3": "/users/3 v0"}
+	for path, want := range wants {
+		for _, h := range []map[string]string{alice, bob} {
+			if _, body := getBody(t, client, path, h); body != want {
+				t.Errorf("GET %s as %s = %q, want %q", path, h["Authorization"], body, want)
+			}
+		}
+	}
+}
+
+// 测试 no-store 与 max-age 指令
+func TestResponseCache_CacheControl(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch r.URL.Path {
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/maxage":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/expired":
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		_, _ = w.Write([]byte(strconv.Itoa(calls[r.URL.Path])))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).ResponseCache(NewResponseCache(time.Minute)).Build()
+	for i := 0; i < 2; i++ {
+		getBody(t, client, "/nostore", nil)
+		getBody(t, client, "/maxage", nil)
+		getBody(t, client, "/expired", nil)
+	}
+	if calls["/nostore"] != 2 || calls["/maxage"] != 1 || calls["/expired"] != 2 {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+
+	// 请求方声明 no-store 时绕过缓存
+	getBody(t, client, "/maxage", map[string]string{"Cache-Control": "no-store"})
+	if calls["/maxage"] != 2 {
+		t.Errorf("request no-store should bypass cache, calls=%d", calls["/maxage"])
+	}
+}
+
+// 测试 Vary 与 Authorization 隔离
+func TestResponseCache_VaryAndAuth(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).ResponseCache(NewResponseCache(time.Minute)).Build()
+	cases := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{map[string]string{"Authorization": "alice", "Accept-Language": "zh"}, "alice|zh"},
+		{map[string]string{"Authorization": "bob", "Accept-Language": "zh"}, "bob|zh"},
+		{map[string]string{"Authorization": "alice", "Accept-Language": "en"}, "alice|en"},
+		{map[string]string{"Authorization": "alice", "Accept-Language": "zh"}, "alice|zh"},
+	}
+	for _, c := range cases {
+		if _, body := getBody(t, client, "/me", c.headers); body != c.want {
+			t.Errorf("got %q, want %q", body, c.want)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", calls)
+	}
+}
+
+// 测试 LRU 按条目数淘汰
+func TestResponseCache_LRU(t *testing.T) {
+	rc := NewResponseCache(time.Minute)
+	rc.MaxEntries = 2
+	rc.set("a", &cacheEntry{respBody: []byte("a"), status: 200})
+	rc.set("b", &cacheEntry{respBody: []byte("b"), status: 200})
+	rc.get("a") // a 变为最近使用
+	rc.set("c", &cacheEntry{respBody: []byte("c"), status: 200})
+
+	if _, ok := rc.get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok := rc.get("a"); !ok {
+		t.Error("a should be kept")
+	}
+	if _, ok := rc.get("c"); !ok {
+		t.Error("c should be kept")
+	}
+}
+
+// 测试以 store.AdapterCache 为后端
+func TestResponseCache_Store(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("shared"))
+	}))
+	defer server.Close()
+
+	backend := store.NewMemory()
+	c1, _ := NewClient(server.URL).ResponseCache(NewResponseCacheWithStore(time.Minute, backend)).Build()
+	c2, _ := NewClient(server.URL).ResponseCache(NewResponseCacheWithStore(time.Minute, backend)).Build()
+	getBody(t, c1, "/s", nil)
+	if _, body := getBody(t, c2, "/s", nil); body != "shared" {
+		t.Errorf("body=%q", body)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 upstream call, got %d", calls)
+	}
+}
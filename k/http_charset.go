@@ -0,0 +1,124 @@
+package k
+
+// http_charset.go —— 响应体字符集识别与转码（GBK / GB18030 / Big5 等 → UTF-8）
+//
+// 设计目标：
+//   - ReadBody / ReadBodyString / ReadJSON / ReadAs 读取文本响应时统一转为 UTF-8
+//   - 识别顺序：R().Charset 显式指定 → BOM → Content-Type 的 charset 参数 → HTML <meta> / XML 声明
+//   - 只处理文本类响应（text/*、JSON、XML、表单、JavaScript）或 Content-Type 带 charset 参数的响应，二进制内容原样返回；
+//     没有 Content-Type 的响应不转码，需用 R().Charset 显式指定
+//   - 字符集名称按 WHATWG 编码标准解析（gb2312 → GBK，x-gbk、cp936 等别名均可识别），无法识别时原样返回
+//
+// 示例：
+//
+//	resp, _ := client.Get("/notice")                 // Content-Type: text/html; charset=GBK
+//	text, _ := client.ReadBodyString(resp)           // 已是 UTF-8
+//
+//	resp, _ = client.Get("/legacy", R().Charset("gb18030")) // 服务端未声明字符集时显式指定
+//	err := client.ReadJSON(resp, &result)
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// charsetKey 在请求 context 中携带 R().Charset 指定的字符集，读取响应时通过 resp.Request 取回
+type charsetKey struct{}
+
+// charsetSniffLen 查找 <meta> / XML 声明时扫描的字节数
+const charsetSniffLen = 1024
+
+var (
+	metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_\-:.]+)`)
+	xmlEncodingRe = regexp.MustCompile(`(?i)<\?xml[^>]+encoding\s*=\s*["']([a-z0-9_\-:.]+)["']`)
+)
+
+// Charset 指定响应体的字符集（如 "gbk"、"gb18030"、"big5"），优先于响应头与内容中的声明。
+// 用于服务端未声明或声明错误的场景。
+func (r *RequestBuilder) Charset(name string) *RequestBuilder {
+	r.cfg.charset = name
+	return r
+}
+
+// toUTF8 按 R().Charset、BOM、Content-Type、<meta> / XML 声明识别字符集并转码为 UTF-8。
+// 非文本响应（包括未声明 Content-Type 且未指定 Charset）、UTF-8 或无法识别的字符集原样返回（UTF-8 BOM 会被去除）。
+func toUTF8(resp *http.Response, body []byte) []byte {
+	override := ""
+	if resp.Request != nil {
+		override, _ = resp.Request.Context().Value(charsetKey{}).(string)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mt, params, _ := mime.ParseMediaType(contentType)
+	if override == "" && !isTextMediaType(mt) {
+		return body
+	}
+
+	var enc encoding.Encoding
+	switch {
+	case override != "":
+		enc = lookupCharset(override)
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return body[3:]
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case params["charset"] != "":
+		enc = lookupCharset(params["charset"])
+	default:
+		enc = lookupCharset(sniffCharset(body))
+	}
+	if enc == nil || enc == unicode.UTF8 {
+		return body
+	}
+	out, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// lookupCharset 按 WHATWG 名称与别名查找编码，空名称或无法识别时返回 nil
+func lookupCharset(name string) encoding.Encoding {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+// sniffCharset 在内容开头查找 <meta charset> / <meta http-equiv> 或 <?xml encoding?> 声明
+func sniffCharset(body []byte) string {
+	head := body[:min(len(body), charsetSniffLen)]
+	if m := xmlEncodingRe.FindSubmatch(head); m != nil {
+		return string(m[1])
+	}
+	if m := metaCharsetRe.FindSubmatch(head); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// isTextMediaType 判断是否为需要处理字符集的文本类型；未声明或无法解析的类型不视为文本，
+// 避免以 FF FE / FE FF 开头的二进制内容被当作 UTF-16 解码，此时需用 R().Charset 显式指定
+func isTextMediaType(mt string) bool {
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		strings.HasSuffix(mt, "/json"), strings.HasSuffix(mt, "+json"),
+		strings.HasSuffix(mt, "/xml"), strings.HasSuffix(mt, "+xml"),
+		mt == "application/javascript", mt == MediaTypeForm:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package k
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func mustEncode(t *testing.T, s string, gbk bool) []byte {
+	t.Helper()
+	enc := simplifiedchinese.GBK.NewEncoder()
+	if !gbk {
+		enc = traditionalchinese.Big5.NewEncoder()
+	}
+	b, err := enc.Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// 测试按 Content-Type、<meta>、XML 声明、BOM 与 R().Charset 识别字符集
+func TestReadBody_Charset(t *testing.T) {
+	gbkHTML := mustEncode(t, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=gb2312"></head><body>政务公开</body></html>`, true)
+	routes := map[string]struct {
+		ct   string
+		body []byte
+	}{
+		"/header":  {"text/plain; charset=GBK", mustEncode(t, "你好，世界", true)},
+		"/meta":    {"text/html", gbkHTML},
+		"/big5":    {"application/json; charset=big5", mustEncode(t, `{"name":"臺灣銀行"}`, false)},
+		"/bom":     {"application/json", append([]byte{0xEF, 0xBB, 0xBF}, `{"name":"中文"}`...)},
+		"/guess":   {"text/plain", mustEncode(t, "未声明字符集", true)},
+		"/binary":  {"application/octet-stream", mustEncode(t, "二进制", true)},
+		"/untyped": {"", []byte{0xFF, 0xFE, 'A', 0, 'B', 0}},
+		"/invalid": {"text/ html", []byte{0xFE, 0xFF, 0, 'A'}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := routes[r.URL.Path]
+		w.Header().Set("Content-Type", rt.ct)
+		w.Write(rt.body)
+	}))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Build()
+
+	get := func(path string, rb ...*RequestBuilder) string {
+		resp, err := client.Get(path, rb...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := client.ReadBodyString(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if s := get("/header"); s != "你好，世界" {
+		t.Errorf("header charset: %q", s)
+	}
+	if s := get("/meta"); !bytes.Contains([]byte(s), []byte("政务公开")) {
+		t.Errorf("meta charset: %q", s)
+	}
+	if s := get("/guess", R().Charset("gb18030")); s != "未声明字符集" {
+		t.Errorf("override charset: %q", s)
+	}
+	if s := get("/binary"); s != string(routes["/binary"].body) {
+		t.Errorf("binary body should be untouched: %q", s)
+	}
+	// 未声明或无法解析的类型不按 BOM 解码
+	for _, path := range []string{"/untyped", "/invalid"} {
+		if s := get(path); s != string(routes[path].body) {
+			t.Errorf("%s body should be untouched: %q", path, s)
+		}
+	}
+
+	var v struct{ Name string }
+	resp, _ := client.Get("/big5")
+	if err := client.ReadJSON(resp, &v); err != nil || v.Name != "臺灣銀行" {
+		t.Errorf("big5 json: %+v %v", v, err)
+	}
+	resp, _ = client.Get("/bom")
+	if err := client.ReadJSON(resp, &v); err != nil || v.Name != "中文" {
+		t.Errorf("bom json: %+v %v", v, err)
+	}
+}
+
+// 测试 ReadAs 解码声明了 GBK 编码的 XML
+func TestReadAs_GBKXML(t *testing.T) {
+	body := mustEncode(t, `<?xml version="1.0" encoding="GBK"?><resp><msg>交易成功</msg></resp>`, true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(body)
+	}))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Build()
+
+	resp, _ := client.Get("/")
+	var v struct {
+		Msg string `xml:"msg"`
+	}
+	if err := client.ReadAs(resp, &v); err != nil || v.Msg != "交易成功" {
+		t.Errorf("xml: %+v %v", v, err)
+	}
+}
@@ -33,19 +33,14 @@ package k
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
-	"mime/multipart"
-	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -80,103 +75,6 @@ func (e *HTTPError) Error() string {
 // ErrCircuitOpen 熔断器处于开启状态时返回此错误，表示请求被拒绝
 var ErrCircuitOpen = errors.New("circuit breaker is open")
 
-// ═══════════════════════════════════════════════════════
-// Proxy Pool 代理池（新增，支持轮询/随机/健康检查）
-// ═══════════════════════════════════════════════════════
-
-type ProxyStrategy string
-
-const (
-	ProxyStrategyRandom     ProxyStrategy = "random"      // 随机选取代理
-	ProxyStrategyRoundRobin ProxyStrategy = "round_robin" // 轮询选取代理
-	ProxyStrategyWeight     ProxyStrategy = "weight"      // 加权选取代理
-)
-
-// Proxy 代理实例结构体
-type Proxy struct {
-	URL   string // 代理地址
-	alive bool   // 代理是否存活
-}
-
-// ProxyPool 代理池（并发安全）
-type ProxyPool struct {
-	mu       sync.RWMutex
-	proxies  []*Proxy
-	strategy ProxyStrategy
-	index    int // 轮询使用的游标
-}
-
-// NewProxyPool 创建代理池
-// urls: 代理地址列表
-// strategy: 代理选取策略
-func NewProxyPool(urls []string, strategy ProxyStrategy) *ProxyPool {
-	p := &ProxyPool{
-		strategy: strategy,
-		proxies:  make([]*Proxy, 0, len(urls)),
-	}
-	for _, u := range urls {
-		p.proxies = append(p.proxies, &Proxy{URL: u, alive: true})
-	}
-	rand.New(rand.NewSource(time.Now().UnixNano()))
-	return p
-}
-
-// Get 按照配置的策略获取一个可用代理
-func (p *ProxyPool) Get() (*url.URL, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	if len(p.proxies) == 0 {
-		return nil, nil
-	}
-
-	// 过滤出可用代理
-	alive := make([]*Proxy, 0)
-	for _, px := range p.proxies {
-		if px.alive {
-			alive = append(alive, px)
-		}
-	}
-	if len(alive) == 0 {
-		return nil, errors.New("no alive proxy in pool")
-	}
-
-	var target *Proxy
-	switch p.strategy {
-	case ProxyStrategyRandom:
-		target = alive[rand.Intn(len(alive))]
-	case ProxyStrategyRoundRobin:
-		target = alive[p.index%len(alive)]
-		p.index++
-	default:
-		target = alive[0]
-	}
-
-	return url.Parse(strings.TrimSpace(target.URL))
-}
-
-// MarkDown 标记某个代理下线
-func (p *ProxyPool) MarkDown(proxyURL string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for _, px := range p.proxies {
-		if px.URL == proxyURL {
-			px.alive = false
-		}
-	}
-}
-
-// MarkUp 标记某个代理上线
-func (p *ProxyPool) MarkUp(proxyURL string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for _, px := range p.proxies {
-		if px.URL == proxyURL {
-			px.alive = true
-		}
-	}
-}
-
 // ═══════════════════════════════════════════════════════
 // ClientBuilder —— 客户端构建器
 // ═══════════════════════════════════════════════════════
@@ -189,6 +87,7 @@ func (p *ProxyPool) MarkUp(proxyURL string) {
 //   - Timeout:          50s
 //   - HandshakeTimeout: 10s
 //   - ResponseTimeout:  10s
+//   - DialTimeout:      30s
 //   - KeepAlive:        30s
 //   - MaxIdleConns:     100
 //   - MaxConnsPerHost:  10
@@ -198,25 +97,43 @@ type ClientBuilder struct {
 	timeout          time.Duration
 	handshakeTimeout time.Duration
 	responseTimeout  time.Duration
+	dialTimeout      time.Duration
 	keepAlive        time.Duration
 	maxIdleConns     int
 	maxConnsPerHost  int
 	idleConnTimeout  time.Duration
 	tlsConfig        *tls.Config
+	tlsOptions       *TLSOptions
 	proxyURL         string     // 单个代理地址
 	proxyPool        *ProxyPool // 代理池（新增）
 	compressed       bool
+	decompressors    map[string]DecompressorFunc
+	maxBodySize      int64
+	transport        http.RoundTripper // 自定义底层传输，非 nil 时替代内置 Transport
+	dialFn           DialFunc          // 自定义拨号函数，见 DialContext
+	unixSocket       string            // Unix Socket 路径，非空时所有连接经此 Socket
+	h2c              bool
+	dnsCache         *DNSCache
+	fallbackDelay    time.Duration // Happy Eyeballs 回退延迟
 	checkRedirect    func(*http.Request, []*http.Request) error
+	cookieJar        http.CookieJar
 	bearerTokenFn    func() string
 	basicUsername    string
 	basicPassword    string
+	oauth2           *OAuth2TokenSource
 	signFn           func(*http.Request) error
 	logger           func(format string, args ...any)
+	slog             *requestLogger
 	metrics          *Metrics
+	collector        *MetricsCollector
 	circuitBreaker   *CircuitBreaker
-	rateLimiter      *RateLimiter
+	breakerGroup     *BreakerGroup
+	rateLimiter      Limiter
+	hedge            *HedgePolicy
+	balancer         *LoadBalancer
 	responseCache    *ResponseCache
 	retryOpts        []Option
+	hooks            clientHooks
 }
 
 // NewClient 创建 ClientBuilder。
@@ -235,6 +152,7 @@ func NewClient(baseURL string) *ClientBuilder {
 		timeout:          50 * time.Second,
 		handshakeTimeout: 10 * time.Second,
 		responseTimeout:  10 * time.Second,
+		dialTimeout:      30 * time.Second,
 		keepAlive:        30 * time.Second,
 		maxIdleConns:     100,
 		maxConnsPerHost:  10,
@@ -332,6 +250,7 @@ func (b *ClientBuilder) IdleConnTimeout(d time.Duration) *ClientBuilder {
 
 // TLS 配置 HTTPS 的 TLS 参数。
 // 常用场景：跳过证书校验（测试环境）、指定客户端证书（mTLS）。
+// 从 PEM 文件加载证书、公钥固定、证书热加载见 TLSOptions。
 //
 // 参数：
 //   - cfg: *tls.Config，为 nil 时使用系统默认配置。
@@ -344,14 +263,28 @@ func (b *ClientBuilder) TLS(cfg *tls.Config) *ClientBuilder {
 	return b
 }
 
-// Proxy 设置单个 HTTP 代理服务器地址。
+// Proxy 设置单个代理服务器地址，支持 http / https / socks5，未写协议时按 http 处理。
 func (b *ClientBuilder) Proxy(proxyURL string) *ClientBuilder {
 	b.proxyURL = proxyURL
 	b.proxyPool = nil // 与代理池互斥
 	return b
 }
 
-// ProxyPool 设置代理池，支持多代理自动轮换（新增）
+// Transport 替换底层 http.RoundTripper，常用于注入 httpmock 等测试替身或自定义传输层。
+// 设置后内置 Transport 不再创建，连接池、TLS、代理、压缩等传输层配置均不生效；
+// 鉴权、签名、限速、熔断、重试、缓存、日志、指标等客户端流水线仍照常执行。
+//
+// 示例：
+//
+//	mock := httpmock.New()
+//	client, _ := NewClient("https://api.example.com").Transport(mock).Build()
+func (b *ClientBuilder) Transport(rt http.RoundTripper) *ClientBuilder {
+	b.transport = rt
+	return b
+}
+
+// ProxyPool 设置代理池，支持多代理自动轮换。
+// 经代理发送失败（网络错误）会被动记录到代理池，连续失败达到 pool.MaxFails 时自动下线该代理。
 func (b *ClientBuilder) ProxyPool(pool *ProxyPool) *ClientBuilder {
 	b.proxyPool = pool
 	b.proxyURL = "" // 与单个代理互斥
@@ -359,8 +292,8 @@ func (b *ClientBuilder) ProxyPool(pool *ProxyPool) *ClientBuilder {
 }
 
 // Compression 启用请求/响应的 gzip/deflate 压缩。
-// 启用后请求头自动添加 Accept-Encoding: deflate, gzip，
-// ReadBody 会自动解压 gzip 响应体。
+// 启用后请求头自动添加 Accept-Encoding: deflate, gzip（及 Decompressor 注册的编码），
+// ReadBody 会自动解压响应体，解压后的大小受 MaxBodySize 限制。
 func (b *ClientBuilder) Compression() *ClientBuilder {
 	b.compressed = true
 	return b
@@ -395,6 +328,7 @@ func (b *ClientBuilder) BearerToken(fn func() string) *ClientBuilder {
 	b.bearerTokenFn = fn
 	b.basicUsername = ""
 	b.basicPassword = ""
+	b.oauth2 = nil
 	return b
 }
 
@@ -408,17 +342,35 @@ func (b *ClientBuilder) BasicAuth(username, password string) *ClientBuilder {
 	b.basicUsername = username
 	b.basicPassword = password
 	b.bearerTokenFn = nil
+	b.oauth2 = nil
+	return b
+}
+
+// OAuth2 设置 OAuth2 令牌源鉴权，与 BearerToken / BasicAuth 互斥，后调用的生效。
+// 每次请求从 src 获取缓存的令牌写入 Authorization 头；响应为 401 时强制刷新令牌并重发一次
+// （请求体不可重放时不重发）。
+//
+// 参数：
+//   - src: 由 NewClientCredentialsSource / NewRefreshTokenSource 创建的令牌源，可在多个客户端间共享
+func (b *ClientBuilder) OAuth2(src *OAuth2TokenSource) *ClientBuilder {
+	b.oauth2 = src
+	b.bearerTokenFn = nil
+	b.basicUsername = ""
+	b.basicPassword = ""
 	return b
 }
 
-// Sign 设置请求签名钩子，在鉴权头写入之后、请求发送之前执行。
-// 适合需要对完整请求内容（含 header）计算签名的场景，如 AWS Signature、HMAC-SHA256。
+// Sign 设置请求签名钩子，在鉴权头写入之后、每次发送（含重试）之前执行。
+// 适合需要对完整请求内容（含 header）计算签名的场景，内置实现见 HMACSigner、AWSSigV4Signer、ParamsSigner。
 //
 // 参数：
 //   - fn: 接收 *http.Request 并向其添加签名相关 header，返回 error 时请求终止。
+//     需要读取请求体时使用 req.GetBody（请求有 body 时总会设置），不要直接消费 req.Body。
 //
 // 示例：
 //
+//	.Sign(HMACSigner("ak", "sk"))
+//
 //	.Sign(func(req *http.Request) error {
 //	    sig := hmac.Sign(req)
 //	    req.Header.Set("X-Signature", sig)
@@ -432,7 +384,8 @@ func (b *ClientBuilder) Sign(fn func(*http.Request) error) *ClientBuilder {
 // ─── 可观测 ────────────────────────────────────────────
 
 // Logger 设置请求日志函数。
-// 每次请求完成后（无论成功或失败）自动打印方法、URL、状态码和耗时。
+// 每次请求完成后（无论成功或失败）自动打印方法、URL、状态码和耗时，URL 中的敏感 query 参数会脱敏。
+// 需要结构化字段、请求 / 响应体或逐次尝试日志时使用 Slog。
 //
 // 参数：
 //   - fn: 日志函数，签名与 fmt.Printf / log.Printf 兼容。
@@ -463,36 +416,73 @@ func (b *ClientBuilder) Metrics(m *Metrics) *ClientBuilder {
 	return b
 }
 
+// MetricsCollector 注入带标签的指标收集器，按 method/host/route/code 统计请求数、
+// 耗时直方图、重试次数和缓存命中率，可通过 mc.Handler() 以 Prometheus 格式暴露。
+// 可与 Metrics 同时使用，互不影响。
+//
+// 参数：
+//   - mc: *MetricsCollector 实例，通过 NewMetricsCollector() 创建。
+//
+// 示例：
+//
+//	mc := NewMetricsCollector()
+//	client, _ := NewClient("...").MetricsCollector(mc).Build()
+//	http.Handle("/metrics", mc.Handler())
+func (b *ClientBuilder) MetricsCollector(mc *MetricsCollector) *ClientBuilder {
+	b.collector = mc
+	return b
+}
+
 // ─── 可靠性 ────────────────────────────────────────────
 
-// CircuitBreaker 注入熔断器。
-// 连续失败超过阈值后自动开启熔断，拒绝后续请求（返回 ErrCircuitOpen），
-// 经过冷却期后进入半开状态探测，连续成功后自动恢复。
+// CircuitBreaker 注入熔断器，客户端的所有请求共享同一个熔断器。
+// 连续失败或窗口失败率 / 慢调用率超过阈值后自动开启熔断，拒绝后续请求（返回 ErrCircuitOpen），
+// 经过冷却期后进入半开状态探测，连续成功后自动恢复。与 BreakerGroup 互斥，后调用的生效。
 //
 // 参数：
 //   - cb: *CircuitBreaker 实例，通过 NewCircuitBreaker() 创建。
-//     创建后可修改 MaxFailures / OpenTimeout / HalfOpenSuccesses 字段自定义阈值。
+//     创建后可修改 MaxFailures / OpenTimeout / WindowSize / FailureRateThreshold 等字段自定义阈值。
 func (b *ClientBuilder) CircuitBreaker(cb *CircuitBreaker) *ClientBuilder {
 	b.circuitBreaker = cb
+	b.breakerGroup = nil
 	return b
 }
 
-// RateLimiter 注入令牌桶限速器。
-// 请求在获取令牌之前会阻塞等待，context 取消时立即返回错误。
+// BreakerGroup 注入熔断器组，按 host 或 route 为每个后端使用独立的熔断器。
+// 与 CircuitBreaker 互斥，后调用的生效。
 //
 // 参数：
-//   - rl: *RateLimiter 实例，通过 NewRateLimiter(rps, burst) 创建。
-func (b *ClientBuilder) RateLimiter(rl *RateLimiter) *ClientBuilder {
+//   - g: 通过 NewBreakerGroup 创建
+//
+// 示例：
+//
+//	.BreakerGroup(NewBreakerGroup(BreakerPerHost, nil))
+func (b *ClientBuilder) BreakerGroup(g *BreakerGroup) *ClientBuilder {
+	b.breakerGroup = g
+	b.circuitBreaker = nil
+	return b
+}
+
+// RateLimiter 注入限速器。
+// 请求在获取许可之前会阻塞等待，context 取消时立即返回错误。
+//
+// 参数：
+//   - rl: Limiter 实现：NewRateLimiter(rps, burst) 进程内令牌桶；
+//     NewCacheRateLimiter 多副本共享配额；NewHostLimiter / NewKeyedLimiter 按 host 或 key 分组。
+func (b *ClientBuilder) RateLimiter(rl Limiter) *ClientBuilder {
+	if r, ok := rl.(*RateLimiter); ok && r == nil {
+		rl = nil // 兼容传入 (*RateLimiter)(nil) 表示不限速
+	}
 	b.rateLimiter = rl
 	return b
 }
 
-// ResponseCache 注入内存响应缓存。
-// 仅缓存 GET 请求且状态码为 200 的响应，其他方法和状态码直接透传。
-// 缓存命中时直接返回缓存内容，不经过熔断、限速、重试等环节。
+// ResponseCache 注入响应缓存，遵循 HTTP 缓存语义（Cache-Control / ETag / Vary）。
+// 仅缓存 GET 请求，新鲜的缓存命中时直接返回，不经过熔断、限速、重试等环节；
+// 过期但带验证器的条目会发送条件请求，服务端返回 304 时复用缓存内容并以 200 返回给调用方。
 //
 // 参数：
-//   - rc: *ResponseCache 实例，通过 NewResponseCache(ttl) 创建。
+//   - rc: *ResponseCache 实例，通过 NewResponseCache(ttl) 或 NewResponseCacheWithStore(ttl, s) 创建。
 func (b *ClientBuilder) ResponseCache(rc *ResponseCache) *ClientBuilder {
 	b.responseCache = rc
 	return b
@@ -525,17 +515,25 @@ func (b *ClientBuilder) Retry(opts ...Option) *ClientBuilder {
 // 校验失败（如 baseURL 格式非法、代理地址无法解析）时返回 error。
 // 构建成功的 HTTPClient 可安全地被多个 goroutine 并发使用。
 func (b *ClientBuilder) Build() (*HTTPClient, error) {
+	if err := b.parseUnixBaseURL(); err != nil {
+		return nil, err
+	}
 	if b.baseURL != "" {
 		if _, err := url.ParseRequestURI(b.baseURL); err != nil {
 			return nil, fmt.Errorf("invalid baseURL %q: %w", b.baseURL, err)
 		}
 	}
+	if b.balancer != nil {
+		if b.baseURL != "" {
+			return nil, errors.New("baseURL must be empty when LoadBalancer is set")
+		}
+		if err := b.balancer.validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: b.keepAlive,
-		}).DialContext,
+		DialContext:           b.dialer(),
 		TLSHandshakeTimeout:   b.handshakeTimeout,
 		ResponseHeaderTimeout: b.responseTimeout,
 		DisableCompression:    !b.compressed,
@@ -548,30 +546,51 @@ func (b *ClientBuilder) Build() (*HTTPClient, error) {
 	if b.tlsConfig != nil {
 		transport.TLSClientConfig = b.tlsConfig
 	}
+	var caReloader *certReloader
+	if b.tlsOptions != nil {
+		cfg, reloader, err := b.tlsOptions.build(b.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig, caReloader = cfg, reloader
+	}
 
 	// 代理池优先于单个代理
 	if b.proxyPool != nil {
-		transport.Proxy = func(_ *http.Request) (*url.URL, error) {
-			return b.proxyPool.Get()
-		}
+		transport.Proxy = b.proxyPool.proxyFunc()
 	} else if b.proxyURL != "" {
-		raw := b.proxyURL
-		if !strings.HasPrefix(raw, "http") {
-			raw = "http://" + raw
-		}
-		proxy, err := url.Parse(strings.TrimSpace(raw))
+		proxy, err := parseProxyURL(b.proxyURL)
 		if err != nil {
 			return nil, fmt.Errorf("invalid proxy URL: %w", err)
 		}
 		transport.Proxy = http.ProxyURL(proxy)
 	}
 
+	var rt http.RoundTripper = transport
+	if caReloader != nil {
+		rt = &caReloadTransport{r: caReloader, cur: transport}
+	}
+	if b.h2c && transport.Proxy == nil {
+		rt = newH2CTransport(transport, rt)
+	}
+	if b.transport != nil {
+		rt = b.transport
+	}
+
 	return &HTTPClient{
 		builder: b,
+		codecs:  newCodecRegistry(),
 		raw: &http.Client{
 			Timeout:       b.timeout,
-			Transport:     transport,
+			Transport:     rt,
+			CheckRedirect: b.checkRedirect,
+			Jar:           b.cookieJar,
+		},
+		// 流式请求（下载等）共享同一个 Transport，但不设整体超时，由 context 控制
+		stream: &http.Client{
+			Transport:     rt,
 			CheckRedirect: b.checkRedirect,
+			Jar:           b.cookieJar,
 		},
 	}, nil
 }
@@ -585,6 +604,8 @@ func (b *ClientBuilder) Build() (*HTTPClient, error) {
 type HTTPClient struct {
 	builder *ClientBuilder
 	raw     *http.Client
+	stream  *http.Client // 无整体超时，供读取时间不可预估的流式响应使用
+	codecs  *codecRegistry
 }
 
 // ═══════════════════════════════════════════════════════
@@ -593,12 +614,26 @@ type HTTPClient struct {
 
 // requestConfig 存储单次请求的可选参数
 type requestConfig struct {
-	ctx          context.Context
-	headers      map[string]string
-	queryParams  map[string]string
-	formData     url.Values
-	file         *File
-	expectStatus []int
+	ctx            context.Context
+	headers        map[string]string
+	queryParams    map[string]string
+	formData       url.Values
+	files          []*File
+	uploadProgress ProgressFunc
+	expectStatus   []int
+	route          string // 指标中的 route 标签，为空时取 URL path
+	stream         bool   // 流式响应：不受客户端 Timeout 限制，且不经过响应缓存
+	hedge          *HedgePolicy
+	hedgeSet       bool   // 为 true 时以 hedge 覆盖客户端的对冲策略（可为 nil 表示关闭）
+	hashKey        string // 一致性哈希负载均衡使用的 key
+	path           string // 调用方传入的相对路径（不含 query），负载均衡时拼接到所选端点
+	accept         string // R().Accept 生成的 Accept 头
+	charset        string // R().Charset 指定的响应字符集
+	maxBodySize    int64  // 响应体大小上限，0 使用客户端设置，< 0 不限制
+	attempts       int    // 实际尝试次数（execute 累加），供完成钩子使用
+
+	// finalServerErr 返回 true 时 5xx 作为响应返回、不重试（JSON-RPC 以 HTTP 500 携带 error 对象），须保持 resp.Body 可读
+	finalServerErr func(*http.Response) bool
 }
 
 // RequestBuilder 构建单次请求的可选参数，通过 R() 创建后链式调用。
@@ -617,6 +652,27 @@ func R() *RequestBuilder {
 	return &RequestBuilder{}
 }
 
+// clone 复制一份请求配置，header / query map 深拷贝，修改副本不影响调用方持有的 RequestBuilder
+func (r *RequestBuilder) clone() *RequestBuilder {
+	cp := R()
+	if r == nil {
+		cp.cfg.headers = make(map[string]string)
+		return cp
+	}
+	cp.cfg = r.cfg
+	cp.cfg.headers = make(map[string]string, len(r.cfg.headers))
+	for k, v := range r.cfg.headers {
+		cp.cfg.headers[k] = v
+	}
+	if r.cfg.queryParams != nil {
+		cp.cfg.queryParams = make(map[string]string, len(r.cfg.queryParams))
+		for k, v := range r.cfg.queryParams {
+			cp.cfg.queryParams[k] = v
+		}
+	}
+	return cp
+}
+
 // Context 为本次请求注入 context，用于控制超时或取消。
 // 注入的 context 优先于客户端的 Timeout 设置。
 //
@@ -656,7 +712,7 @@ func (r *RequestBuilder) QueryParams(p map[string]string) *RequestBuilder {
 
 // FormData 设置 application/x-www-form-urlencoded 表单数据。
 // 设置后会覆盖请求体，并自动设置 Content-Type。
-// 若同时设置了 File，则改用 multipart/form-data 格式。
+// 若同时设置了 File / Files，则改用 multipart/form-data 格式。
 //
 // 参数：
 //   - v: url.Values 类型的表单字段，支持同一字段多个值。
@@ -665,8 +721,8 @@ func (r *RequestBuilder) FormData(v url.Values) *RequestBuilder {
 	return r
 }
 
-// File 设置文件上传，必须配合 FormData 使用（即使表单字段为空也需传 url.Values{}）。
-// 设置后请求自动切换为 multipart/form-data 格式。
+// File 设置单个文件上传，可配合 FormData 附带表单字段。
+// 设置后请求自动切换为 multipart/form-data 格式，请求体流式发送，不会整体读入内存。
 //
 // 参数：
 //   - f: *File 描述上传文件的元信息和内容来源（Content 字节或 Path 路径）。
@@ -679,7 +735,16 @@ func (r *RequestBuilder) FormData(v url.Values) *RequestBuilder {
 //	    Path:      "/tmp/photo.png",
 //	}, url.Values{"desc": {"profile photo"}})
 func (r *RequestBuilder) File(f *File) *RequestBuilder {
-	r.cfg.file = f
+	r.cfg.files = []*File{f}
+	return r
+}
+
+// Files 设置多个文件在同一个 multipart 请求中上传，会覆盖之前 File / Files 的设置。
+//
+// 参数：
+//   - files: 一个或多个 *File，FieldName 可以重复（服务端按数组接收）。
+func (r *RequestBuilder) Files(files ...*File) *RequestBuilder {
+	r.cfg.files = files
 	return r
 }
 
@@ -699,44 +764,85 @@ func (r *RequestBuilder) ExpectStatus(codes ...int) *RequestBuilder {
 	return r
 }
 
+// Route 设置本次请求在 MetricsCollector 中的 route 标签。
+// 未设置时取实际 URL path；路径中包含 ID 等变量时应显式指定模板，避免标签基数膨胀。
+//
+// 参数：
+//   - route: 路由模板，例如 "/users/:id"。
+//
+// 示例：
+//
+//	client.Get("/users/"+id, R().Route("/users/:id"))
+func (r *RequestBuilder) Route(route string) *RequestBuilder {
+	r.cfg.route = route
+	return r
+}
+
 // ═══════════════════════════════════════════════════════
 // 核心执行（内部方法）
 // ═══════════════════════════════════════════════════════
 
 // buildURL 将相对路径拼接到 baseURL。
 // 空 path 返回 baseURL；空 baseURL 返回原始 path；否则确保中间只有一个 "/"。
+// 使用负载均衡时以第一个端点作为 baseURL，实际端点在每次尝试前选取。
 func (c *HTTPClient) buildURL(path string) string {
+	baseURL := c.builder.baseURL
+	if c.builder.balancer != nil {
+		baseURL = c.builder.balancer.endpoints[0].base
+	}
 	if path == "" {
-		return c.builder.baseURL
+		return baseURL
 	}
-	if c.builder.baseURL == "" {
+	if baseURL == "" {
 		return path
 	}
-	return c.builder.baseURL + "/" + strings.TrimLeft(path, "/")
+	return baseURL + "/" + strings.TrimLeft(path, "/")
 }
 
 // do 构建 *http.Request 并委托给 execute 执行。
 // 负责：URL 拼接、默认 header、query params、form data / 文件上传、鉴权、签名、状态码校验。
-func (c *HTTPClient) do(method, path string, body io.Reader, contentType string, rb *RequestBuilder) (*http.Response, error) {
+func (c *HTTPClient) do(method, path string, body io.Reader, contentType string, rb *RequestBuilder) (resp *http.Response, err error) {
 	var cfg requestConfig
 	if rb != nil {
 		cfg = rb.cfg
 	}
 
+	// 完成钩子覆盖所有返回路径，包括请求构建、鉴权与 OnRequest 失败
+	var req *http.Request
+	if hooks := &c.builder.hooks; len(hooks.onError)+len(hooks.onComplete) > 0 {
+		start := time.Now()
+		defer func() {
+			if resp != nil && !cfg.stream {
+				bufferBody(resp)
+			}
+			hooks.complete(&HookEvent{Request: req, Response: resp, Err: err, Attempt: cfg.attempts, Duration: time.Since(start)})
+		}()
+	}
+	cfg.path, _, _ = strings.Cut(path, "?")
+
 	ctx := cfg.ctx
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if cfg.charset != "" {
+		ctx = context.WithValue(ctx, charsetKey{}, cfg.charset) // 读取响应时经 resp.Request 取回
+	}
+	if limit := c.bodyLimit(&cfg); limit > 0 {
+		ctx = context.WithValue(ctx, bodyLimitKey{}, limit)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.buildURL(path), body)
+	req, err = http.NewRequestWithContext(ctx, method, c.buildURL(path), body)
 	if err != nil {
 		return nil, err
 	}
 
 	// 默认 header
 	req.Header.Set("Accept", "*/*")
+	if cfg.accept != "" {
+		req.Header.Set("Accept", cfg.accept)
+	}
 	if c.builder.compressed {
-		req.Header.Set("Accept-Encoding", "deflate, gzip")
+		req.Header.Set("Accept-Encoding", c.builder.acceptEncoding())
 	}
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
@@ -756,23 +862,32 @@ func (c *HTTPClient) do(method, path string, body io.Reader, contentType string,
 	}
 
 	// form data / 文件上传
-	if cfg.formData != nil {
-		if cfg.file != nil {
-			// 有文件时使用 multipart/form-data
-			if err = attachFile(req, cfg.formData, cfg.file); err != nil {
-				return nil, err
-			}
-		} else {
-			// 纯表单使用 application/x-www-form-urlencoded
-			encoded := []byte(cfg.formData.Encode())
-			req.Body = io.NopCloser(bytes.NewReader(encoded))
-			req.ContentLength = int64(len(encoded))
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(cfg.files) > 0 {
+		// 有文件时使用流式 multipart/form-data
+		if err = attachFiles(req, cfg.formData, cfg.files, cfg.uploadProgress); err != nil {
+			return nil, err
 		}
+	} else if cfg.formData != nil {
+		// 纯表单使用 application/x-www-form-urlencoded
+		encoded := []byte(cfg.formData.Encode())
+		req.Body = io.NopCloser(bytes.NewReader(encoded))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(encoded)), nil
+		}
+		req.ContentLength = int64(len(encoded))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
-	// 鉴权（BearerToken 优先于 BasicAuth）
-	if c.builder.bearerTokenFn != nil {
+	// 鉴权（OAuth2 / BearerToken / BasicAuth 三者互斥）
+	var oauthToken string
+	if c.builder.oauth2 != nil {
+		tok, err := c.builder.oauth2.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", tok.authorization())
+		oauthToken = tok.AccessToken
+	} else if c.builder.bearerTokenFn != nil {
 		if token := c.builder.bearerTokenFn(); token != "" {
 			req.Header.Set("Authorization", "Bearer "+token)
 		}
@@ -780,18 +895,41 @@ func (c *HTTPClient) do(method, path string, body io.Reader, contentType string,
 		req.SetBasicAuth(c.builder.basicUsername, c.builder.basicPassword)
 	}
 
-	// 签名（在鉴权之后执行，可对完整 header 签名）
-	if c.builder.signFn != nil {
-		if err = c.builder.signFn(req); err != nil {
-			return nil, fmt.Errorf("sign request: %w", err)
-		}
+	if err = c.builder.hooks.request(req); err != nil {
+		return nil, err
 	}
+	return c.roundTrip(req, &cfg, oauthToken)
+}
 
-	resp, err := c.execute(req)
+// roundTrip 执行请求并处理 OAuth2 令牌失效重发与状态码白名单校验
+func (c *HTTPClient) roundTrip(req *http.Request, cfg *requestConfig, oauthToken string) (*http.Response, error) {
+	ctx := req.Context()
+	resp, err := c.execute(req, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	// OAuth2：令牌被服务端拒绝（提前吊销、时钟偏差等）时强制刷新并重发一次
+	if resp.StatusCode == http.StatusUnauthorized && c.builder.oauth2 != nil &&
+		(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil) {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.builder.oauth2.Invalidate(oauthToken)
+		tok, err := c.builder.oauth2.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", tok.authorization())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if resp, err = c.execute(req, cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	// 状态码白名单校验
 	if len(cfg.expectStatus) > 0 {
 		match := false
@@ -812,75 +950,194 @@ func (c *HTTPClient) do(method, path string, body io.Reader, contentType string,
 }
 
 // execute 按固定顺序执行所有可靠性能力，顺序不可更改：
-//  1. 缓存命中（仅 GET）→ 直接返回，跳过后续所有步骤
-//  2. 熔断检查          → 开启时返回 ErrCircuitOpen
+//  1. 缓存命中（仅 GET）→ 新鲜时直接返回，跳过后续所有步骤；过期时附加条件请求头
+//  2. 熔断检查          → 开启时返回 ErrCircuitOpen；负载均衡时 BreakerGroup 改为每次尝试按所选端点检查
 //  3. 限速等待          → 令牌不足时阻塞，context 取消时返回错误
-//  4. body 缓存         → 读取并缓存请求体字节，供重试时重放
+//  4. body 重放         → 优先使用 GetBody，否则读取并缓存请求体字节，供重试时重放
 //  5. 发送请求（含重试）→ 复用 retry.go 的 RetryWithContext
 //  6. 记录日志
-//  7. 更新指标
-//  8. 更新熔断状态
-//  9. 写入响应缓存（仅 GET 200）
-func (c *HTTPClient) execute(req *http.Request) (*http.Response, error) {
+//  7. 更新指标（Metrics / MetricsCollector）
+//  8. 更新熔断状态（按端点检查的 BreakerGroup 在每次尝试后记录）
+//  9. 写入响应缓存（304 时复用缓存内容，其余按 Cache-Control 判断是否可缓存）
+func (c *HTTPClient) execute(req *http.Request, cfg *requestConfig) (*http.Response, error) {
 	b := c.builder
 
-	// ① 缓存命中（仅 GET）
-	if b.responseCache != nil && req.Method == http.MethodGet {
-		if cached, ok := b.responseCache.get(req.URL.String()); ok {
-			return &http.Response{
-				StatusCode: cached.status,
-				Status:     http.StatusText(cached.status),
-				Header:     cached.headers.Clone(),
-				Body:       io.NopCloser(bytes.NewReader(cached.respBody)),
-				Request:    req,
-			}, nil
+	// ① 缓存命中（仅 GET，流式响应及请求声明 no-store 时除外）
+	useCache := b.responseCache != nil && req.Method == http.MethodGet && !cfg.stream
+	if useCache {
+		_, noStore := parseCacheControl(req.Header.Values("Cache-Control"))["no-store"]
+		useCache = !noStore
+	}
+	var (
+		stale *cacheEntry // 需要重新验证的缓存条目
+		key   string      // 发送前计算的缓存键，写回时沿用（响应可能改变 Jar 中的 Cookie）
+	)
+	if useCache {
+		// 缓存在选择端点前查找：负载均衡时键为逻辑 URL（首个端点），各副本共享同一缓存条目
+		key = cacheKey(req, c.raw.Jar)
+		cached, fresh := b.responseCache.lookup(key, req)
+		if b.collector != nil {
+			b.collector.observeCache(req.URL.Host, fresh)
+		}
+		if fresh {
+			return cached.response(req), nil
+		}
+		if cached != nil && cached.hasValidator() {
+			stale = cached
+			stale.addConditionalHeaders(req)
 		}
 	}
 
-	// ② 熔断检查
-	if b.circuitBreaker != nil && !b.circuitBreaker.Allow() {
+	// ② 熔断检查；负载均衡时 BreakerGroup 按每次尝试所选端点检查（见 operationFn）
+	breaker := b.circuitBreaker
+	endpointBreakers := b.breakerGroup != nil && b.balancer != nil
+	if b.breakerGroup != nil && !endpointBreakers {
+		breaker = b.breakerGroup.forRequest(req, cfg.route)
+	}
+	if breaker != nil && !breaker.Allow() {
 		return nil, ErrCircuitOpen
 	}
 
 	// ③ 限速
 	if b.rateLimiter != nil {
-		if err := b.rateLimiter.Wait(req.Context()); err != nil {
+		if err := b.rateLimiter.Acquire(req); err != nil {
+			if breaker != nil {
+				breaker.release() // 请求未发出，归还半开探测名额
+			}
 			return nil, fmt.Errorf("rate limiter: %w", err)
 		}
 	}
 
-	// ④ 缓存请求 body，供重试时重放（http.Request.Body 只能读一次）
-	var bodyBytes []byte
-	if req.Body != nil && req.Body != http.NoBody {
-		var err error
-		bodyBytes, err = io.ReadAll(req.Body)
+	// ④ 请求 body 重放（http.Request.Body 只能读一次）
+	//    已设置 GetBody 的请求（bytes/strings Reader、表单、流式 multipart）每次重试重新获取 body；
+	//    其余任意 io.Reader 读取并缓存字节后补上 GetBody
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		bodyBytes, err := io.ReadAll(req.Body)
 		req.Body.Close()
 		if err != nil {
+			if breaker != nil {
+				breaker.release() // 请求未发出，归还半开探测名额
+			}
 			return nil, err
 		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(bodyBytes)), nil
+		}
+		req.Body, _ = req.GetBody()
 	}
 
 	// ⑤ 发送请求（含重试）
 	//    operationFn 内部：
 	//      - 网络错误 / 5xx（非 501）→ 返回普通 error，RetryWithContext 会重试
-	//      - 4xx / 2xx / 501        → 返回 NonRetryable，RetryWithContext 立即终止
+	//      - 4xx / 2xx / 501        → 返回响应且 error 为 nil，RetryWithContext 立即结束
 	var finalResp *http.Response
+	attempts := 0
 	start := time.Now()
+	route := cfg.route // 指标中的 route 标签
+	if route == "" {
+		route = req.URL.Path
+	}
+
+	host := req.URL.Host // 指标中的 host 标签，负载均衡时为最后一次尝试的端点
+
+	tried := make(map[*endpoint]bool) // 负载均衡：本次请求已尝试过的端点
+	var lastAttempt *HookEvent        // 上一次尝试，重试前传给 OnRetry
 
 	operationFn := func(args ...any) (any, error) {
-		if bodyBytes != nil {
-			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		attempts++
+		if lastAttempt != nil {
+			b.hooks.retry(lastAttempt)
+		}
+		if attempts > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, NonRetryable(err) // 无法重放 body（如文件已被删除），终止重试
+			}
+			req.Body = body
+		}
+		// 负载均衡：选取端点后改写本次尝试的 URL，签名须在改写之后
+		attemptReq := req
+		var (
+			ep             *endpoint
+			attemptBreaker *CircuitBreaker // 所选端点在 BreakerGroup 中的熔断器
+		)
+		if b.balancer != nil {
+			var err error
+			if attemptReq, ep, err = c.balancedRequest(req, cfg, tried); err != nil {
+				return nil, NonRetryable(err)
+			}
+			host = attemptReq.URL.Host
+			if endpointBreakers {
+				if attemptBreaker = b.breakerGroup.forRequest(attemptReq, cfg.route); !attemptBreaker.Allow() {
+					b.balancer.release(ep)
+					return nil, ErrCircuitOpen // 该端点已熔断，重试时换用其他端点
+				}
+			}
+		}
+		// 签名在每次尝试前执行（鉴权头已写入，可对完整 header 签名），重试时刷新时间戳 / nonce
+		if b.signFn != nil {
+			if err := b.signFn(attemptReq); err != nil {
+				if ep != nil {
+					b.balancer.release(ep)
+				}
+				if attemptBreaker != nil {
+					attemptBreaker.release()
+				}
+				return nil, NonRetryable(fmt.Errorf("sign request: %w", err))
+			}
+		}
+		raw := c.raw
+		if cfg.stream {
+			raw = c.stream
+		}
+		var (
+			resp *http.Response
+			err  error
+		)
+		attemptStart := time.Now()
+		if b.collector != nil {
+			b.collector.inFlight(attemptReq.URL.Host, 1)
+		}
+		if hedge := cfg.hedgePolicy(b); hedge.eligible(attemptReq) {
+			resp, err = c.sendHedged(raw, attemptReq, hedge, route)
+		} else {
+			resp, err = c.send(raw, attemptReq)
 		}
-		resp, err := c.raw.Do(req)
+		if b.collector != nil {
+			b.collector.inFlight(attemptReq.URL.Host, -1)
+		}
+		err = asTLSError(attemptReq.URL.Host, err)
+		if err == nil {
+			limitBody(resp) // 之后的钩子、日志、缓存、调用方读取都受响应体大小限制
+			if b.hooks.observesBody() && !cfg.stream {
+				bufferBody(resp)
+			}
+		}
+		if ep != nil {
+			b.balancer.done(ep, attemptReq, resp, err, time.Since(attemptStart))
+		}
+		if attemptBreaker != nil {
+			attemptBreaker.record(err != nil || resp.StatusCode >= 500, time.Since(attemptStart))
+		}
+		if b.slog != nil {
+			b.slog.logAttempt(attemptReq, attempts, resp, err, time.Since(attemptStart))
+		}
+		lastAttempt = &HookEvent{Request: attemptReq, Response: resp, Err: err, Attempt: attempts, Duration: time.Since(attemptStart)}
+		b.hooks.response(lastAttempt)
 		if err != nil {
+			var tlsErr *TLSError
+			if errors.As(err, &tlsErr) && tlsErr.Verification {
+				return nil, NonRetryable(err) // 证书校验失败，重试无意义
+			}
 			return nil, err // 网络错误，触发重试
 		}
-		if resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented {
+		if resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented &&
+			(cfg.finalServerErr == nil || !cfg.finalServerErr(resp)) {
 			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
 			return nil, fmt.Errorf("server error: %s", resp.Status) // 5xx，触发重试
 		}
-		return resp, NonRetryable(nil) // 其余状态码，立即终止
+		return resp, nil // 其余状态码，直接返回给调用方
 	}
 
 	successFn := func(data any) {
@@ -893,24 +1150,29 @@ func (c *HTTPClient) execute(req *http.Request) (*http.Response, error) {
 	} else {
 		// 未配置重试：直接执行一次
 		data, err := operationFn()
-		if err != nil && !IsNonRetryable(err) {
+		if err != nil {
 			execErr = err
-		} else if data != nil {
+		} else {
 			finalResp = data.(*http.Response)
 		}
 	}
 
 	elapsed := time.Since(start)
+	cfg.attempts += attempts
 
 	// ⑥ 日志
 	if b.logger != nil {
 		ms := float64(elapsed.Milliseconds())
+		u := redactURL(req.URL, defaultRedactQuery)
 		if execErr != nil {
-			b.logger("[HTTP] %s %s — error: %v (%.2fms)", req.Method, req.URL, execErr, ms)
+			b.logger("[HTTP] %s %s — error: %v (%.2fms)", req.Method, u, execErr, ms)
 		} else {
-			b.logger("[HTTP] %s %s — %d (%.2fms)", req.Method, req.URL, finalResp.StatusCode, ms)
+			b.logger("[HTTP] %s %s — %d (%.2fms)", req.Method, u, finalResp.StatusCode, ms)
 		}
 	}
+	if b.slog != nil {
+		b.slog.logRequest(req, cfg.stream, finalResp, execErr, elapsed, attempts, c.decodeBody)
+	}
 
 	// ⑦ 指标
 	if b.metrics != nil {
@@ -920,37 +1182,65 @@ func (c *HTTPClient) execute(req *http.Request) (*http.Response, error) {
 			b.metrics.ErrorRequests.Add(1)
 		}
 	}
+	if b.collector != nil {
+		b.collector.observeRequest(req.Method, host, route, finalResp, execErr, elapsed, attempts)
+	}
 
-	// ⑧ 熔断状态更新
-	if b.circuitBreaker != nil {
-		if execErr != nil || (finalResp != nil && finalResp.StatusCode >= 500) {
-			b.circuitBreaker.RecordFailure()
-		} else {
-			b.circuitBreaker.RecordSuccess()
-		}
+	// ⑧ 熔断状态更新（耗时用于慢调用判定）
+	if breaker != nil {
+		breaker.record(execErr != nil || (finalResp != nil && finalResp.StatusCode >= 500), elapsed)
 	}
 
 	if execErr != nil {
 		return nil, execErr
 	}
 
-	// ⑨ 写入响应缓存（仅 GET 200，读取 body 后重新装填以供调用方读取）
-	if b.responseCache != nil && req.Method == http.MethodGet && finalResp.StatusCode == http.StatusOK {
-		body, readErr := io.ReadAll(finalResp.Body)
-		finalResp.Body.Close()
-		if readErr == nil {
-			b.responseCache.set(req.URL.String(), &cacheEntry{
-				respBody: body,
-				headers:  finalResp.Header.Clone(),
-				status:   finalResp.StatusCode,
-			})
+	// ⑨ 写入响应缓存
+	//    304：用新头部刷新缓存条目，并以缓存内容返回给调用方
+	//    其余：按 Cache-Control 判断可缓存时读取 body 写入缓存，再重新装填以供调用方读取
+	//    不安全方法成功后删除目标 URI 的缓存
+	if useCache {
+		if stale != nil && finalResp.StatusCode == http.StatusNotModified {
+			io.Copy(io.Discard, finalResp.Body)
+			finalResp.Body.Close()
+			return b.responseCache.revalidated(key, stale, finalResp).response(req), nil
+		}
+		if cacheableStatus[finalResp.StatusCode] {
+			body, readErr := io.ReadAll(finalResp.Body)
+			finalResp.Body.Close()
 			finalResp.Body = io.NopCloser(bytes.NewReader(body))
+			if readErr != nil {
+				return nil, readErr
+			}
+			b.responseCache.store(key, req, finalResp, body)
 		}
+	} else if b.responseCache != nil {
+		b.responseCache.invalidate(req, finalResp, c.raw.Jar)
 	}
 
 	return finalResp, nil
 }
 
+// send 发送一次 HTTP 请求；使用代理池时记录本次选中的代理，用于被动健康检测
+func (c *HTTPClient) send(raw *http.Client, req *http.Request) (*http.Response, error) {
+	pool := c.builder.proxyPool
+	if pool == nil {
+		return raw.Do(req)
+	}
+	pick := &proxyPick{}
+	start := time.Now()
+	resp, err := raw.Do(req.WithContext(context.WithValue(req.Context(), proxyPickKey{}, pick)))
+	if pick.proxy != nil {
+		// 只有网络错误归因于代理
+		if err != nil && req.Context().Err() == nil {
+			pool.reportFailure(pick.proxy, err)
+		} else if err == nil {
+			pool.reportSuccess(pick.proxy, time.Since(start))
+		}
+	}
+	return resp, err
+}
+
 // ═══════════════════════════════════════════════════════
 // HTTP 方法
 // ═══════════════════════════════════════════════════════
@@ -1064,26 +1354,27 @@ func first(rbs []*RequestBuilder) *RequestBuilder {
 // 响应读取
 // ═══════════════════════════════════════════════════════
 
-// ReadBody 读取并关闭响应体，自动处理 gzip 解压。
+// ReadBody 读取并关闭响应体，按 Content-Encoding 自动解压，文本响应按声明的字符集（GBK、Big5 等）转为 UTF-8。
+// 超过 MaxBodySize（线上或解压后）时返回 *BodyTooLargeError。
 // 调用后 resp.Body 已关闭，不可再次读取。
 //
 // 参数：
 //   - resp: *http.Response，不可为 nil。
 //
 // 返回：
-//   - []byte: 原始响应体内容（gzip 时为解压后内容）
+//   - []byte: 响应体内容（压缩时为解压后内容，文本响应为 UTF-8）
 func (c *HTTPClient) ReadBody(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
-	var reader io.Reader = resp.Body
-	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
-		gr, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		defer gr.Close()
-		reader = gr
+	reader, err := c.decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
 	}
-	return io.ReadAll(reader)
+	return toUTF8(resp, b), nil
 }
 
 // ReadBodyString 读取响应体并以字符串形式返回，内部调用 ReadBody。
@@ -1124,228 +1415,6 @@ func (c *HTTPClient) ReadJSON(resp *http.Response, target any) error {
 // 可靠性组件
 // ═══════════════════════════════════════════════════════
 
-// ─── 熔断器 ────────────────────────────────────────────
-
-type circuitState int
-
-const (
-	stateClosed   circuitState = iota // 正常，所有请求放行
-	stateOpen                         // 熔断，所有请求拒绝
-	stateHalfOpen                     // 半开，放行探测请求
-)
-
-// CircuitBreaker 三态熔断器，状态流转：Closed → Open → HalfOpen → Closed。
-//
-// 创建后可直接修改公开字段调整阈值：
-//
-//	cb := NewCircuitBreaker()
-//	cb.MaxFailures = 10          // 允许更多失败次数
-//	cb.OpenTimeout = time.Minute // 熔断持续更久
-type CircuitBreaker struct {
-	mu          sync.Mutex
-	state       circuitState
-	failures    int
-	successes   int
-	lastFailure time.Time
-
-	// MaxFailures 连续失败多少次后开启熔断，默认 5
-	MaxFailures int
-	// OpenTimeout 熔断持续时间，超过后进入半开状态，默认 30s
-	OpenTimeout time.Duration
-	// HalfOpenSuccesses 半开状态需要多少次连续成功才完全恢复，默认 2
-	HalfOpenSuccesses int
-}
-
-// NewCircuitBreaker 创建使用默认阈值的熔断器。
-// 默认值：MaxFailures=5，OpenTimeout=30s，HalfOpenSuccesses=2。
-func NewCircuitBreaker() *CircuitBreaker {
-	return &CircuitBreaker{
-		MaxFailures:       5,
-		OpenTimeout:       30 * time.Second,
-		HalfOpenSuccesses: 2,
-	}
-}
-
-// Allow 判断当前是否允许发送请求。
-// 熔断开启时返回 false；半开状态或正常状态返回 true。
-// 此方法由 HTTPClient 内部调用，通常不需要外部直接调用。
-func (cb *CircuitBreaker) Allow() bool {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	if cb.state == stateOpen {
-		if time.Since(cb.lastFailure) > cb.OpenTimeout {
-			cb.state = stateHalfOpen
-			cb.successes = 0
-			return true
-		}
-		return false
-	}
-	return true
-}
-
-// RecordSuccess 记录一次成功，由 HTTPClient 内部在请求成功后调用。
-// 半开状态下连续成功达到阈值时切换回 Closed。
-func (cb *CircuitBreaker) RecordSuccess() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	cb.failures = 0
-	if cb.state == stateHalfOpen {
-		cb.successes++
-		if cb.successes >= cb.HalfOpenSuccesses {
-			cb.state = stateClosed
-		}
-	}
-}
-
-// RecordFailure 记录一次失败，由 HTTPClient 内部在请求失败后调用。
-// 连续失败达到阈值时切换到 Open 状态。
-func (cb *CircuitBreaker) RecordFailure() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	cb.failures++
-	cb.lastFailure = time.Now()
-	if cb.failures >= cb.MaxFailures {
-		cb.state = stateOpen
-	}
-}
-
-// State 返回熔断器当前状态的字符串描述："closed"、"open" 或 "half-open"。
-// 可用于监控上报或调试日志。
-func (cb *CircuitBreaker) State() string {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	switch cb.state {
-	case stateClosed:
-		return "closed"
-	case stateOpen:
-		return "open"
-	case stateHalfOpen:
-		return "half-open"
-	}
-	return "unknown"
-}
-
-// ─── 限速器 ────────────────────────────────────────────
-
-// RateLimiter 基于令牌桶算法的限速器，并发安全。
-// 令牌按照 rps 速率持续补充，桶的容量由 burst 决定。
-type RateLimiter struct {
-	mu         sync.Mutex
-	tokens     float64   // 当前令牌数
-	maxTokens  float64   // 桶的最大容量（= burst）
-	refillRate float64   // 每秒补充的令牌数（= rps）
-	lastRefill time.Time // 上次补充时间，用于计算增量
-}
-
-// NewRateLimiter 创建令牌桶限速器。
-//
-// 参数：
-//   - rps:   每秒允许的稳定请求数，例如 100.0 表示每秒 100 个请求
-//   - burst: 瞬时峰值（桶的容量），例如 20 表示瞬间最多 20 个并发请求
-//
-// 示例：
-//
-//	rl := NewRateLimiter(100, 20) // 稳定 100 rps，瞬时最多 20
-func NewRateLimiter(rps float64, burst int) *RateLimiter {
-	return &RateLimiter{
-		tokens:     float64(burst),
-		maxTokens:  float64(burst),
-		refillRate: rps,
-		lastRefill: time.Now(),
-	}
-}
-
-// Wait 阻塞等待直到获取到令牌，context 取消时立即返回错误。
-// 此方法由 HTTPClient 内部调用，通常不需要外部直接调用。
-func (r *RateLimiter) Wait(ctx context.Context) error {
-	for {
-		r.mu.Lock()
-		now := time.Now()
-		r.tokens = minFloat(r.maxTokens, r.tokens+now.Sub(r.lastRefill).Seconds()*r.refillRate)
-		r.lastRefill = now
-		if r.tokens >= 1 {
-			r.tokens--
-			r.mu.Unlock()
-			return nil
-		}
-		wait := time.Duration((1-r.tokens)/r.refillRate*1000) * time.Millisecond
-		r.mu.Unlock()
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(wait):
-		}
-	}
-}
-
-func minFloat(a, b float64) float64 {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// ─── 响应缓存 ──────────────────────────────────────────
-
-// cacheEntry 单条缓存记录
-type cacheEntry struct {
-	respBody  []byte
-	headers   http.Header
-	status    int
-	expiresAt time.Time
-}
-
-// ResponseCache 基于内存的 TTL 缓存，仅缓存 GET 请求且状态码为 200 的响应。
-// 后台 goroutine 按 TTL 间隔定期清理过期条目，读写均并发安全。
-type ResponseCache struct {
-	mu      sync.RWMutex
-	entries map[string]*cacheEntry
-	ttl     time.Duration
-}
-
-// NewResponseCache 创建响应缓存。
-//
-// 参数：
-//   - ttl: 缓存有效期，例如 5*time.Minute。过期后下次请求会重新发送并刷新缓存。
-func NewResponseCache(ttl time.Duration) *ResponseCache {
-	c := &ResponseCache{entries: make(map[string]*cacheEntry), ttl: ttl}
-	go c.evictLoop()
-	return c
-}
-
-// evictLoop 后台定期清理过期条目，随 ResponseCache 生命周期运行
-func (c *ResponseCache) evictLoop() {
-	t := time.NewTicker(c.ttl)
-	defer t.Stop()
-	for range t.C {
-		now := time.Now()
-		c.mu.Lock()
-		for k, e := range c.entries {
-			if now.After(e.expiresAt) {
-				delete(c.entries, k)
-			}
-		}
-		c.mu.Unlock()
-	}
-}
-
-func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	e, ok := c.entries[key]
-	if !ok || time.Now().After(e.expiresAt) {
-		return nil, false
-	}
-	return e, true
-}
-
-func (c *ResponseCache) set(key string, e *cacheEntry) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	e.expiresAt = time.Now().Add(c.ttl)
-	c.entries[key] = e
-}
-
 // ─── 指标 ──────────────────────────────────────────────
 
 // Metrics 并发安全的请求指标收集器，通过原子操作更新，无锁竞争。
@@ -1360,6 +1429,7 @@ type Metrics struct {
 	TotalRequests   atomic.Int64 // 请求总数
 	ErrorRequests   atomic.Int64 // 失败请求数（网络错误或 5xx）
 	TotalDurationMs atomic.Int64 // 所有请求的累计耗时（毫秒）
+	HedgedRequests  atomic.Int64 // 额外发出的对冲请求数，不计入 TotalRequests
 }
 
 // Summary 返回格式化的指标摘要字符串，格式：total=N errors=N avg_ms=N
@@ -1372,57 +1442,6 @@ func (m *Metrics) Summary() string {
 	return fmt.Sprintf("total=%d errors=%d avg_ms=%d", total, m.ErrorRequests.Load(), avg)
 }
 
-// ═══════════════════════════════════════════════════════
-// 文件上传辅助
-// ═══════════════════════════════════════════════════════
-
-// File 描述一个待上传的文件，由 Content 或 Path 二选一提供内容来源。
-// Content 非 nil 时优先使用，Path 用于从磁盘流式读取大文件。
-type File struct {
-	FieldName string // multipart 表单字段名，例如 "avatar"
-	FileName  string // 文件名，例如 "photo.png"（显示在服务端）
-	Content   []byte // 直接提供文件字节内容（优先于 Path）
-	Path      string // 从磁盘读取的文件路径，例如 "/tmp/upload.csv"
-}
-
-// attachFile 将文件和表单字段写入 multipart 请求体，并设置对应的 Content-Type
-func attachFile(req *http.Request, values url.Values, f *File) error {
-	buf := &bytes.Buffer{}
-	w := multipart.NewWriter(buf)
-	for key, vals := range values {
-		for _, val := range vals {
-			if err := w.WriteField(key, val); err != nil {
-				return err
-			}
-		}
-	}
-	part, err := w.CreateFormFile(f.FieldName, f.FileName)
-	if err != nil {
-		return err
-	}
-	if f.Content != nil {
-		if _, err = part.Write(f.Content); err != nil {
-			return err
-		}
-	} else if f.Path != "" {
-		file, err := os.Open(f.Path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		if _, err = io.Copy(part, file); err != nil {
-			return err
-		}
-	}
-	if err = w.Close(); err != nil {
-		return err
-	}
-	req.Body = io.NopCloser(buf)
-	req.ContentLength = int64(buf.Len())
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	return nil
-}
-
 // ═══════════════════════════════════════════════════════
 // 便捷独立函数（共享单例客户端）
 // ═══════════════════════════════════════════════════════
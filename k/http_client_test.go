@@ -193,6 +193,33 @@ func TestClient_PostJSON(t *testing.T) {
 	}
 }
 
+// 测试配置重试时非 5xx 响应直接返回给调用方，不当作错误、不重试
+func TestClient_RetryReturnsResponse(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).Build()
+	for path, want := range map[string]int{"/": http.StatusOK, "/missing": http.StatusNotFound} {
+		calls = 0
+		resp, err := client.Get(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want || calls != 1 {
+			t.Errorf("%s: status=%d calls=%d", path, resp.StatusCode, calls)
+		}
+	}
+}
+
 // 测试熔断器
 func TestCircuitBreaker(t *testing.T) {
 	cb := NewCircuitBreaker()
@@ -0,0 +1,426 @@
+package k
+
+// http_codec.go —— 编解码器注册表：按 Content-Type 解码响应、按媒体类型编码请求、Accept 协商
+//
+// 设计目标：
+//   - 每个 HTTPClient 持有独立的注册表，内置 JSON、XML、表单、protobuf
+//   - ReadAs 按响应 Content-Type 选择解码器，支持 +json / +xml 结构化后缀，缺失时按内容嗅探
+//   - PostXML / PostProto / PostAs 按媒体类型编码请求体
+//   - R().Accept 按优先级生成带 q 值的 Accept 头
+//   - protobuf 不引入依赖：内置实现支持带 Marshal / Unmarshal 方法的消息（gogo、vtprotobuf 等），
+//     使用 google.golang.org/protobuf 时通过 CodecFuncs 注册
+//
+// 示例：
+//
+//	var order Order
+//	resp, _ := client.Get("/orders/1", R().Accept("application/xml", "application/json"))
+//	err := client.ReadAs(resp, &order) // 服务端返回哪种格式都能解码
+//
+//	client.RegisterCodec(CodecFuncs{
+//	    Type:          "application/x-protobuf",
+//	    MarshalFunc:   func(v any) ([]byte, error) { return proto.Marshal(v.(proto.Message)) },
+//	    UnmarshalFunc: func(b []byte, v any) error { return proto.Unmarshal(b, v.(proto.Message)) },
+//	}, "application/x-protobuf", "application/protobuf")
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 常用媒体类型
+const (
+	MediaTypeJSON  = "application/json"
+	MediaTypeXML   = "application/xml"
+	MediaTypeForm  = "application/x-www-form-urlencoded"
+	MediaTypeProto = "application/x-protobuf"
+)
+
+// ErrUnsupportedMediaType 注册表中没有与 Content-Type 对应的编解码器
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Codec 编解码器
+type Codec interface {
+	// ContentType 编码请求体时使用的媒体类型
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// CodecFuncs 由函数组成的 Codec，便于接入第三方序列化库
+type CodecFuncs struct {
+	Type          string
+	MarshalFunc   func(v any) ([]byte, error)
+	UnmarshalFunc func(data []byte, v any) error
+}
+
+func (c CodecFuncs) ContentType() string                { return c.Type }
+func (c CodecFuncs) Marshal(v any) ([]byte, error)      { return c.MarshalFunc(v) }
+func (c CodecFuncs) Unmarshal(data []byte, v any) error { return c.UnmarshalFunc(data, v) }
+
+// ─── 内置编解码器 ──────────────────────────────────────
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                { return MediaTypeJSON }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string { return MediaTypeXML }
+
+func (xmlCodec) Marshal(v any) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// Unmarshal 解码 XML。ReadBody 已按声明的字符集转为 UTF-8，
+// 因此忽略 <?xml encoding="GBK"?> 等声明，不再二次转码。
+func (xmlCodec) Unmarshal(data []byte, v any) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	return dec.Decode(v)
+}
+
+// protoCodec 支持实现了 Marshal() / Unmarshal() 方法的消息，以及 []byte 原样透传
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string { return MediaTypeProto }
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case interface{ Marshal() ([]byte, error) }:
+		return m.Marshal()
+	case []byte:
+		return m, nil
+	}
+	return nil, fmt.Errorf("protobuf: %T has no Marshal() method, register a codec with RegisterCodec", v)
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	switch m := v.(type) {
+	case interface{ Unmarshal([]byte) error }:
+		return m.Unmarshal(data)
+	case *[]byte:
+		*m = append((*m)[:0], data...)
+		return nil
+	}
+	return fmt.Errorf("protobuf: %T has no Unmarshal([]byte) method, register a codec with RegisterCodec", v)
+}
+
+// formCodec application/x-www-form-urlencoded。
+// 支持 url.Values、map[string]string、map[string][]string、map[string]any 与带 form 标签的结构体。
+type formCodec struct{}
+
+func (formCodec) ContentType() string { return MediaTypeForm }
+
+func (formCodec) Marshal(v any) ([]byte, error) {
+	vals := url.Values{}
+	switch m := v.(type) {
+	case url.Values:
+		vals = m
+	case map[string][]string:
+		vals = m
+	case map[string]string:
+		for k, s := range m {
+			vals.Set(k, s)
+		}
+	case map[string]any:
+		for k, s := range m {
+			vals.Set(k, fmt.Sprint(s))
+		}
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		if rv.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("form: unsupported type %T", v)
+		}
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			name, omitEmpty, ok := formFieldName(rt.Field(i))
+			if !ok {
+				continue
+			}
+			fv := rv.Field(i)
+			if omitEmpty && fv.IsZero() {
+				continue
+			}
+			if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+				for j := 0; j < fv.Len(); j++ {
+					vals.Add(name, fmt.Sprint(fv.Index(j).Interface()))
+				}
+				continue
+			}
+			vals.Set(name, fmt.Sprint(fv.Interface()))
+		}
+	}
+	return []byte(vals.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v any) error {
+	vals, err := url.ParseQuery(string(data))
+	if err != nil {
+		return fmt.Errorf("form: %w", err)
+	}
+	switch m := v.(type) {
+	case *url.Values:
+		*m = vals
+		return nil
+	case *map[string][]string:
+		*m = vals
+		return nil
+	case *map[string]string:
+		if *m == nil {
+			*m = make(map[string]string, len(vals))
+		}
+		for k := range vals {
+			(*m)[k] = vals.Get(k)
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form: unsupported target %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, ok := formFieldName(rt.Field(i))
+		if !ok || !vals.Has(name) {
+			continue
+		}
+		if err := setFormField(rv.Field(i), vals[name]); err != nil {
+			return fmt.Errorf("form: field %s: %w", rt.Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+// formFieldName 解析 form 标签，未设置时使用字段名
+func formFieldName(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+	tag := f.Tag.Get("form")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, opts == "omitempty", true
+}
+
+// setFormField 将表单值写入字段，支持字符串、整数、浮点、布尔及其切片
+func setFormField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setFormScalar(s.Index(i), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setFormScalar(fv, values[0])
+}
+
+func setFormScalar(fv reflect.Value, s string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+	return nil
+}
+
+// ─── 注册表 ────────────────────────────────────────────
+
+// codecRegistry 媒体类型到编解码器的映射（并发安全）
+type codecRegistry struct {
+	mu     sync.RWMutex
+	byType map[string]Codec
+}
+
+func newCodecRegistry() *codecRegistry {
+	r := &codecRegistry{byType: make(map[string]Codec)}
+	r.register(jsonCodec{}, MediaTypeJSON, "text/json")
+	r.register(xmlCodec{}, MediaTypeXML, "text/xml")
+	r.register(formCodec{}, MediaTypeForm)
+	r.register(protoCodec{}, MediaTypeProto, "application/protobuf", "application/vnd.google.protobuf")
+	return r
+}
+
+func (r *codecRegistry) register(c Codec, mediaTypes ...string) {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{c.ContentType()}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, mt := range mediaTypes {
+		r.byType[strings.ToLower(mt)] = c
+	}
+}
+
+// lookup 按媒体类型查找，支持 application/problem+json 等结构化后缀
+func (r *codecRegistry) lookup(contentType string) (Codec, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.byType[mt]; ok {
+		return c, true
+	}
+	if i := strings.LastIndexByte(mt, '+'); i >= 0 {
+		if c, ok := r.byType["application/"+mt[i+1:]]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// forResponse 选择响应的解码器；Content-Type 缺失或为通用类型时按内容嗅探 JSON / XML
+func (r *codecRegistry) forResponse(contentType string, body []byte) (Codec, error) {
+	if c, ok := r.lookup(contentType); ok {
+		return c, nil
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == "" || mt == "text/plain" || mt == "application/octet-stream" {
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			c, _ := r.lookup(MediaTypeJSON)
+			return c, nil
+		}
+		if len(trimmed) > 0 && trimmed[0] == '<' {
+			c, _ := r.lookup(MediaTypeXML)
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+}
+
+// ─── HTTPClient 方法 ───────────────────────────────────
+
+// RegisterCodec 注册或覆盖编解码器，可在运行期间调用。
+//
+// 参数：
+//   - codec:      编解码器
+//   - mediaTypes: 对应的媒体类型，为空时使用 codec.ContentType()
+func (c *HTTPClient) RegisterCodec(codec Codec, mediaTypes ...string) {
+	c.codecs.register(codec, mediaTypes...)
+}
+
+// ReadAs 按响应的 Content-Type 选择解码器，将响应体反序列化到 target，内部调用 ReadBody。
+// 调用后 resp.Body 已关闭；响应体为空时不修改 target 并返回 nil。
+//
+// 参数：
+//   - resp:   *http.Response，为 nil 时返回错误
+//   - target: 指向目标值的指针
+func (c *HTTPClient) ReadAs(resp *http.Response, target any) error {
+	if resp == nil {
+		return errors.New("response is nil")
+	}
+	b, err := c.ReadBody(resp)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	codec, err := c.codecs.forResponse(resp.Header.Get("Content-Type"), b)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(b, target)
+}
+
+// PostAs 以 mediaType 对应的编解码器序列化 data 后发送 POST 请求。
+//
+// 参数：
+//   - path:      请求路径
+//   - mediaType: 请求体媒体类型，同时作为 Content-Type，须已注册
+//   - data:      待序列化的值
+//   - rb:        可选请求配置
+func (c *HTTPClient) PostAs(path, mediaType string, data any, rb ...*RequestBuilder) (*http.Response, error) {
+	codec, ok := c.codecs.lookup(mediaType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+	}
+	b, err := codec.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(path, bytes.NewReader(b), mediaType, rb...)
+}
+
+// PostXML 将 data 序列化为 XML（含 <?xml ...?> 声明）后以 POST 方式发送。
+func (c *HTTPClient) PostXML(path string, data any, rb ...*RequestBuilder) (*http.Response, error) {
+	return c.PostAs(path, MediaTypeXML, data, rb...)
+}
+
+// PostProto 将 protobuf 消息序列化后以 POST 方式发送，Content-Type 为 application/x-protobuf。
+func (c *HTTPClient) PostProto(path string, msg any, rb ...*RequestBuilder) (*http.Response, error) {
+	return c.PostAs(path, MediaTypeProto, msg, rb...)
+}
+
+// Accept 按优先级设置 Accept 头，排在前面的媒体类型 q 值更高，
+// 例如 Accept("application/x-protobuf", "application/json") 生成
+// "application/x-protobuf, application/json;q=0.9"。通过 Headers 设置的 Accept 优先。
+func (r *RequestBuilder) Accept(mediaTypes ...string) *RequestBuilder {
+	parts := make([]string, len(mediaTypes))
+	for i, mt := range mediaTypes {
+		q := 10 - i
+		switch {
+		case i == 0:
+			parts[i] = mt
+		case q < 1:
+			parts[i] = mt + ";q=0.1"
+		default:
+			parts[i] = mt + ";q=0." + strconv.Itoa(q)
+		}
+	}
+	r.cfg.accept = strings.Join(parts, ", ")
+	return r
+}
@@ -0,0 +1,148 @@
+package k
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type codecOrder struct {
+	ID    int      `json:"id" xml:"id" form:"id"`
+	Name  string   `json:"name" xml:"name" form:"name"`
+	Tags  []string `json:"tags" xml:"tag" form:"tag"`
+	Paid  bool     `json:"paid" xml:"paid" form:"paid"`
+	Notes string   `json:"-" xml:"-" form:"notes,omitempty"`
+}
+
+// fakeProto 模拟生成代码中带 Marshal / Unmarshal 方法的 protobuf 消息
+type fakeProto struct{ N uint64 }
+
+func (m *fakeProto) Marshal() ([]byte, error) { return binary.AppendUvarint(nil, m.N), nil }
+func (m *fakeProto) Unmarshal(b []byte) error {
+	n, k := binary.Uvarint(b)
+	if k <= 0 {
+		return errors.New("bad varint")
+	}
+	m.N = n
+	return nil
+}
+
+// 测试 ReadAs 按 Content-Type 选择解码器
+func TestReadAs_ContentTypes(t *testing.T) {
+	bodies := map[string]struct{ ct, body string }{
+		"/json":    {"application/json; charset=utf-8", `{"id":1,"name":"a","tags":["x","y"],"paid":true}`},
+		"/problem": {"application/vnd.api+json", `{"id":1,"name":"a","tags":["x","y"],"paid":true}`},
+		"/xml":     {"text/xml; charset=utf-8", `<?xml version="1.0"?><order><id>1</id><name>a</name><tag>x</tag><tag>y</tag><paid>true</paid></order>`},
+		"/form":    {"application/x-www-form-urlencoded", `id=1&name=a&tag=x&tag=y&paid=true`},
+		"/sniff":   {"", `  {"id":1,"name":"a","tags":["x","y"],"paid":true}`},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := bodies[r.URL.Path]
+		w.Header()["Content-Type"] = []string{b.ct}
+		io.WriteString(w, b.body)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	for path := range bodies {
+		resp, err := client.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o codecOrder
+		if err := client.ReadAs(resp, &o); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if o.ID != 1 || o.Name != "a" || len(o.Tags) != 2 || o.Tags[1] != "y" || !o.Paid {
+			t.Errorf("%s: decoded %+v", path, o)
+		}
+	}
+}
+
+// 测试未注册的媒体类型
+func TestReadAs_Unsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/msgpack")
+		w.Write([]byte{0x81})
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	resp, _ := client.Get("/")
+	var v map[string]any
+	if err := client.ReadAs(resp, &v); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 注册后可解码
+	client.RegisterCodec(CodecFuncs{
+		Type:          "application/msgpack",
+		MarshalFunc:   func(v any) ([]byte, error) { return nil, nil },
+		UnmarshalFunc: func(b []byte, v any) error { *(v.(*map[string]any)) = map[string]any{"len": len(b)}; return nil },
+	})
+	resp, _ = client.Get("/")
+	if err := client.ReadAs(resp, &v); err != nil || v["len"] != 1 {
+		t.Fatalf("v=%v err=%v", v, err)
+	}
+}
+
+// 测试请求编码器与 Accept 协商
+func TestPostAs_EncodersAndAccept(t *testing.T) {
+	type seen struct{ ct, accept, body string }
+	var got seen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = seen{r.Header.Get("Content-Type"), r.Header.Get("Accept"), string(b)}
+		// 按 Accept 的首选类型响应
+		if strings.HasPrefix(got.accept, MediaTypeProto) {
+			w.Header().Set("Content-Type", MediaTypeProto)
+			w.Write(b)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeJSON)
+		json.NewEncoder(w).Encode(codecOrder{ID: 2})
+	}))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Build()
+
+	resp, err := client.PostXML("/", codecOrder{ID: 1, Name: "a&b"}, R().Accept(MediaTypeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.ct != MediaTypeXML || !strings.HasPrefix(got.body, "<?xml") || !strings.Contains(got.body, "<name>a&amp;b</name>") {
+		t.Errorf("xml request = %+v", got)
+	}
+
+	resp, err = client.PostProto("/", &fakeProto{N: 300}, R().Accept(MediaTypeProto, MediaTypeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ct != MediaTypeProto || got.accept != "application/x-protobuf, application/json;q=0.9" {
+		t.Errorf("proto request = %+v", got)
+	}
+	var msg fakeProto
+	if err := client.ReadAs(resp, &msg); err != nil || msg.N != 300 {
+		t.Errorf("proto response = %+v, %v", msg, err)
+	}
+
+	resp, err = client.PostAs("/", MediaTypeForm, codecOrder{ID: 3, Tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	vals, _ := url.ParseQuery(got.body)
+	if vals.Get("id") != "3" || len(vals["tag"]) != 2 || vals.Has("notes") {
+		t.Errorf("form body = %q", got.body)
+	}
+
+	if _, err := client.PostProto("/", struct{}{}); err == nil {
+		t.Error("expected error for message without Marshal method")
+	}
+}
@@ -0,0 +1,352 @@
+package k
+
+// http_cookie.go —— Cookie Jar：公共后缀感知的作用域、持久化到文件或 store.AdapterCache、会话视图
+//
+// 设计目标：
+//   - 基于 net/http/cookiejar + publicsuffix，拒绝为 "com.cn"、"github.io" 等公共后缀设置 Cookie
+//   - 记录每个 Cookie 的完整属性（域、路径、过期时间、Secure 等），可保存到文件或缓存并在重启后恢复
+//   - Session() 返回共享 Transport 与全部客户端配置、但使用独立 Cookie Jar 的客户端视图，
+//     适合同一进程内以多个账号登录同一站点
+//
+// 示例：
+//
+//	jar, _ := NewCookieJar(&CookieJarOptions{Store: NewFileCookieStore("cookies.json"), AutoSave: true})
+//	client, _ := NewClient("https://sso.example.com").CookieJar(jar).Build()
+//
+//	alice := client.Session(nil) // 独立的内存 Jar
+//	bob := client.Session(nil)
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieStore Cookie 持久化后端
+type CookieStore interface {
+	// Load 读取已保存的数据，尚未保存过时返回 nil, nil
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+// fileCookieStore 保存到本地文件
+type fileCookieStore struct{ path string }
+
+// NewFileCookieStore 创建保存到本地文件的 Cookie 存储，写入时先写临时文件再重命名，避免写到一半被读取
+func NewFileCookieStore(path string) CookieStore {
+	return &fileCookieStore{path: path}
+}
+
+func (s *fileCookieStore) Load() ([]byte, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (s *fileCookieStore) Save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// cacheCookieStore 保存到 store.AdapterCache
+type cacheCookieStore struct {
+	cache  store.AdapterCache
+	key    string
+	expire int
+}
+
+// NewCacheCookieStore 创建保存到 store.AdapterCache（如 Redis）的 Cookie 存储，多实例可共享登录态。
+//
+// 参数：
+//   - cache:  缓存适配器
+//   - key:    缓存键
+//   - expire: 过期时间（秒），应不短于 Cookie 的有效期
+func NewCacheCookieStore(cache store.AdapterCache, key string, expire int) CookieStore {
+	return &cacheCookieStore{cache: cache, key: key, expire: expire}
+}
+
+func (s *cacheCookieStore) Load() ([]byte, error) {
+	v, err := s.cache.Get(s.key)
+	if err != nil || v == "" {
+		return nil, err
+	}
+	return []byte(v), nil
+}
+
+func (s *cacheCookieStore) Save(data []byte) error {
+	return s.cache.Set(s.key, string(data), s.expire)
+}
+
+// CookieJarOptions NewCookieJar 的可选参数，传 nil 时为不持久化的内存 Jar。
+type CookieJarOptions struct {
+	// Store 持久化后端，创建 Jar 时从中恢复未过期的 Cookie
+	Store CookieStore
+	// AutoSave 为 true 时每次收到 Set-Cookie 后立即保存，否则需手动调用 Save
+	AutoSave bool
+	// OnSaveError AutoSave 失败时回调
+	OnSaveError func(error)
+}
+
+// savedCookie 持久化的 Cookie 及其来源 URL
+type savedCookie struct {
+	URL      string    `json:"url"` // 设置该 Cookie 的响应对应的 scheme://host/
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"` // 为空表示 host-only Cookie
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"` // 零值表示会话 Cookie
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+	SameSite int       `json:"same_site,omitempty"`
+
+	seq uint64 // 创建顺序：保存与恢复时保持，使恢复后 Cookie 的发送顺序与原来一致
+}
+
+func (c *savedCookie) key() string {
+	u, _ := url.Parse(c.URL)
+	scope := c.Domain
+	if scope == "" {
+		scope = u.Hostname()
+	}
+	return strings.ToLower(strings.TrimPrefix(scope, ".")) + ";" + c.Path + ";" + c.Name
+}
+
+// CookieJar 实现 http.CookieJar（并发安全），通过 NewCookieJar 创建。
+type CookieJar struct {
+	opts CookieJarOptions
+
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[string]*savedCookie
+	seq     uint64
+
+	saveMu sync.Mutex // 串行化 Save，避免较旧的快照覆盖较新的
+}
+
+// NewCookieJar 创建使用公共后缀列表的 Cookie Jar，设置了 Store 时恢复已保存的 Cookie。
+func NewCookieJar(opts *CookieJarOptions) (*CookieJar, error) {
+	j := &CookieJar{entries: make(map[string]*savedCookie)}
+	if opts != nil {
+		j.opts = *opts
+	}
+	j.jar = newPublicSuffixJar()
+	if j.opts.Store == nil {
+		return j, nil
+	}
+	data, err := j.opts.Store.Load()
+	if err != nil || len(data) == 0 {
+		return j, err
+	}
+	var saved []*savedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sc := range saved {
+		if !sc.Expires.IsZero() && !sc.Expires.After(now) {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{sc.cookie()})
+		j.seq++
+		sc.seq = j.seq
+		j.entries[sc.key()] = sc
+	}
+	return j, nil
+}
+
+func newPublicSuffixJar() *cookiejar.Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List}) // 只在 Options 非法时返回错误
+	return jar
+}
+
+// SetCookies 实现 http.CookieJar。只记录底层 Jar 实际接受的 Cookie，被公共后缀规则等拒绝的不会持久化。
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar := j.current()
+	jar.SetCookies(u, cookies)
+
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	now := time.Now()
+	j.mu.Lock()
+	for _, c := range cookies {
+		sc := &savedCookie{
+			URL: origin, Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Secure: c.Secure, HttpOnly: c.HttpOnly, SameSite: int(c.SameSite),
+		}
+		if sc.Path == "" || sc.Path[0] != '/' {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			sc.Expires = c.Expires
+		}
+		if !sc.Expires.IsZero() && !sc.Expires.After(now) {
+			delete(j.entries, sc.key()) // 服务端删除 Cookie
+			continue
+		}
+		if !accepted(jar, u, sc) {
+			continue
+		}
+		if old, ok := j.entries[sc.key()]; ok {
+			sc.seq = old.seq // 与 cookiejar 一致，更新值不改变创建顺序
+		} else {
+			j.seq++
+			sc.seq = j.seq
+		}
+		j.entries[sc.key()] = sc
+	}
+	j.mu.Unlock()
+
+	if j.opts.AutoSave && j.opts.Store != nil {
+		if err := j.Save(); err != nil && j.opts.OnSaveError != nil {
+			j.opts.OnSaveError(err)
+		}
+	}
+}
+
+// accepted 以该 Cookie 作用范围内的 URL 查询底层 Jar，确认 Cookie 已被接受
+func accepted(jar *cookiejar.Jar, u *url.URL, sc *savedCookie) bool {
+	probe := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: sc.Path}
+	if sc.Secure {
+		probe.Scheme = "https"
+	}
+	for _, c := range jar.Cookies(probe) {
+		if c.Name == sc.Name && c.Value == sc.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cookies 实现 http.CookieJar
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.current().Cookies(u)
+}
+
+// current 返回底层 Jar，Clear 会替换它
+func (j *CookieJar) current() *cookiejar.Jar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar
+}
+
+// Save 将未过期的 Cookie（含会话 Cookie）保存到 Store，未设置 Store 时返回错误
+func (j *CookieJar) Save() error {
+	if j.opts.Store == nil {
+		return errors.New("cookie jar has no store")
+	}
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	now := time.Now()
+	j.mu.Lock()
+	saved := make([]*savedCookie, 0, len(j.entries))
+	for k, sc := range j.entries {
+		if !sc.Expires.IsZero() && !sc.Expires.After(now) {
+			delete(j.entries, k)
+			continue
+		}
+		saved = append(saved, sc)
+	}
+	j.mu.Unlock()
+	sort.Slice(saved, func(a, b int) bool { return saved[a].seq < saved[b].seq })
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return j.opts.Store.Save(data)
+}
+
+// Clear 清空全部 Cookie（例如退出登录），设置了 AutoSave 时同步清空持久化数据
+func (j *CookieJar) Clear() error {
+	j.mu.Lock()
+	j.jar = newPublicSuffixJar()
+	j.entries = make(map[string]*savedCookie)
+	j.mu.Unlock()
+	if j.opts.AutoSave && j.opts.Store != nil {
+		return j.Save()
+	}
+	return nil
+}
+
+func (c *savedCookie) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path, Expires: c.Expires,
+		Secure: c.Secure, HttpOnly: c.HttpOnly, SameSite: http.SameSite(c.SameSite),
+	}
+}
+
+// defaultCookiePath RFC 6265 §5.1.4 默认路径
+func defaultCookiePath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndexByte(p, '/')
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// CookieJar 设置 Cookie Jar，Cookie 在同一客户端的请求之间自动保存与携带。
+//
+// 参数：
+//   - jar: 通常为 NewCookieJar 创建的 *CookieJar，也可传入任意 http.CookieJar；nil 表示不使用
+func (b *ClientBuilder) CookieJar(jar http.CookieJar) *ClientBuilder {
+	if j, ok := jar.(*CookieJar); ok && j == nil {
+		jar = nil
+	}
+	b.cookieJar = jar
+	return b
+}
+
+// Session 返回共享 Transport（连接池）与全部客户端配置、但使用独立 Cookie Jar 的客户端视图。
+//
+// 参数：
+//   - jar: 会话使用的 Cookie Jar，为 nil 时创建新的内存 Jar
+func (c *HTTPClient) Session(jar http.CookieJar) *HTTPClient {
+	if jar == nil {
+		jar = newPublicSuffixJar()
+	}
+	raw, stream := *c.raw, *c.stream
+	raw.Jar, stream.Jar = jar, jar
+	return &HTTPClient{builder: c.builder, raw: &raw, stream: &stream, codecs: c.codecs}
+}
+
+// Jar 返回客户端当前使用的 Cookie Jar，未设置时为 nil
+func (c *HTTPClient) Jar() http.CookieJar {
+	return c.raw.Jar
+}
@@ -0,0 +1,243 @@
+package k
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// cookieServer /login 设置 Cookie，/whoami 回显收到的 Cookie（/whoami?cache=1 允许缓存），/logout 删除 Cookie
+func cookieServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: r.URL.Query().Get("user"), Path: "/", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Value: "1", Path: "/"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+		case "/whoami":
+			if r.URL.Query().Get("cache") != "" {
+				w.Header().Set("Cache-Control", "max-age=60")
+			}
+			var names []string
+			for _, c := range r.Cookies() {
+				names = append(names, c.Name+"="+c.Value)
+			}
+			io.WriteString(w, strings.Join(names, ";"))
+		}
+	}))
+}
+
+func whoami(t *testing.T, c *HTTPClient, query ...string) string {
+	t.Helper()
+	resp, err := c.Get("/whoami" + strings.Join(query, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := c.ReadBodyString(resp)
+	return s
+}
+
+// 测试 Cookie 在请求之间携带，MaxAge<0 删除，Clear 清空
+func TestCookieJar_PersistsAcrossRequests(t *testing.T) {
+	srv := cookieServer()
+	defer srv.Close()
+
+	jar, _ := NewCookieJar(nil)
+	client, _ := NewClient(srv.URL).CookieJar(jar).Build()
+	if client.Jar() != jar {
+		t.Fatal("Jar() should return the configured jar")
+	}
+	resp, _ := client.Get("/login?user=alice")
+	resp.Body.Close()
+	if got := whoami(t, client); got != "sid=alice;tmp=1" {
+		t.Errorf("cookies = %q", got)
+	}
+
+	resp, _ = client.Get("/logout")
+	resp.Body.Close()
+	if got := whoami(t, client); got != "tmp=1" {
+		t.Errorf("after logout = %q", got)
+	}
+
+	jar.Clear()
+	if got := whoami(t, client); got != "" {
+		t.Errorf("after Clear = %q", got)
+	}
+}
+
+// 测试公共后缀上的 Domain 被拒绝
+func TestCookieJar_PublicSuffix(t *testing.T) {
+	jar, _ := NewCookieJar(nil)
+	for host, suffix := range map[string]string{"shop.example.com.cn": "com.cn", "user.github.io": "github.io"} {
+		u, _ := url.Parse("https://" + host + "/")
+		jar.SetCookies(u, []*http.Cookie{{Name: "evil", Value: "1", Domain: suffix}})
+		other, _ := url.Parse("https://other." + suffix + "/")
+		if cs := jar.Cookies(other); len(cs) != 0 {
+			t.Errorf("cookie for %s leaked to %s: %v", suffix, other.Host, cs)
+		}
+	}
+
+	// 注册域名下的 Domain Cookie 对子域名可见
+	u, _ := url.Parse("https://a.example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "ok", Value: "1", Domain: "example.com"}})
+	b, _ := url.Parse("https://b.example.com/")
+	if cs := jar.Cookies(b); len(cs) != 1 {
+		t.Errorf("domain cookie = %v", cs)
+	}
+}
+
+// 测试被公共后缀规则拒绝的 Cookie 不会持久化，并发 AutoSave 最终保存的是最新快照
+func TestCookieJar_PersistsOnlyAcceptedCookies(t *testing.T) {
+	cache := store.NewMemory()
+	newStore := func() CookieStore { return NewCacheCookieStore(cache, "cookies", 3600) }
+	jar, _ := NewCookieJar(&CookieJarOptions{Store: newStore(), AutoSave: true})
+
+	u, _ := url.Parse("https://shop.example.com.cn/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "evil", Value: "1", Domain: "com.cn"},
+		{Name: "sid", Value: "s1", Path: "/"},
+		{Name: "admin", Value: "a1", Path: "/admin", Secure: true},
+	})
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jar.SetCookies(u, []*http.Cookie{{Name: fmt.Sprintf("c%d", i), Value: "v", Path: "/"}})
+		}()
+	}
+	wg.Wait()
+
+	restored, err := NewCookieJar(&CookieJarOptions{Store: newStore()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.entries) != 52 {
+		t.Fatalf("restored %d cookies, want 52", len(restored.entries))
+	}
+	for _, sc := range restored.entries {
+		if sc.Name == "evil" {
+			t.Fatalf("rejected cookie persisted: %+v", sc)
+		}
+	}
+	admin, _ := url.Parse("https://shop.example.com.cn/admin/users")
+	if got := restored.Cookies(admin); len(got) != 52 || got[0].Name != "admin" {
+		t.Errorf("admin cookies = %v", got)
+	}
+}
+
+// 测试保存到文件后重新加载
+func TestCookieJar_FileStore(t *testing.T) {
+	srv := cookieServer()
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	jar, _ := NewCookieJar(&CookieJarOptions{Store: NewFileCookieStore(path), AutoSave: true})
+	client, _ := NewClient(srv.URL).CookieJar(jar).Build()
+	resp, _ := client.Get("/login?user=bob")
+	resp.Body.Close()
+
+	restored, err := NewCookieJar(&CookieJarOptions{Store: NewFileCookieStore(path)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client2, _ := NewClient(srv.URL).CookieJar(restored).Build()
+	if got := whoami(t, client2); got != "sid=bob;tmp=1" {
+		t.Errorf("restored cookies = %q", got)
+	}
+
+	// 未保存过时 Load 返回空
+	empty, err := NewCookieJar(&CookieJarOptions{Store: NewFileCookieStore(filepath.Join(t.TempDir(), "none.json"))})
+	if err != nil || empty == nil {
+		t.Fatalf("jar=%v err=%v", empty, err)
+	}
+}
+
+// 测试保存到 store.AdapterCache
+func TestCookieJar_CacheStore(t *testing.T) {
+	srv := cookieServer()
+	defer srv.Close()
+
+	cache := store.NewMemory()
+	cs := NewCacheCookieStore(cache, "cookies:bob", 3600)
+	jar, _ := NewCookieJar(&CookieJarOptions{Store: cs})
+	client, _ := NewClient(srv.URL).CookieJar(jar).Build()
+	resp, _ := client.Get("/login?user=bob")
+	resp.Body.Close()
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewCookieJar(&CookieJarOptions{Store: NewCacheCookieStore(cache, "cookies:bob", 3600)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL)
+	if got := restored.Cookies(u); len(got) != 2 {
+		t.Errorf("restored = %v", got)
+	}
+	if err := (&CookieJar{}).Save(); err == nil {
+		t.Error("Save without store should fail")
+	}
+}
+
+// 测试 Session 之间 Cookie 相互隔离
+func TestHTTPClient_Session(t *testing.T) {
+	srv := cookieServer()
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Build()
+	alice, bob := client.Session(nil), client.Session(nil)
+	resp, _ := alice.Get("/login?user=alice")
+	resp.Body.Close()
+	resp, _ = bob.Get("/login?user=bob")
+	resp.Body.Close()
+
+	if got := whoami(t, alice); got != "sid=alice;tmp=1" {
+		t.Errorf("alice = %q", got)
+	}
+	if got := whoami(t, bob); got != "sid=bob;tmp=1" {
+		t.Errorf("bob = %q", got)
+	}
+	if got := whoami(t, client); got != "" {
+		t.Errorf("base client = %q", got)
+	}
+	if alice.raw.Transport != client.raw.Transport {
+		t.Error("session should share the transport")
+	}
+}
+
+// 测试共享 ResponseCache 的 Session 之间不会命中对方的缓存：
+// Jar 中的 Cookie 由 http.Client 在发送时才加入请求头，缓存键必须在查找前就计入这些 Cookie
+func TestHTTPClient_SessionResponseCache(t *testing.T) {
+	srv := cookieServer()
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).ResponseCache(NewResponseCache(time.Minute)).Build()
+	anonymous, alice, bob := client.Session(nil), client.Session(nil), client.Session(nil)
+	for user, s := range map[string]*HTTPClient{"alice": alice, "bob": bob} {
+		resp, _ := s.Get("/login?user=" + user)
+		resp.Body.Close()
+	}
+	for range 2 {
+		if got := whoami(t, anonymous, "?cache=1"); got != "" {
+			t.Errorf("anonymous = %q", got)
+		}
+		if got := whoami(t, alice, "?cache=1"); got != "sid=alice;tmp=1" {
+			t.Errorf("alice = %q", got)
+		}
+		if got := whoami(t, bob, "?cache=1"); got != "sid=bob;tmp=1" {
+			t.Errorf("bob = %q", got)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package k
+
+// http_dial.go —— 连接建立：自定义拨号、Unix Socket、h2c、DNS 缓存与 Happy Eyeballs
+//
+// 设计目标：
+//   - DialContext 替换底层拨号函数（如经 SSH 隧道、VPN、测试中的内存连接），DNS 缓存与 Unix Socket 仍在其上生效
+//   - baseURL 为 unix:///var/run/docker.sock 时所有请求经该 Socket 发送，请求 Host 为 localhost
+//   - H2C 以明文 HTTP/2（prior knowledge）访问 http:// 地址，适合内部 gRPC-gateway；https:// 照常协商 HTTP/2 或 HTTP/1.1
+//   - DNSCache 按 TTL 缓存解析结果，可固定（Pin）主机到指定 IP；解析失败时使用过期结果，连接全部失败时清除缓存
+//   - HappyEyeballs 设置 IPv6 / IPv4 竞速的回退延迟（RFC 6555），使用 DNS 缓存时同样生效
+//   - DialTimeout、KeepAlive 对自定义 DialContext 同样生效：拨号受超时约束，返回的 TCP 连接开启 keep-alive
+//
+// 示例：
+//
+//	docker, _ := NewClient("unix:///var/run/docker.sock").Build()
+//	resp, _ := docker.Get("/v1.43/containers/json")
+//
+//	dns := NewDNSCache(time.Minute)
+//	dns.Pin("api.internal", "10.0.0.8", "10.0.0.9")
+//	client, _ := NewClient("http://api.internal:8080").H2C().DNSCache(dns).HappyEyeballs(100 * time.Millisecond).Build()
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DialFunc 建立网络连接，签名与 net.Dialer.DialContext 相同
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// defaultFallbackDelay 与 net.Dialer 的默认 Happy Eyeballs 回退延迟一致
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// DialTimeout 设置建立 TCP / Unix 连接的超时时间，对内置拨号器与 DialContext 均生效。
+//
+// 参数：
+//   - d: 超时时长，例如 5*time.Second。设为 0 表示不限制（仍受请求 context 约束）。
+//
+// 默认值：30s
+func (b *ClientBuilder) DialTimeout(d time.Duration) *ClientBuilder {
+	b.dialTimeout = d
+	return b
+}
+
+// DialContext 替换底层拨号函数。Unix Socket、DNS 缓存与 Happy Eyeballs 在其上生效；
+// 每次调用 fn 的 ctx 带有 DialTimeout 超时，fn 返回 *net.TCPConn 时按 KeepAlive 设置 keep-alive。设置了 Transport 时不生效。
+//
+// 参数：
+//   - fn: 拨号函数，nil 表示使用内置 net.Dialer
+func (b *ClientBuilder) DialContext(fn DialFunc) *ClientBuilder {
+	b.dialFn = fn
+	return b
+}
+
+// UnixSocket 所有请求经指定的 Unix Socket 发送，baseURL 仍决定请求路径前缀与 Host。
+// 也可直接使用 NewClient("unix:///path/to.sock")，此时 Host 为 localhost。
+//
+// 参数：
+//   - path: Socket 文件路径，例如 "/var/run/docker.sock"
+func (b *ClientBuilder) UnixSocket(path string) *ClientBuilder {
+	b.unixSocket = path
+	return b
+}
+
+// H2C 对 http:// 地址使用明文 HTTP/2（prior knowledge，不经过 Upgrade 协商），服务端须支持 h2c。
+// https:// 地址不受影响，仍通过 TLS + ALPN 协商 HTTP/2 或 HTTP/1.1。
+// 配置了 Proxy / ProxyPool 时不生效（HTTP 代理只转发 HTTP/1.1），设置了 Transport 时同样不生效。
+func (b *ClientBuilder) H2C() *ClientBuilder {
+	b.h2c = true
+	return b
+}
+
+// DNSCache 使用带 TTL 的 DNS 缓存解析主机名，同一 DNSCache 可在多个客户端间共享。
+//
+// 参数：
+//   - cache: NewDNSCache 创建的缓存，nil 表示每次连接都解析
+func (b *ClientBuilder) DNSCache(cache *DNSCache) *ClientBuilder {
+	b.dnsCache = cache
+	return b
+}
+
+// HappyEyeballs 设置双栈主机 IPv6 / IPv4 竞速的回退延迟：首选地址族在该时间内未连上时并行尝试另一地址族。
+//
+// 参数：
+//   - fallbackDelay: 回退延迟，0 使用默认值 300ms，负值表示禁用竞速（按顺序逐个尝试）
+func (b *ClientBuilder) HappyEyeballs(fallbackDelay time.Duration) *ClientBuilder {
+	b.fallbackDelay = fallbackDelay
+	return b
+}
+
+// parseUnixBaseURL 将 unix:///path/to.sock 形式的 baseURL 拆为 Socket 路径，请求地址改为 http://localhost
+func (b *ClientBuilder) parseUnixBaseURL() error {
+	if !strings.HasPrefix(b.baseURL, "unix://") {
+		return nil
+	}
+	u, err := url.Parse(b.baseURL)
+	if err != nil || u.Path == "" || u.Host != "" {
+		return fmt.Errorf("invalid unix socket baseURL %q, expected unix:///path/to.sock", b.baseURL)
+	}
+	b.unixSocket = u.Path
+	b.baseURL = "http://localhost"
+	return nil
+}
+
+// dialer 按 DialContext → Unix Socket / DNS 缓存的顺序组装 Transport 使用的拨号函数
+func (b *ClientBuilder) dialer() DialFunc {
+	dial := DialFunc((&net.Dialer{
+		Timeout:       b.dialTimeout,
+		KeepAlive:     b.keepAlive,
+		FallbackDelay: b.fallbackDelay,
+	}).DialContext)
+	if b.dialFn != nil {
+		dial = b.customDialer()
+	}
+	if b.unixSocket != "" {
+		path := b.unixSocket
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, "unix", path)
+		}
+	}
+	if b.dnsCache != nil {
+		return b.dnsCache.dialer(dial, b.fallbackDelay)
+	}
+	return dial
+}
+
+// customDialer 为 DialContext 设置的拨号函数补上 DialTimeout 与 KeepAlive
+func (b *ClientBuilder) customDialer() DialFunc {
+	fn, timeout, keepAlive := b.dialFn, b.dialTimeout, b.keepAlive
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		conn, err := fn(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if tc, ok := conn.(*net.TCPConn); ok && keepAlive != 0 {
+			// 与 net.Dialer 一致：正值为探测间隔，负值关闭 keep-alive
+			if keepAlive < 0 {
+				_ = tc.SetKeepAlive(false)
+			} else if tc.SetKeepAlive(true) == nil {
+				_ = tc.SetKeepAlivePeriod(keepAlive)
+			}
+		}
+		return conn, nil
+	}
+}
+
+// h2cTransport http:// 请求经只启用明文 HTTP/2 的 Transport 发送，其余请求交给 next，
+// 因此 https:// 地址与不支持 HTTP/2 的服务端不受 H2C 影响
+type h2cTransport struct {
+	h2c  *http.Transport
+	next http.RoundTripper
+}
+
+// newH2CTransport 基于 base 复制一份只启用明文 HTTP/2 的 Transport
+func newH2CTransport(base *http.Transport, next http.RoundTripper) *h2cTransport {
+	h2c := base.Clone()
+	h2c.Protocols = new(http.Protocols)
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+	return &h2cTransport{h2c: h2c, next: next}
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// CloseIdleConnections 关闭两个 Transport 的空闲连接
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// ─── DNS 缓存 ─────────────────────────────────────────
+
+// DNSCache 按 TTL 缓存主机名解析结果，并发安全，通过 NewDNSCache 创建。
+type DNSCache struct {
+	// Lookup 解析函数，默认 net.DefaultResolver.LookupHost
+	Lookup func(ctx context.Context, host string) ([]string, error)
+
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time // 零值表示固定（Pin），永不过期
+}
+
+// NewDNSCache 创建 DNS 缓存。
+//
+// 参数：
+//   - ttl: 解析结果的有效期，例如 time.Minute
+func NewDNSCache(ttl time.Duration) *DNSCache {
+	return &DNSCache{
+		Lookup:  net.DefaultResolver.LookupHost,
+		ttl:     ttl,
+		entries: make(map[string]*dnsEntry),
+	}
+}
+
+// Pin 将主机固定解析到指定 IP，不过期、不会被连接失败清除；不传 addrs 时取消固定。
+func (d *DNSCache) Pin(host string, addrs ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	host = strings.ToLower(host)
+	if len(addrs) == 0 {
+		delete(d.entries, host)
+		return
+	}
+	d.entries[host] = &dnsEntry{addrs: addrs}
+}
+
+// LookupHost 返回主机的 IP 列表，缓存未命中或过期时重新解析；解析失败但有过期结果时返回过期结果。
+func (d *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(host)
+	d.mu.Lock()
+	e := d.entries[host]
+	d.mu.Unlock()
+	if e != nil && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		return e.addrs, nil
+	}
+
+	addrs, err := d.Lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		if e != nil {
+			return e.addrs, nil // 解析失败时继续使用过期结果
+		}
+		if err == nil {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, err
+	}
+	d.mu.Lock()
+	d.entries[host] = &dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+// forget 清除非固定的缓存条目，下次连接重新解析
+func (d *DNSCache) forget(host string) {
+	host = strings.ToLower(host)
+	d.mu.Lock()
+	if e := d.entries[host]; e != nil && !e.expires.IsZero() {
+		delete(d.entries, host)
+	}
+	d.mu.Unlock()
+}
+
+// dialer 用缓存的解析结果拨号，IP 地址直接拨号
+func (d *DNSCache) dialer(dial DialFunc, fallbackDelay time.Duration) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := d.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		primaries, fallbacks := partitionAddrs(network, ips)
+		if len(primaries) == 0 {
+			return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+		}
+		conn, err := dialHappyEyeballs(ctx, dial, network, port, primaries, fallbacks, fallbackDelay)
+		if err != nil {
+			d.forget(host)
+		}
+		return conn, err
+	}
+}
+
+// partitionAddrs 按网络类型过滤地址，并按首个地址的地址族分为首选与回退两组
+func partitionAddrs(network string, ips []string) (primaries, fallbacks []string) {
+	var first *bool
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		v4 := ip.To4() != nil
+		if (network == "tcp4" && !v4) || (network == "tcp6" && v4) {
+			continue
+		}
+		if first == nil {
+			first = &v4
+		}
+		if v4 == *first {
+			primaries = append(primaries, s)
+		} else {
+			fallbacks = append(fallbacks, s)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialHappyEyeballs 先按顺序尝试首选地址，fallbackDelay 后（或首选全部失败时）并行尝试回退地址，返回最先成功的连接
+func dialHappyEyeballs(ctx context.Context, dial DialFunc, network, port string, primaries, fallbacks []string, fallbackDelay time.Duration) (net.Conn, error) {
+	if fallbackDelay < 0 || len(fallbacks) == 0 {
+		return dialSerial(ctx, dial, network, port, append(primaries, fallbacks...))
+	}
+	if fallbackDelay == 0 {
+		fallbackDelay = defaultFallbackDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 2)
+	start := func(addrs []string) {
+		go func() {
+			conn, err := dialSerial(ctx, dial, network, port, addrs)
+			results <- result{conn, err}
+		}()
+	}
+
+	start(primaries)
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+	pending, fallbackStarted := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					go func() { // 另一组随后连上时关闭
+						if o := <-results; o.conn != nil {
+							o.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks)
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dialSerial 按顺序尝试每个地址，返回首个成功的连接或第一个错误
+func dialSerial(ctx context.Context, dial DialFunc, network, port string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range addrs {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Join(firstErr, err)
+		}
+		conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,215 @@
+package k
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试 unix:// baseURL
+func TestDial_UnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets unsupported:", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+" "+r.URL.Path)
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client, err := NewClient("unix://" + sock).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("/v1.43/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := client.ReadBodyString(resp); s != "localhost /v1.43/containers/json" {
+		t.Errorf("body = %q", s)
+	}
+
+	// UnixSocket 与自定义 baseURL 组合
+	client, _ = NewClient("http://docker/v1.43").UnixSocket(sock).Build()
+	resp, err = client.Get("/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := client.ReadBodyString(resp); s != "docker /v1.43/info" {
+		t.Errorf("body = %q", s)
+	}
+
+	if _, err := NewClient("unix://host/x.sock").Build(); err == nil {
+		t.Error("expected error for unix URL with host")
+	}
+}
+
+// 测试 h2c
+func TestDial_H2C(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).H2C().Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := client.ReadBodyString(resp); s != "HTTP/2.0" {
+		t.Errorf("proto = %q", s)
+	}
+
+	plain, _ := NewClient(srv.URL).Build()
+	resp, _ = plain.Get("/")
+	if s, _ := plain.ReadBodyString(resp); s != "HTTP/1.1" {
+		t.Errorf("default proto = %q", s)
+	}
+
+	// https:// 只支持 HTTP/1.1 的服务端不受 H2C 影响
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	defer tlsSrv.Close()
+	secure, _ := NewClient(tlsSrv.URL).H2C().TLS(tlsSrv.Client().Transport.(*http.Transport).TLSClientConfig).Build()
+	resp, err = secure.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := secure.ReadBodyString(resp); s != "HTTP/1.1" {
+		t.Errorf("https proto = %q", s)
+	}
+}
+
+// 测试自定义拨号函数同样受 DialTimeout 约束
+func TestDial_CustomDialTimeout(t *testing.T) {
+	client, _ := NewClient("http://blackhole.test").
+		Timeout(5 * time.Second).
+		DialTimeout(50 * time.Millisecond).
+		DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, errors.New("no dial deadline")
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).
+		Build()
+	start := time.Now()
+	_, err := client.Get("/")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+		t.Fatalf("err = %v after %v", err, time.Since(start))
+	}
+}
+
+// 测试 DNS 缓存 TTL、固定解析与自定义拨号
+func TestDial_DNSCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close") // 每个请求都重新拨号
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	var lookups, dials atomic.Int32
+	dns := NewDNSCache(50 * time.Millisecond)
+	dns.Lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		if host == "api.test" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, errors.New("nxdomain")
+	}
+	dns.Pin("pinned.test", "127.0.0.1")
+	client, _ := NewClient("").
+		DNSCache(dns).
+		DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}).
+		Build()
+
+	get := func(host string) error {
+		resp, err := client.Get("http://" + host + ":" + port + "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if err := get("api.test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups.Load() != 1 || dials.Load() != 3 {
+		t.Errorf("lookups=%d dials=%d", lookups.Load(), dials.Load())
+	}
+	time.Sleep(60 * time.Millisecond)
+	get("api.test")
+	if lookups.Load() != 2 {
+		t.Errorf("lookups after ttl = %d", lookups.Load())
+	}
+
+	if err := get("pinned.test"); err != nil || lookups.Load() != 2 {
+		t.Errorf("pinned: err=%v lookups=%d", err, lookups.Load())
+	}
+	if err := get("missing.test"); err == nil || !strings.Contains(err.Error(), "nxdomain") {
+		t.Errorf("missing: %v", err)
+	}
+}
+
+// 测试 Happy Eyeballs：首选地址无响应时回退地址胜出
+func TestDial_HappyEyeballs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	dns := NewDNSCache(time.Minute)
+	dns.Pin("dual.test", "fd00::1", "127.0.0.1")
+	blackhole := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if strings.HasPrefix(addr, "[fd00::1]") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	client, _ := NewClient("http://dual.test:" + port).
+		DNSCache(dns).
+		DialContext(blackhole).
+		HappyEyeballs(20 * time.Millisecond).
+		Timeout(2 * time.Second).
+		Build()
+
+	start := time.Now()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("fallback took %v", d)
+	}
+
+	// 禁用竞速时按顺序尝试，首选地址阻塞直到超时
+	serial, _ := NewClient("http://dual.test:" + port).
+		DNSCache(dns).
+		DialContext(blackhole).
+		HappyEyeballs(-1).
+		Timeout(100 * time.Millisecond).
+		Build()
+	if _, err := serial.Get("/"); err == nil {
+		t.Error("expected timeout without happy eyeballs")
+	}
+}
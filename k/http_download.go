@@ -0,0 +1,332 @@
+package k
+
+// http_download.go —— 流式下载到磁盘
+//
+// 设计目标：
+//   - 响应体边读边写入临时文件，大文件不占用内存，也不受客户端 Timeout 限制
+//   - 传输中断后通过 Range 请求从断点续传；临时文件保留时，下次调用同样从断点继续
+//     （ETag / Last-Modified 保存在临时文件旁，续传时以 If-Range 确认资源未变化）
+//   - 完成后 fsync + rename 原子落盘，目标文件要么完整要么不存在
+//   - 可选 SHA-256 / Content-MD5 校验
+//   - 每个分段请求都经过客户端的限速 / 熔断 / 重试流水线
+//
+// 示例：
+//
+//	n, err := client.Download("/exports/2024.csv", "/data/2024.csv", &DownloadOptions{
+//	    SHA256:   "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//	    Progress: func(done, total int64) { fmt.Printf("%d/%d\n", done, total) },
+//	})
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChecksumError 下载完成后校验和不匹配时返回此错误，临时文件会被删除。
+type ChecksumError struct {
+	Algorithm string // "sha256" 或 "md5"
+	Expected  string // 期望值
+	Actual    string // 实际计算值
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// DownloadOptions Download 的可选参数，传 nil 使用默认值。
+type DownloadOptions struct {
+	// Request 附加的请求级配置（header、query、context 等），不需要时为 nil
+	Request *RequestBuilder
+	// Progress 进度回调，done 为已写入目标文件的总字节数（含续传前已有部分）
+	Progress ProgressFunc
+	// MaxResumes 传输中断后最多续传次数，默认 3；设为负值禁用续传
+	MaxResumes int
+	// SHA256 期望的文件 SHA-256（十六进制），为空时不校验
+	SHA256 string
+	// VerifyContentMD5 为 true 时若服务端返回了 Content-MD5 头则校验完整文件的 MD5
+	VerifyContentMD5 bool
+	// Perm 目标文件权限，默认 0644
+	Perm os.FileMode
+}
+
+// Download 以 GET 方式下载 path 指向的资源并写入 dest。
+//
+// 下载先写入 dest + ".download" 临时文件，完成并校验通过后原子重命名为 dest。
+// 若临时文件已存在（上次下载中断），会通过 Range + If-Range 请求从已有长度继续下载，
+// 校验值（ETag / Last-Modified）保存在 dest + ".download.validator"；缺少校验值时无法确认资源未变化，从头开始。
+// 服务端不支持 Range 或资源已变化（返回 200）时自动从头开始。
+//
+// 参数：
+//   - path: 请求路径，拼接在 baseURL 之后
+//   - dest: 目标文件路径，所在目录不存在时自动创建
+//   - opts: 下载选项，可为 nil
+//
+// 返回：
+//   - int64: 文件总字节数
+func (c *HTTPClient) Download(path, dest string, opts *DownloadOptions) (int64, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	maxResumes := opts.MaxResumes
+	if maxResumes == 0 {
+		maxResumes = 3
+	}
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0o644
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return 0, err
+	}
+	tmp := dest + ".download"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	// restart 清空临时文件，从头下载
+	restart := func() error {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err := f.Seek(0, io.SeekStart)
+		offset = 0
+		return err
+	}
+
+	var (
+		validator  string // ETag / Last-Modified，用于续传时的 If-Range
+		contentMD5 string
+		total      int64 = -1
+	)
+	validatorFile := tmp + ".validator"
+	if offset > 0 {
+		if b, err := os.ReadFile(validatorFile); err == nil {
+			validator = strings.TrimSpace(string(b))
+		}
+		if validator == "" {
+			if err = restart(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for resumes := 0; ; resumes++ {
+		resp, err := c.do(http.MethodGet, path, nil, "", downloadRequest(opts.Request, offset, validator))
+		if err != nil {
+			return offset, err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+			if !ok || start != offset {
+				resp.Body.Close()
+				return offset, fmt.Errorf("download: unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), offset)
+			}
+			total = size
+		case resp.StatusCode == http.StatusOK:
+			// 服务端忽略了 Range 或资源已变化，从头开始
+			if offset > 0 {
+				if err = restart(); err != nil {
+					resp.Body.Close()
+					return 0, err
+				}
+			}
+			total = resp.ContentLength
+			contentMD5 = resp.Header.Get("Content-MD5")
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+			// Content-Range: bytes */N 且 N 等于已有长度时，临时文件已是完整内容（上次中断在 rename 之前），交由下方校验；
+			// 否则资源已变化，从头开始
+			resp.Body.Close()
+			sizeStr, found := strings.CutPrefix(strings.TrimSpace(resp.Header.Get("Content-Range")), "bytes */")
+			if size, err := strconv.ParseInt(sizeStr, 10, 64); !found || err != nil || size != offset {
+				if err = restart(); err != nil {
+					return 0, err
+				}
+				validator = ""
+				continue // 不带 Range 重新请求，不会再次进入此分支
+			}
+			total = offset
+		default:
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return offset, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			validator = resp.Header.Get("ETag")
+			if validator == "" {
+				validator = resp.Header.Get("Last-Modified")
+			}
+			// 先落盘校验值再写入内容，进程中断后下次调用仍能安全续传
+			if validator == "" {
+				err = os.Remove(validatorFile)
+				if os.IsNotExist(err) {
+					err = nil
+				}
+			} else {
+				err = os.WriteFile(validatorFile, []byte(validator), perm)
+			}
+			if err != nil {
+				resp.Body.Close()
+				return offset, err
+			}
+		}
+
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			var dst io.Writer = f
+			if opts.Progress != nil {
+				dst = &progressWriter{w: f, done: offset, total: total, fn: opts.Progress}
+			}
+			n, copyErr := io.Copy(dst, resp.Body)
+			resp.Body.Close()
+			offset += n
+			if copyErr != nil || (total >= 0 && offset < total) {
+				if resumes >= maxResumes || maxResumes < 0 {
+					if copyErr == nil {
+						copyErr = io.ErrUnexpectedEOF
+					}
+					return offset, fmt.Errorf("download interrupted at %d bytes: %w", offset, copyErr)
+				}
+				continue // 断点续传
+			}
+		}
+		break
+	}
+
+	if err = f.Sync(); err != nil {
+		return offset, err
+	}
+	if err = verifyDownload(tmp, opts, contentMD5); err != nil {
+		f.Close()
+		_ = os.Remove(tmp)
+		_ = os.Remove(validatorFile)
+		return offset, err
+	}
+	if err = f.Close(); err != nil {
+		return offset, err
+	}
+	if err = os.Rename(tmp, dest); err != nil {
+		return offset, err
+	}
+	_ = os.Remove(validatorFile)
+	return offset, nil
+}
+
+// downloadRequest 基于调用方的 RequestBuilder 复制一份配置，附加 Range / If-Range，
+// 并强制 identity 编码以保证字节偏移与磁盘文件一致
+func downloadRequest(base *RequestBuilder, offset int64, validator string) *RequestBuilder {
+	r := base.clone()
+	r.cfg.headers["Accept-Encoding"] = "identity"
+	if offset > 0 {
+		r.cfg.headers["Range"] = "bytes=" + strconv.FormatInt(offset, 10) + "-"
+		if validator != "" {
+			r.cfg.headers["If-Range"] = validator
+		}
+	}
+	r.cfg.expectStatus = nil
+	r.cfg.stream = true
+	return r
+}
+
+// parseContentRange 解析 "bytes start-end/size"，size 为 "*" 时返回 -1
+func parseContentRange(v string) (start, size int64, ok bool) {
+	v, found := strings.CutPrefix(strings.TrimSpace(v), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, sizeStr, found := strings.Cut(v, "/")
+	if !found {
+		return 0, 0, false
+	}
+	startStr, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size = -1
+	if sizeStr != "*" {
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
+
+// verifyDownload 对完整的临时文件计算校验和
+func verifyDownload(path string, opts *DownloadOptions, contentMD5 string) error {
+	checkMD5 := opts.VerifyContentMD5 && contentMD5 != ""
+	if opts.SHA256 == "" && !checkMD5 {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sha, sum := sha256.New(), md5.New()
+	writers := make([]io.Writer, 0, 2)
+	if opts.SHA256 != "" {
+		writers = append(writers, sha)
+	}
+	if checkMD5 {
+		writers = append(writers, sum)
+	}
+	if _, err = io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return err
+	}
+	if opts.SHA256 != "" {
+		if actual := hex.EncodeToString(sha.Sum(nil)); !strings.EqualFold(actual, opts.SHA256) {
+			return &ChecksumError{Algorithm: "sha256", Expected: opts.SHA256, Actual: actual}
+		}
+	}
+	if checkMD5 {
+		if actual := encodeSum(sum); actual != contentMD5 {
+			return &ChecksumError{Algorithm: "md5", Expected: contentMD5, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// encodeSum 按 Content-MD5 规范（RFC 1864）以 Base64 编码摘要
+func encodeSum(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// progressWriter 在写入时累计字节数并触发进度回调
+type progressWriter struct {
+	w     io.Writer
+	done  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.fn(p.done, p.total)
+	}
+	return n, err
+}
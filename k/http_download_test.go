@@ -0,0 +1,148 @@
+package k
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 测试下载中断后通过 Range 续传、SHA-256 校验并原子落盘
+func TestClient_DownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 50000)
+	sum := sha256.Sum256(content)
+
+	ranged := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			// 首次请求只写一半后断开连接
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		ranged++
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Build()
+	dest := filepath.Join(t.TempDir(), "sub", "file.bin")
+
+	var lastDone int64
+	n, err := client.Download("/file.bin", dest, &DownloadOptions{
+		SHA256:   hex.EncodeToString(sum[:]),
+		Progress: func(done, total int64) { lastDone = done },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || lastDone != n {
+		t.Errorf("n=%d lastDone=%d", n, lastDone)
+	}
+	if ranged != 1 {
+		t.Errorf("expected 1 ranged request, got %d", ranged)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded content mismatch")
+	}
+	if _, err = os.Stat(dest + ".download"); !os.IsNotExist(err) {
+		t.Error("temp file should be renamed")
+	}
+}
+
+// 测试校验和不匹配时返回 ChecksumError 且不生成目标文件
+func TestClient_DownloadChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Build()
+	dest := filepath.Join(t.TempDir(), "hello.txt")
+	_, err := client.Download("/", dest, &DownloadOptions{SHA256: "00"})
+	var ce *ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected ChecksumError, got %v", err)
+	}
+	if ok, _ := PathExists(dest); ok {
+		t.Error("dest should not exist")
+	}
+	if ok, _ := PathExists(dest + ".download"); ok {
+		t.Error("temp file should be removed")
+	}
+}
+
+// 测试跨调用续传：校验值随临时文件保存并以 If-Range 发送，资源变化、416 长度不符或缺少校验值时从头下载
+func TestClient_DownloadResumeAcrossCalls(t *testing.T) {
+	var (
+		content = bytes.Repeat([]byte("v1"), 5000)
+		etag    = `"v1"`
+		abort   = true
+		ranges  []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", etag)
+		if abort {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Build()
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	if _, err := client.Download("/file.bin", dest, &DownloadOptions{MaxResumes: -1}); err == nil {
+		t.Fatal("expected interrupted download")
+	}
+
+	// 资源已变化：If-Range 不匹配，服务端返回完整的新内容
+	content, etag, abort = bytes.Repeat([]byte("v2"), 4000), `"v2"`, false
+	if _, err := client.Download("/file.bin", dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Fatalf("content mismatch after resource change (len %d)", len(got))
+	}
+	if ranges[1] != `bytes=5000-|"v1"` {
+		t.Fatalf("resume request Range|If-Range = %q", ranges[1])
+	}
+	if ok, _ := PathExists(dest + ".download.validator"); ok {
+		t.Error("validator file should be removed")
+	}
+
+	// 临时文件比资源更长：416 的 Content-Range 长度不符，从头下载
+	content = []byte("short")
+	_ = os.WriteFile(dest+".download", bytes.Repeat([]byte("x"), 20), 0o644)
+	_ = os.WriteFile(dest+".download.validator", []byte(etag), 0o644)
+	if _, err := client.Download("/file.bin", dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "short" {
+		t.Fatalf("after 416 got %q", got)
+	}
+
+	// 没有校验值的临时文件不续传
+	ranges = nil
+	_ = os.WriteFile(dest+".download", []byte("sh"), 0o644)
+	if _, err := client.Download("/file.bin", dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "short" || len(ranges) != 1 || ranges[0] != "|" {
+		t.Fatalf("got %q, requests %q", got, ranges)
+	}
+}
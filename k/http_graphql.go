@@ -0,0 +1,295 @@
+package k
+
+// http_graphql.go —— 基于 HTTPClient 的 GraphQL 客户端：查询、变更、持久化查询、SSE 订阅
+//
+// 设计目标：
+//   - GraphQLQuery / GraphQLMutate 以泛型解码 data，变量可为结构体或 map
+//   - 响应中的 errors[] 以 GraphQLErrors 返回（errors.As 识别），部分成功时同时返回已解码的 data
+//   - 支持 Apollo 自动持久化查询（APQ）：先只发送查询的 SHA-256，服务端未缓存时再发送完整查询；查询可改用 GET 便于 CDN 缓存
+//   - GraphQLSubscribe 按 GraphQL over SSE（distinct connections 模式）消费订阅，逐条产出 iter.Seq2[T, error]
+//   - 请求经过 HTTPClient 的完整流程（鉴权、签名、限速、熔断、重试、日志、钩子）
+//
+// 示例：
+//
+//	gql := NewGraphQLClient(client, "/graphql")
+//	gql.PersistedQueries = true
+//
+//	type Data struct {
+//		User struct{ ID, Name string } `json:"user"`
+//	}
+//	data, err := GraphQLQuery[Data](ctx, gql, `query($id: ID!) { user(id: $id) { id name } }`, map[string]any{"id": "42"})
+//	var gqlErrs GraphQLErrors
+//	if errors.As(err, &gqlErrs) { ... }
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+)
+
+// GraphQLRequest 一次 GraphQL 操作
+type GraphQLRequest struct {
+	Query         string         `json:"query,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     any            `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation 错误在查询文本中的位置
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError errors[] 中的一项
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// Code 返回 extensions.code（如 "UNAUTHENTICATED"），没有时为空
+func (e *GraphQLError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// GraphQLErrors 响应中的 errors[]，作为 error 返回
+type GraphQLErrors []*GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Message
+		if len(err.Path) > 0 {
+			msgs[i] += fmt.Sprintf(" (path %v)", err.Path)
+		}
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+// graphQLResponse GraphQL 响应体
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQLClient GraphQL 端点，并发安全，通过 NewGraphQLClient 创建。
+type GraphQLClient struct {
+	// PersistedQueries 为 true 时启用自动持久化查询（APQ）
+	PersistedQueries bool
+	// PersistedGET 为 true 时持久化查询（不含变更）使用 GET 发送，便于 CDN 缓存
+	PersistedGET bool
+	// Request 每个请求附加的配置（header 等），其中的 Context 会被调用时的 ctx 替代
+	Request *RequestBuilder
+
+	client *HTTPClient
+	path   string
+}
+
+// NewGraphQLClient 创建 GraphQL 客户端。
+//
+// 参数：
+//   - c:    发送请求的 HTTPClient
+//   - path: GraphQL 端点路径，例如 "/graphql"
+func NewGraphQLClient(c *HTTPClient, path string) *GraphQLClient {
+	return &GraphQLClient{client: c, path: path}
+}
+
+// GraphQLQuery 执行查询并将 data 解码为 T。存在 errors[] 时返回已解码的部分 data 与 GraphQLErrors。
+func GraphQLQuery[T any](ctx context.Context, g *GraphQLClient, query string, variables any) (T, error) {
+	var data T
+	err := g.Do(ctx, &GraphQLRequest{Query: query, Variables: variables}, &data)
+	return data, err
+}
+
+// GraphQLMutate 执行变更并将 data 解码为 T，始终使用 POST。
+func GraphQLMutate[T any](ctx context.Context, g *GraphQLClient, mutation string, variables any) (T, error) {
+	var data T
+	err := g.do(ctx, &GraphQLRequest{Query: mutation, Variables: variables}, &data, false)
+	return data, err
+}
+
+// Do 执行任意 GraphQL 操作并将 data 解码到 out（可为 nil）。
+// 查询在启用 PersistedGET 时使用 GET，其余使用 POST。
+func (g *GraphQLClient) Do(ctx context.Context, req *GraphQLRequest, out any) error {
+	return g.do(ctx, req, out, !isGraphQLMutation(req.Query))
+}
+
+func (g *GraphQLClient) do(ctx context.Context, req *GraphQLRequest, out any, allowGET bool) error {
+	if !g.PersistedQueries {
+		return g.send(ctx, req, out, false)
+	}
+	// APQ：先只发送哈希，服务端未缓存时再携带完整查询注册
+	hashOnly := *req
+	hashOnly.Query = ""
+	hashOnly.Extensions = persistedExtensions(req)
+	err := g.send(ctx, &hashOnly, out, allowGET && g.PersistedGET)
+	var gqlErrs GraphQLErrors
+	if !errors.As(err, &gqlErrs) || !persistedQueryNotFound(gqlErrs) {
+		return err
+	}
+	full := hashOnly
+	full.Query = req.Query
+	return g.send(ctx, &full, out, false)
+}
+
+// send 发送一次请求并解析响应
+func (g *GraphQLClient) send(ctx context.Context, req *GraphQLRequest, out any, get bool) error {
+	rb := g.Request.clone().Context(ctx).Accept("application/graphql-response+json", MediaTypeJSON)
+	var (
+		resp *http.Response
+		err  error
+	)
+	if get {
+		var params map[string]string
+		if params, err = graphQLQueryParams(req); err != nil {
+			return err
+		}
+		if rb.cfg.queryParams == nil {
+			rb.cfg.queryParams = make(map[string]string, len(params))
+		}
+		for k, v := range params {
+			rb.cfg.queryParams[k] = v
+		}
+		resp, err = g.client.Get(g.path, rb)
+	} else {
+		resp, err = g.client.PostJSON(g.path, req, rb)
+	}
+	if err != nil {
+		return err
+	}
+	body, err := g.client.ReadBody(resp)
+	if err != nil {
+		return err
+	}
+
+	var result graphQLResponse
+	if jsonErr := json.Unmarshal(body, &result); jsonErr != nil || (resp.StatusCode > 299 && len(result.Errors) == 0) {
+		if resp.StatusCode > 299 {
+			return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
+		return fmt.Errorf("graphql: decode response: %w", jsonErr)
+	}
+	if out != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("graphql: decode data: %w", err)
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+	return nil
+}
+
+// GraphQLSubscribe 通过 SSE 订阅，每收到一条结果产出一次；单条结果含 errors[] 时产出 GraphQLErrors 后继续。
+// 服务端发送 complete 事件或关闭连接时结束，不自动重新订阅。
+func GraphQLSubscribe[T any](ctx context.Context, g *GraphQLClient, subscription string, variables any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		events := g.client.StreamSSE(g.path, &StreamOptions{
+			Method:        http.MethodPost,
+			Body:          &GraphQLRequest{Query: subscription, Variables: variables},
+			Request:       g.Request.clone().Context(ctx),
+			MaxReconnects: -1,
+		})
+		for ev, err := range events {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			switch ev.Event {
+			case "complete":
+				return
+			case "next", "message":
+			default:
+				continue
+			}
+			var result graphQLResponse
+			if err := json.Unmarshal([]byte(ev.Data), &result); err != nil {
+				if !yield(zero, fmt.Errorf("graphql: decode event: %w", err)) {
+					return
+				}
+				continue
+			}
+			var data T
+			if len(result.Data) > 0 && string(result.Data) != "null" {
+				if err := json.Unmarshal(result.Data, &data); err != nil {
+					if !yield(zero, fmt.Errorf("graphql: decode data: %w", err)) {
+						return
+					}
+					continue
+				}
+			}
+			var resultErr error
+			if len(result.Errors) > 0 {
+				resultErr = result.Errors
+			}
+			if !yield(data, resultErr) {
+				return
+			}
+		}
+	}
+}
+
+// persistedExtensions 在原有 extensions 上加入 persistedQuery
+func persistedExtensions(req *GraphQLRequest) map[string]any {
+	sum := sha256.Sum256([]byte(req.Query))
+	ext := make(map[string]any, len(req.Extensions)+1)
+	for k, v := range req.Extensions {
+		ext[k] = v
+	}
+	ext["persistedQuery"] = map[string]any{"version": 1, "sha256Hash": hex.EncodeToString(sum[:])}
+	return ext
+}
+
+// persistedQueryNotFound 服务端未缓存该哈希（Apollo 约定的消息或错误码）
+func persistedQueryNotFound(errs GraphQLErrors) bool {
+	for _, e := range errs {
+		if e.Message == "PersistedQueryNotFound" || e.Code() == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
+
+// graphQLQueryParams GET 请求的 query 参数，variables / extensions 以 JSON 编码
+func graphQLQueryParams(req *GraphQLRequest) (map[string]string, error) {
+	params := map[string]string{}
+	if req.Query != "" {
+		params["query"] = req.Query
+	}
+	if req.OperationName != "" {
+		params["operationName"] = req.OperationName
+	}
+	for name, v := range map[string]any{"variables": req.Variables, "extensions": req.Extensions} {
+		if v == nil {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		params[name] = string(b)
+	}
+	return params, nil
+}
+
+// isGraphQLMutation 判断操作是否为变更（忽略开头的空白与注释）
+func isGraphQLMutation(query string) bool {
+	for {
+		query = strings.TrimSpace(query)
+		if !strings.HasPrefix(query, "#") {
+			break
+		}
+		_, query, _ = strings.Cut(query, "\n")
+	}
+	return strings.HasPrefix(query, "mutation")
+}
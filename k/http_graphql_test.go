@@ -0,0 +1,282 @@
+package k
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type gqlUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// gqlServer 解析 POST JSON 或 GET query 形式的 GraphQL 请求并交给 handle 处理
+func gqlServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, req map[string]any)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]any{}
+		if r.Method == http.MethodGet {
+			q := r.URL.Query()
+			for _, k := range []string{"query", "operationName"} {
+				if v := q.Get(k); v != "" {
+					req[k] = v
+				}
+			}
+			for _, k := range []string{"variables", "extensions"} {
+				if v := q.Get(k); v != "" {
+					var m map[string]any
+					json.Unmarshal([]byte(v), &m)
+					req[k] = m
+				}
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&req)
+		}
+		handle(w, r, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGraphQLQueryAndMutate(t *testing.T) {
+	srv := gqlServer(t, func(w http.ResponseWriter, r *http.Request, req map[string]any) {
+		if !strings.HasPrefix(r.Header.Get("Accept"), "application/graphql-response+json") || r.Header.Get("X-Tenant") != "t1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		vars, _ := req["variables"].(map[string]any)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(req["query"].(string), "mutation") {
+			fmt.Fprintf(w, `{"data":{"createUser":{"id":"7","name":%q}}}`, vars["name"])
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"user":{"id":%q,"name":"alice"}}}`, vars["id"])
+	})
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+	gql.Request = R().Headers(map[string]string{"X-Tenant": "t1"})
+
+	type queryData struct {
+		User gqlUser `json:"user"`
+	}
+	data, err := GraphQLQuery[queryData](context.Background(), gql, `query($id: ID!) { user(id: $id) { id name } }`, map[string]any{"id": "42"})
+	if err != nil || data.User != (gqlUser{"42", "alice"}) {
+		t.Fatalf("query = %+v, %v", data, err)
+	}
+
+	type input struct {
+		Name string `json:"name"`
+	}
+	created, err := GraphQLMutate[struct {
+		CreateUser gqlUser `json:"createUser"`
+	}](context.Background(), gql, `mutation($name: String!) { createUser(name: $name) { id name } }`, input{"bob"})
+	if err != nil || created.CreateUser != (gqlUser{"7", "bob"}) {
+		t.Fatalf("mutate = %+v, %v", created, err)
+	}
+}
+
+func TestGraphQLErrorsWithPartialData(t *testing.T) {
+	srv := gqlServer(t, func(w http.ResponseWriter, r *http.Request, req map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":{"user":{"id":"1","name":"alice"},"orders":null},
+			"errors":[{"message":"forbidden","locations":[{"line":1,"column":30}],"path":["orders"],"extensions":{"code":"FORBIDDEN"}}]}`)
+	})
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+
+	data, err := GraphQLQuery[struct {
+		User   gqlUser  `json:"user"`
+		Orders []string `json:"orders"`
+	}](context.Background(), gql, `{ user { id name } orders }`, nil)
+	var gqlErrs GraphQLErrors
+	if !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 {
+		t.Fatalf("err = %v", err)
+	}
+	e := gqlErrs[0]
+	if e.Code() != "FORBIDDEN" || e.Locations[0] != (GraphQLLocation{1, 30}) || e.Path[0] != "orders" {
+		t.Fatalf("error = %+v", e)
+	}
+	if err.Error() != "graphql: forbidden (path [orders])" {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+	if data.User.Name != "alice" {
+		t.Fatalf("partial data lost: %+v", data)
+	}
+}
+
+func TestGraphQLHTTPError(t *testing.T) {
+	srv := gqlServer(t, func(w http.ResponseWriter, r *http.Request, req map[string]any) {
+		if req["query"] == "{ denied }" {
+			w.Header().Set("Content-Type", "application/graphql-response+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":[{"message":"unauthenticated","extensions":{"code":"UNAUTHENTICATED"}}]}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "no such endpoint")
+	})
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+
+	err := gql.Do(context.Background(), &GraphQLRequest{Query: "{ denied }"}, nil)
+	var gqlErrs GraphQLErrors
+	if !errors.As(err, &gqlErrs) || gqlErrs[0].Code() != "UNAUTHENTICATED" {
+		t.Fatalf("err = %v", err)
+	}
+
+	err = gql.Do(context.Background(), &GraphQLRequest{Query: "{ other }"}, nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound || string(httpErr.Body) != "no such endpoint" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// apqServer 模拟 Apollo APQ：未注册的哈希返回 PersistedQueryNotFound
+func apqServer(t *testing.T, methods *[]string) *httptest.Server {
+	var mu sync.Mutex
+	cache := map[string]string{}
+	return gqlServer(t, func(w http.ResponseWriter, r *http.Request, req map[string]any) {
+		mu.Lock()
+		defer mu.Unlock()
+		ext, _ := req["extensions"].(map[string]any)
+		pq, _ := ext["persistedQuery"].(map[string]any)
+		hash, _ := pq["sha256Hash"].(string)
+		query, _ := req["query"].(string)
+		*methods = append(*methods, fmt.Sprintf("%s query=%t", r.Method, query != ""))
+
+		w.Header().Set("Content-Type", "application/json")
+		if query != "" {
+			sum := sha256.Sum256([]byte(query))
+			if hex.EncodeToString(sum[:]) != hash {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cache[hash] = query
+		}
+		if _, ok := cache[hash]; !ok {
+			fmt.Fprint(w, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"ok":true}}`)
+	})
+}
+
+func TestGraphQLPersistedQueries(t *testing.T) {
+	var methods []string
+	srv := apqServer(t, &methods)
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+	gql.PersistedQueries = true
+
+	type okData struct {
+		OK bool `json:"ok"`
+	}
+	for range 2 {
+		data, err := GraphQLQuery[okData](context.Background(), gql, `{ ok }`, nil)
+		if err != nil || !data.OK {
+			t.Fatalf("query = %+v, %v", data, err)
+		}
+	}
+	want := []string{"POST query=false", "POST query=true", "POST query=false"}
+	if fmt.Sprint(methods) != fmt.Sprint(want) {
+		t.Fatalf("requests = %v, want %v", methods, want)
+	}
+}
+
+func TestGraphQLPersistedGET(t *testing.T) {
+	var methods []string
+	srv := apqServer(t, &methods)
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+	gql.PersistedQueries, gql.PersistedGET = true, true
+
+	ctx := context.Background()
+	for range 2 {
+		if err := gql.Do(ctx, &GraphQLRequest{Query: "query Q { ok }", OperationName: "Q"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 变更不走 GET
+	if _, err := GraphQLMutate[map[string]any](ctx, gql, "mutation { ok }", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gql.Do(ctx, &GraphQLRequest{Query: "# comment\nmutation { ok }"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"GET query=false", "POST query=true", "GET query=false",
+		"POST query=false", "POST query=true",
+		"POST query=false", "POST query=true",
+	}
+	if fmt.Sprint(methods) != fmt.Sprint(want) {
+		t.Fatalf("requests = %v, want %v", methods, want)
+	}
+}
+
+func TestGraphQLSubscribe(t *testing.T) {
+	var subscribed atomic.Int32
+	srv := gqlServer(t, func(w http.ResponseWriter, r *http.Request, req map[string]any) {
+		subscribed.Add(1)
+		if r.Method != http.MethodPost || !strings.HasPrefix(req["query"].(string), "subscription") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: next\ndata: {\"data\":{\"tick\":1}}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "event: next\ndata: {\"data\":{\"tick\":2},\"errors\":[{\"message\":\"slow\"}]}\n\n")
+		fmt.Fprint(w, "event: next\ndata: {\"data\":{\"tick\":3}}\n\n")
+		fmt.Fprint(w, "event: complete\ndata:\n\n")
+		fmt.Fprint(w, "event: next\ndata: {\"data\":{\"tick\":4}}\n\n")
+	})
+	client, _ := NewClient(srv.URL).Build()
+	gql := NewGraphQLClient(client, "/graphql")
+
+	type tick struct {
+		Tick int `json:"tick"`
+	}
+	var got []string
+	for ev, err := range GraphQLSubscribe[tick](context.Background(), gql, `subscription { tick }`, nil) {
+		got = append(got, fmt.Sprintf("%d:%v", ev.Tick, err))
+	}
+	want := []string{"1:<nil>", "2:graphql: slow", "3:<nil>"}
+	if fmt.Sprint(got) != fmt.Sprint(want) || subscribed.Load() != 1 {
+		t.Fatalf("events = %v (subscribed %d), want %v", got, subscribed.Load(), want)
+	}
+
+	// 提前退出
+	n := 0
+	for range GraphQLSubscribe[tick](context.Background(), gql, `subscription { tick }`, nil) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Fatalf("break: got %d events", n)
+	}
+}
+
+func TestIsGraphQLMutation(t *testing.T) {
+	cases := map[string]bool{
+		"mutation { a }":               true,
+		"  \n# c1\n  # c2\nmutation M": true,
+		"query { a }":                  false,
+		"{ a }":                        false,
+		"subscription { a }":           false,
+		"# mutation\n{ a }":            false,
+	}
+	for q, want := range cases {
+		if got := isGraphQLMutation(q); got != want {
+			t.Errorf("isGraphQLMutation(%q) = %v", q, got)
+		}
+	}
+}
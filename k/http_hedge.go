@@ -0,0 +1,288 @@
+package k
+
+// http_hedge.go —— 对冲请求（hedged requests），降低长尾延迟
+//
+// 对幂等读请求，若首个请求在对冲延迟内没有返回，则再发出一个相同请求，
+// 采用最先返回的成功响应并取消其余请求（参见 "The Tail at Scale", Dean & Barroso）。
+//
+// 设计目标：
+//   - 对冲延迟可固定，也可取最近请求延迟的分位数（例如 P95），样本不足时回退到固定值
+//   - 对冲请求同样消耗限速器令牌；令牌不足时放弃对冲而不是排队等待，避免放大流量
+//   - 对冲次数与对冲胜出次数单独计入 Metrics / MetricsCollector
+//   - 只作用于单次尝试，与重试正交：每次重试尝试内部都可能对冲
+//
+// 示例：
+//
+//	client, _ := NewClient("https://replica.example.com").
+//	    Hedge(&HedgePolicy{Delay: 50 * time.Millisecond, Percentile: 0.95}).
+//	    Build()
+//
+//	client.Get("/report", R().Hedge(nil)) // 单个请求关闭对冲
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeSamples 延迟样本环形缓冲区大小
+const hedgeSamples = 256
+
+// HedgePolicy 对冲策略。策略实例内部统计延迟样本，不同后端建议使用不同实例。
+type HedgePolicy struct {
+	// Delay 首个请求发出后多久未返回即发出对冲请求；设置 Percentile 时作为样本不足时的回退值
+	Delay time.Duration
+	// Percentile 取最近请求延迟的分位数（0~1，例如 0.95）作为对冲延迟，为 0 时只使用 Delay
+	Percentile float64
+	// MinSamples 使用分位数前至少需要的样本数，默认 20
+	MinSamples int
+	// MaxHedges 单次尝试最多额外发出的对冲请求数，默认 1
+	MaxHedges int
+	// Methods 允许对冲的方法，默认 GET、HEAD、OPTIONS
+	Methods []string
+
+	mu      sync.Mutex
+	samples [hedgeSamples]time.Duration
+	n       int // 已写入的样本总数
+}
+
+// Hedge 为客户端的幂等请求启用对冲。
+//
+// 参数：
+//   - p: 对冲策略，为 nil 时关闭
+func (b *ClientBuilder) Hedge(p *HedgePolicy) *ClientBuilder {
+	b.hedge = p
+	return b
+}
+
+// Hedge 覆盖客户端的对冲策略，p 为 nil 时本次请求不对冲
+func (r *RequestBuilder) Hedge(p *HedgePolicy) *RequestBuilder {
+	r.cfg.hedge = p
+	r.cfg.hedgeSet = true
+	return r
+}
+
+// hedgePolicy 返回本次请求生效的对冲策略
+func (cfg *requestConfig) hedgePolicy(b *ClientBuilder) *HedgePolicy {
+	if cfg.hedgeSet {
+		return cfg.hedge
+	}
+	return b.hedge
+}
+
+// eligible 判断请求是否可以对冲：方法幂等且请求体可重放
+func (p *HedgePolicy) eligible(req *http.Request) bool {
+	if p == nil || (p.Delay <= 0 && p.Percentile <= 0) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	methods := p.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	for _, m := range methods {
+		if m == req.Method {
+			return true
+		}
+	}
+	return false
+}
+
+// delay 计算当前的对冲延迟，返回 0 表示不对冲
+func (p *HedgePolicy) delay() time.Duration {
+	if p.Percentile <= 0 {
+		return p.Delay
+	}
+	minSamples := p.MinSamples
+	if minSamples <= 0 {
+		minSamples = 20
+	}
+	p.mu.Lock()
+	n := min(p.n, hedgeSamples)
+	if n < minSamples {
+		p.mu.Unlock()
+		return p.Delay
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, p.samples[:n])
+	p.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(float64(n-1) * min(p.Percentile, 1))
+	return sorted[idx]
+}
+
+// observe 记录一个首个请求的延迟样本
+func (p *HedgePolicy) observe(d time.Duration) {
+	p.mu.Lock()
+	p.samples[p.n%hedgeSamples] = d
+	p.n++
+	p.mu.Unlock()
+}
+
+// hedgeResult 一路请求的结果
+type hedgeResult struct {
+	idx     int
+	resp    *http.Response
+	err     error
+	elapsed time.Duration
+}
+
+// sendHedged 发出首个请求，超过对冲延迟未返回（或提前失败）时再发出对冲请求，
+// 返回第一个成功（非 5xx）的响应；401 / 403 只在没有其他进行中的请求时返回，
+// 避免某一路的鉴权失败抢先于正常响应；全部失败时返回最后一个结果。
+func (c *HTTPClient) sendHedged(raw *http.Client, req *http.Request, p *HedgePolicy, route string) (*http.Response, error) {
+	b := c.builder
+	maxHedges := p.MaxHedges
+	if maxHedges <= 0 {
+		maxHedges = 1
+	}
+	results := make(chan hedgeResult, maxHedges+1)
+	cancels := make([]context.CancelFunc, 0, maxHedges+1)
+	defer func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+
+	launch := func(idx int) error {
+		ctx, cancel := context.WithCancel(req.Context())
+		r := req.Clone(ctx)
+		if idx > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			r.Body = body
+		}
+		// 对冲请求重新签名，刷新时间戳 / nonce，否则服务端按重放拒绝
+		if idx > 0 && b.signFn != nil {
+			if err := b.signFn(r); err != nil {
+				cancel()
+				return err
+			}
+		}
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			resp, err := c.send(raw, r)
+			results <- hedgeResult{idx: idx, resp: resp, err: err, elapsed: time.Since(start)}
+		}()
+		return nil
+	}
+
+	// tryHedge 发出一个对冲请求；限速器无可用令牌时放弃
+	tryHedge := func(idx int) bool {
+		if b.rateLimiter != nil && !b.rateLimiter.TryAcquire(req) {
+			return false
+		}
+		if launch(idx) != nil {
+			return false
+		}
+		if b.metrics != nil {
+			b.metrics.HedgedRequests.Add(1)
+		}
+		if b.collector != nil {
+			b.collector.observeHedge(req.Method, req.URL.Host, route, false)
+		}
+		return true
+	}
+
+	if err := launch(0); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	launched, pending := 1, 1
+	primaryDone := false
+
+	var timer <-chan time.Time
+	if d := p.delay(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			// 延迟样本只取首个请求：只记录胜出者会使分位数偏低，进而提高对冲比例
+			if res.idx == 0 {
+				primaryDone = true
+				if res.err == nil {
+					p.observe(res.elapsed)
+				}
+			}
+			if res.err == nil && res.resp.StatusCode < 500 && !(authFailure(res.resp.StatusCode) && pending > 0) {
+				if !primaryDone {
+					p.observe(time.Since(start)) // 首个请求至少耗时这么久
+				}
+				if res.idx > 0 && b.collector != nil {
+					b.collector.observeHedge(req.Method, req.URL.Host, route, true)
+				}
+				// 胜出请求的 context 需要保持到响应体读取完毕
+				cancel := cancels[res.idx]
+				cancels[res.idx] = nil
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancel}
+				go drainHedges(results, pending)
+				return res.resp, nil
+			}
+			// 失败：保留最后一个结果，若还有对冲额度则立即补发（鉴权失败时补发无意义）
+			if last.resp != nil {
+				last.resp.Body.Close()
+			}
+			last = res
+			if res.err == nil && authFailure(res.resp.StatusCode) {
+				continue
+			}
+			if launched <= maxHedges && req.Context().Err() == nil && tryHedge(launched) {
+				launched++
+				pending++
+			}
+		case <-timer:
+			if launched <= maxHedges && tryHedge(launched) {
+				launched++
+				pending++
+				if launched <= maxHedges {
+					timer = time.After(p.delay())
+				}
+			}
+		}
+	}
+	return last.resp, last.err
+}
+
+// authFailure 401 / 403：通常由单路请求的签名或凭证问题导致，不应作为对冲的胜出结果
+func authFailure(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// drainHedges 关闭落败请求的响应体，释放连接
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose 关闭响应体时释放对应请求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
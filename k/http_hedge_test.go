@@ -0,0 +1,220 @@
+package k
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试首个请求变慢时对冲请求胜出，落败请求被取消
+func TestHedge_SlowPrimaryLoses(t *testing.T) {
+	var calls atomic.Int32
+	cancelled := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+				cancelled <- struct{}{}
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		io.WriteString(w, "fast")
+	}))
+	defer srv.Close()
+
+	m := &Metrics{}
+	mc := NewMetricsCollector()
+	client, _ := NewClient(srv.URL).
+		Metrics(m).
+		MetricsCollector(mc).
+		Hedge(&HedgePolicy{Delay: 20 * time.Millisecond}).
+		Build()
+
+	start := time.Now()
+	resp, err := client.Get("/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "fast" {
+		t.Fatalf("body = %q", body)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("hedged request took %v", d)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("losing request was not cancelled")
+	}
+
+	if m.TotalRequests.Load() != 1 || m.HedgedRequests.Load() != 1 {
+		t.Errorf("total=%d hedged=%d", m.TotalRequests.Load(), m.HedgedRequests.Load())
+	}
+	var buf bytes.Buffer
+	mc.WritePrometheus(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`http_client_hedges_total{method="GET",host="` + strings.TrimPrefix(srv.URL, "http://") + `",route="/slow"} 1`,
+		`http_client_hedge_wins_total{`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// 测试首个请求在延迟内返回时不发出对冲
+func TestHedge_FastPrimaryNoHedge(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	m := &Metrics{}
+	client, _ := NewClient(srv.URL).Metrics(m).Hedge(&HedgePolicy{Delay: 500 * time.Millisecond}).Build()
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if calls.Load() != 5 || m.HedgedRequests.Load() != 0 {
+		t.Errorf("calls=%d hedged=%d", calls.Load(), m.HedgedRequests.Load())
+	}
+}
+
+// 测试非幂等方法与请求级关闭不对冲
+func TestHedge_NotEligible(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(60 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Hedge(&HedgePolicy{Delay: 10 * time.Millisecond}).Build()
+	resp, err := client.PostJSON("/orders", map[string]int{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get("/report", R().Hedge(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if n := calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2", n)
+	}
+}
+
+// 测试限速器无令牌时放弃对冲
+func TestHedge_RateLimited(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(60 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	m := &Metrics{}
+	client, _ := NewClient(srv.URL).
+		Metrics(m).
+		RateLimiter(NewRateLimiter(0.001, 1)).
+		Hedge(&HedgePolicy{Delay: 10 * time.Millisecond}).
+		Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 || m.HedgedRequests.Load() != 0 {
+		t.Errorf("calls=%d hedged=%d", calls.Load(), m.HedgedRequests.Load())
+	}
+}
+
+// 测试分位数延迟：样本不足时回退到 Delay
+func TestHedgePolicy_Percentile(t *testing.T) {
+	p := &HedgePolicy{Delay: time.Second, Percentile: 0.9, MinSamples: 10}
+	for i := 1; i <= 9; i++ {
+		p.observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := p.delay(); d != time.Second {
+		t.Errorf("fallback delay = %v", d)
+	}
+	for i := 10; i <= 100; i++ {
+		p.observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := p.delay(); d < 85*time.Millisecond || d > 95*time.Millisecond {
+		t.Errorf("p90 delay = %v", d)
+	}
+}
+
+// 测试对冲请求重新签名，不会被 HMACVerifier 当作重放拒绝；延迟样本按首个请求记录
+func TestHedge_ResignsHedgedRequests(t *testing.T) {
+	var calls atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		io.WriteString(w, "fast")
+	})
+	secret := func(ak string) (string, bool) { return "sk", ak == "ak" }
+	srv := httptest.NewServer(HMACVerifier(secret, nil)(handler))
+	defer srv.Close()
+
+	policy := &HedgePolicy{Delay: 20 * time.Millisecond}
+	client, _ := NewClient(srv.URL).Sign(HMACSigner("ak", "sk")).Hedge(policy).Build()
+	resp, err := client.Get("/signed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "fast" {
+		t.Fatalf("status=%d body=%q", resp.StatusCode, body)
+	}
+	if policy.n != 1 || policy.samples[0] < 20*time.Millisecond {
+		t.Errorf("samples n=%d first=%v, want the primary's (censored) latency", policy.n, policy.samples[0])
+	}
+}
+
+// 测试对冲请求的 401 不会抢先于仍在进行的首个请求
+func TestHedge_AuthFailureDoesNotWin(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			time.Sleep(80 * time.Millisecond)
+			io.WriteString(w, "primary")
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Hedge(&HedgePolicy{Delay: 10 * time.Millisecond, MaxHedges: 2}).Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "primary" {
+		t.Fatalf("status=%d body=%q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,187 @@
+package k
+
+// http_hooks.go —— 请求生命周期钩子：OnRequest / OnRetry / OnResponse / OnError / OnComplete
+//
+// 设计目标：
+//   - 覆盖完整生命周期：构建完成 → 每次重试前 → 每次尝试后（含重试中被丢弃的 5xx 与网络错误）→ 最终完成
+//   - 钩子可读取可重放的请求体与响应体（RequestBody / ResponseBody），不影响重试与调用方读取
+//   - 同一钩子可注册多个，按注册顺序执行；OnRequest 返回错误时中止请求
+//   - 流式请求（SSE、Download）的响应体不缓冲，钩子中不可读取
+//
+// 示例（支付渠道调用审计）：
+//
+//	client, _ := NewClient("https://pay.example.com").
+//		OnResponse(func(e *HookEvent) {
+//			reqBody, _ := RequestBody(e.Request)
+//			respBody, _ := ResponseBody(e.Response)
+//			audit.Write(e.Request.Method, e.Request.URL.String(), e.Attempt, reqBody, respBody, e.Err)
+//		}).
+//		OnComplete(func(e *HookEvent) { audit.Done(e.Request.URL.String(), e.Attempt, e.Duration, e.Err) }).
+//		Build()
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HookEvent 传给 OnRetry / OnResponse / OnError / OnComplete 的事件
+type HookEvent struct {
+	// Request 本次尝试实际发送的请求（负载均衡改写、签名之后）；完成事件中为原始请求，
+	// 请求未能构建（URL 或 method 无效）时为 nil
+	Request *http.Request
+	// Response 响应，网络错误时为 nil；Body 可通过 ResponseBody 重复读取（流式请求除外）
+	Response *http.Response
+	// Err 本次尝试或整个请求的错误
+	Err error
+	// Attempt 第几次尝试（从 1 开始）；完成事件中为总尝试次数，缓存命中时为 0
+	Attempt int
+	// Duration 本次尝试耗时；完成事件中为总耗时
+	Duration time.Duration
+}
+
+// clientHooks 客户端注册的全部钩子
+type clientHooks struct {
+	onRequest  []func(*http.Request) error
+	onRetry    []func(*HookEvent)
+	onResponse []func(*HookEvent)
+	onError    []func(*HookEvent)
+	onComplete []func(*HookEvent)
+}
+
+// OnRequest 注册请求构建完成（header、query、body、鉴权已就绪）后、进入缓存 / 熔断 / 重试流程前的钩子。
+// 返回非 nil 错误时请求不会发出，错误原样返回给调用方。
+func (b *ClientBuilder) OnRequest(fn func(req *http.Request) error) *ClientBuilder {
+	b.hooks.onRequest = append(b.hooks.onRequest, fn)
+	return b
+}
+
+// OnRetry 注册每次重试前的钩子，事件描述触发重试的上一次尝试（Attempt 为其序号）。
+func (b *ClientBuilder) OnRetry(fn func(e *HookEvent)) *ClientBuilder {
+	b.hooks.onRetry = append(b.hooks.onRetry, fn)
+	return b
+}
+
+// OnResponse 注册每次尝试结束后的钩子，包括重试过程中被丢弃的 5xx 响应与网络错误。
+// 注册后非流式请求的响应体会读入内存，以便钩子与调用方都能完整读取。
+func (b *ClientBuilder) OnResponse(fn func(e *HookEvent)) *ClientBuilder {
+	b.hooks.onResponse = append(b.hooks.onResponse, fn)
+	return b
+}
+
+// OnError 注册请求最终失败（重试耗尽、熔断、限速、状态码不在 ExpectStatus 中、鉴权或 OnRequest 失败等）时的钩子。
+// 注册后非流式请求的最终响应体会读入内存，以便钩子通过 ResponseBody 读取。
+func (b *ClientBuilder) OnError(fn func(e *HookEvent)) *ClientBuilder {
+	b.hooks.onError = append(b.hooks.onError, fn)
+	return b
+}
+
+// OnComplete 注册请求最终完成（成功或失败）时的钩子，每次调用 Get / Post 等恰好触发一次，
+// 包括请求在发出前失败（请求构建、OAuth2 取令牌、OnRequest 返回错误）的情况。
+// 注册后非流式请求的最终响应体会读入内存，以便钩子通过 ResponseBody 读取。
+func (b *ClientBuilder) OnComplete(fn func(e *HookEvent)) *ClientBuilder {
+	b.hooks.onComplete = append(b.hooks.onComplete, fn)
+	return b
+}
+
+// RequestBody 读取请求体副本（通过 GetBody，不消耗 req.Body），无请求体时返回 nil。
+// 流式 multipart 上传会重新生成整个请求体，大文件时慎用。
+func RequestBody(req *http.Request) ([]byte, error) {
+	if req == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body is not replayable")
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// ResponseBody 返回钩子中响应体的内容，不影响调用方读取。
+// 仅在注册了 OnResponse / OnError / OnComplete 的客户端上可用，流式请求返回错误。
+func ResponseBody(resp *http.Response) ([]byte, error) {
+	if resp == nil {
+		return nil, nil
+	}
+	if rb, ok := resp.Body.(*replayBody); ok {
+		return rb.data, rb.err
+	}
+	return nil, errors.New("response body is not replayable")
+}
+
+// request 依次执行 OnRequest，遇到错误即返回
+func (h *clientHooks) request(req *http.Request) error {
+	for _, fn := range h.onRequest {
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *clientHooks) retry(e *HookEvent) {
+	for _, fn := range h.onRetry {
+		fn(e)
+	}
+}
+
+func (h *clientHooks) response(e *HookEvent) {
+	for _, fn := range h.onResponse {
+		fn(e)
+	}
+}
+
+// complete 触发 OnError（失败时）与 OnComplete
+func (h *clientHooks) complete(e *HookEvent) {
+	if e.Err != nil {
+		for _, fn := range h.onError {
+			fn(e)
+		}
+	}
+	for _, fn := range h.onComplete {
+		fn(e)
+	}
+}
+
+// observesBody 是否有钩子需要读取每次尝试的响应体；OnError / OnComplete 只缓冲最终响应
+func (h *clientHooks) observesBody() bool {
+	return len(h.onResponse) > 0
+}
+
+// bufferBody 将响应体读入内存后以 replayBody 重新装填；读取出错时调用方在读完已有内容后得到同样的错误
+func bufferBody(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if _, ok := resp.Body.(*replayBody); ok {
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = &replayBody{Reader: bytes.NewReader(data), data: data, err: err}
+}
+
+// replayBody 内存中的响应体，ResponseBody 可随时取回完整内容
+type replayBody struct {
+	*bytes.Reader
+	data []byte
+	err  error // 读取原响应体时的错误（如超过 MaxBodySize）
+}
+
+func (r *replayBody) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF && r.err != nil {
+		return n, r.err
+	}
+	return n, err
+}
+
+func (r *replayBody) Close() error {
+	return nil
+}
@@ -0,0 +1,168 @@
+package k
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试重试过程中每次尝试的请求体、响应体（含被丢弃的 5xx）都能被钩子读取
+func TestHooks_AuditTrail(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.Header.Get("X-Audit-ID") != "a-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "busy %d", n)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	var trail []string
+	var retries []int
+	var complete *HookEvent
+	client, _ := NewClient(srv.URL).
+		Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).
+		OnRequest(func(req *http.Request) error {
+			req.Header.Set("X-Audit-ID", "a-1")
+			return nil
+		}).
+		OnRetry(func(e *HookEvent) { retries = append(retries, e.Attempt) }).
+		OnResponse(func(e *HookEvent) {
+			reqBody, _ := RequestBody(e.Request)
+			respBody, _ := ResponseBody(e.Response)
+			trail = append(trail, fmt.Sprintf("%d:%s:%d:%s", e.Attempt, reqBody, e.Response.StatusCode, respBody))
+		}).
+		OnComplete(func(e *HookEvent) { complete = e }).
+		Build()
+
+	resp, err := client.PostJSON("/charge", map[string]int{"amount": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Fatalf("caller body = %q", body)
+	}
+
+	want := `1:{"amount":100}:503:busy 1 2:{"amount":100}:503:busy 2 3:{"amount":100}:200:ok`
+	if got := strings.Join(trail, " "); got != want {
+		t.Errorf("trail = %s", got)
+	}
+	if fmt.Sprint(retries) != "[1 2]" {
+		t.Errorf("retries = %v", retries)
+	}
+	if complete == nil || complete.Attempt != 3 || complete.Err != nil || complete.Response != resp {
+		t.Errorf("complete = %+v", complete)
+	}
+	if b, err := ResponseBody(complete.Response); err != nil || string(b) != "ok" {
+		t.Errorf("complete body = %q, %v", b, err)
+	}
+}
+
+// 测试 OnRequest 中止请求
+func TestHooks_OnRequestAborts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer srv.Close()
+
+	denied := errors.New("denied by policy")
+	var completed []error
+	client, _ := NewClient(srv.URL).
+		OnRequest(func(req *http.Request) error {
+			if req.Method == http.MethodDelete {
+				return denied
+			}
+			return nil
+		}).
+		OnComplete(func(e *HookEvent) { completed = append(completed, e.Err) }).
+		Build()
+	if _, err := client.Delete("/users/1"); !errors.Is(err, denied) {
+		t.Fatalf("err = %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("request should not be sent, calls = %d", calls.Load())
+	}
+
+	// 请求构建失败同样触发完成钩子
+	if _, err := client.Get("/bad\x7f"); err == nil {
+		t.Fatal("expected invalid URL error")
+	}
+	if len(completed) != 2 || !errors.Is(completed[0], denied) || completed[1] == nil {
+		t.Errorf("completed = %v", completed)
+	}
+}
+
+// 测试最终失败时触发 OnError，网络错误时 OnResponse 收到 Err
+func TestHooks_OnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "dup")
+	}))
+	defer srv.Close()
+
+	var errs, completes int
+	var attemptErr error
+	client, _ := NewClient(srv.URL).
+		OnResponse(func(e *HookEvent) { attemptErr = e.Err }).
+		OnError(func(e *HookEvent) { errs++ }).
+		OnComplete(func(e *HookEvent) { completes++ }).
+		Build()
+
+	_, err := client.Get("/", R().ExpectStatus(200))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || string(httpErr.Body) != "dup" || errs != 1 || completes != 1 {
+		t.Fatalf("err=%v errs=%d completes=%d", err, errs, completes)
+	}
+
+	srv.Close()
+	if _, err := client.Get("/"); err == nil {
+		t.Fatal("expected network error")
+	}
+	if attemptErr == nil || errs != 2 || completes != 2 {
+		t.Errorf("attemptErr=%v errs=%d completes=%d", attemptErr, errs, completes)
+	}
+}
+
+// 测试缓存命中时完成事件的尝试次数为 0，响应体仍可读取
+func TestHooks_CacheHit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "cached")
+	}))
+	defer srv.Close()
+
+	var events []*HookEvent
+	client, _ := NewClient(srv.URL).
+		ResponseCache(NewResponseCache(time.Minute)).
+		OnComplete(func(e *HookEvent) { events = append(events, e) }).
+		Build()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, _ := client.ReadBodyString(resp); s != "cached" {
+			t.Errorf("body = %q", s)
+		}
+	}
+	if len(events) != 2 || events[0].Attempt != 1 || events[1].Attempt != 0 {
+		t.Fatalf("events = %+v", events)
+	}
+	if b, _ := ResponseBody(events[1].Response); string(b) != "cached" {
+		t.Errorf("hook body = %q", b)
+	}
+}
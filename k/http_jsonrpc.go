@@ -0,0 +1,254 @@
+package k
+
+// http_jsonrpc.go —— 基于 HTTPClient 的 JSON-RPC 2.0 客户端：单次调用、通知、批量调用
+//
+// 设计目标：
+//   - 请求 id 由客户端自增分配，响应按 id 对应回调用，批量响应乱序、缺项都能正确处理
+//   - params 可为任意可 JSON 编码的切片、结构体或 map，结果以泛型或传入指针解码
+//   - 响应中的 error 对象以 *JSONRPCError 返回（errors.As 识别），保留 code / message / data；
+//     以 HTTP 4xx / 5xx 携带的 error 对象同样如此，携带 error 对象的 5xx 不重试，其余 5xx（如网关 503）照常重试
+//   - 请求经 PostJSON 发出，复用客户端的鉴权、签名、限速、熔断、重试、日志与钩子
+//
+// 示例：
+//
+//	rpc := NewJSONRPCClient(client, "/rpc")
+//
+//	height, err := JSONRPCCall[string](ctx, rpc, "eth_blockNumber", nil)
+//	var rpcErr *JSONRPCError
+//	if errors.As(err, &rpcErr) && rpcErr.Code == JSONRPCMethodNotFound { ... }
+//
+//	var balance string
+//	var block map[string]any
+//	err = rpc.BatchCall(ctx, []*JSONRPCBatchElem{
+//		{Method: "eth_getBalance", Params: []any{addr, "latest"}, Result: &balance},
+//		{Method: "eth_getBlockByNumber", Params: []any{"latest", false}, Result: &block},
+//	})
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// JSON-RPC 2.0 规范定义的错误码
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+// ErrJSONRPCNoResponse 批量调用的响应中缺少某个 id 对应的结果
+var ErrJSONRPCNoResponse = errors.New("jsonrpc: no response for request")
+
+// JSONRPCError 响应中的 error 对象
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// JSONRPCBatchElem 批量调用中的一项，BatchCall 返回后 Error 为该项的错误
+type JSONRPCBatchElem struct {
+	Method string
+	Params any
+	// Result 结果解码目标（指针），为 nil 时丢弃结果
+	Result any
+	// Error 该项的错误：*JSONRPCError、ErrJSONRPCNoResponse 或结果解码错误
+	Error error
+}
+
+// jsonrpcRequest 请求对象，通知不带 id
+type jsonrpcRequest struct {
+	JSONRPC string  `json:"jsonrpc"`
+	ID      *uint64 `json:"id,omitempty"`
+	Method  string  `json:"method"`
+	Params  any     `json:"params,omitempty"`
+}
+
+// jsonrpcResponse 响应对象
+type jsonrpcResponse struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// JSONRPCClient JSON-RPC 2.0 端点，并发安全，通过 NewJSONRPCClient 创建。
+//
+// 调用以 POST 发送，是否重试由客户端的 Retry 配置决定；方法非幂等时应关闭重试或使用单独的客户端。
+type JSONRPCClient struct {
+	// Request 每个请求附加的配置（header 等），其中的 Context 会被调用时的 ctx 替代
+	Request *RequestBuilder
+
+	client *HTTPClient
+	path   string
+	nextID atomic.Uint64
+}
+
+// NewJSONRPCClient 创建 JSON-RPC 客户端。
+//
+// 参数：
+//   - c:    发送请求的 HTTPClient
+//   - path: JSON-RPC 端点路径，例如 "/rpc"，baseURL 即端点时传 ""
+func NewJSONRPCClient(c *HTTPClient, path string) *JSONRPCClient {
+	return &JSONRPCClient{client: c, path: path}
+}
+
+// JSONRPCCall 调用 method 并将结果解码为 T。
+func JSONRPCCall[T any](ctx context.Context, j *JSONRPCClient, method string, params any) (T, error) {
+	var result T
+	err := j.Call(ctx, method, params, &result)
+	return result, err
+}
+
+// Call 调用 method 并将结果解码到 result（指针，可为 nil）。
+//
+// 参数：
+//   - method: 方法名
+//   - params: 参数，通常为切片（按位置）或结构体 / map（按名称），nil 时不发送 params
+//   - result: 结果解码目标
+func (j *JSONRPCClient) Call(ctx context.Context, method string, params any, result any) error {
+	id := j.nextID.Add(1)
+	body, err := j.post(ctx, &jsonrpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	var resp jsonrpcResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("jsonrpc: decode response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.ID == nil || *resp.ID != id {
+		return fmt.Errorf("jsonrpc: response id mismatch for %s", method)
+	}
+	return decodeRPCResult(resp.Result, result)
+}
+
+// Notify 发送通知（不带 id 的请求），服务端不返回结果。
+func (j *JSONRPCClient) Notify(ctx context.Context, method string, params any) error {
+	_, err := j.post(ctx, &jsonrpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+	return err
+}
+
+// BatchCall 在一个 HTTP 请求中发送全部调用，按 id 将乱序返回的结果写回对应项。
+// 返回的错误只表示整批失败（网络错误、HTTP 错误、服务端拒绝整个批次），单项错误见 elems[i].Error。
+func (j *JSONRPCClient) BatchCall(ctx context.Context, elems []*JSONRPCBatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+	reqs := make([]jsonrpcRequest, len(elems))
+	byID := make(map[uint64]*JSONRPCBatchElem, len(elems))
+	for i, e := range elems {
+		id := j.nextID.Add(1)
+		reqs[i] = jsonrpcRequest{JSONRPC: "2.0", ID: &id, Method: e.Method, Params: e.Params}
+		byID[id] = e
+		e.Error = ErrJSONRPCNoResponse
+	}
+	body, err := j.post(ctx, reqs)
+	if err != nil {
+		return err
+	}
+
+	var resps []jsonrpcResponse
+	if err := json.Unmarshal(body, &resps); err != nil {
+		// 批次本身无效时服务端返回单个 error 对象
+		var single jsonrpcResponse
+		if json.Unmarshal(body, &single) == nil && single.Error != nil {
+			return single.Error
+		}
+		return fmt.Errorf("jsonrpc: decode batch response: %w", err)
+	}
+	for _, resp := range resps {
+		if resp.ID == nil {
+			continue
+		}
+		e, ok := byID[*resp.ID]
+		if !ok {
+			continue
+		}
+		delete(byID, *resp.ID)
+		if resp.Error != nil {
+			e.Error = resp.Error
+			continue
+		}
+		e.Error = decodeRPCResult(resp.Result, e.Result)
+	}
+	return nil
+}
+
+// post 发送请求体并返回响应体；非 2xx 且响应体不是 JSON-RPC 响应时返回 *HTTPError。
+// 服务端以 5xx 返回 error 对象（如 -32603 Internal error）时不重试，直接按 JSON-RPC 错误处理
+func (j *JSONRPCClient) post(ctx context.Context, payload any) ([]byte, error) {
+	rb := j.Request.clone().Context(ctx)
+	rb.cfg.finalServerErr = j.serverError
+	resp, err := j.client.PostJSON(j.path, payload, rb)
+	if err != nil {
+		return nil, err
+	}
+	body, err := j.client.ReadBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode > 299 {
+		// 部分服务端以 4xx / 5xx 携带 error 对象，交给调用方按 JSON-RPC 错误处理
+		var probe jsonrpcResponse
+		if json.Unmarshal(body, &probe) != nil || probe.Error == nil {
+			return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
+	}
+	return body, nil
+}
+
+// serverError 判断 5xx 响应是否携带 JSON-RPC error 对象：是则作为最终结果返回，
+// 否则（如网关返回的 {"message":"no healthy upstream"}）照常重试。读取后把原始字节装回 resp.Body
+func (j *JSONRPCClient) serverError(resp *http.Response) bool {
+	if !isJSONContentType(resp.Header.Get("Content-Type")) {
+		return false
+	}
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	decoded := *resp
+	decoded.Body = io.NopCloser(bytes.NewReader(raw))
+	rc, err := j.client.decodeBody(&decoded)
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	var probe jsonrpcResponse
+	return json.NewDecoder(rc).Decode(&probe) == nil && probe.Error != nil
+}
+
+// decodeRPCResult 将 result 解码到 out，out 为 nil 时丢弃
+func decodeRPCResult(raw json.RawMessage, out any) error {
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("jsonrpc: decode result: %w", err)
+	}
+	return nil
+}
+
+// isJSONContentType 判断 Content-Type 是否为 JSON（含 application/*+json）
+func isJSONContentType(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == MediaTypeJSON || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
@@ -0,0 +1,238 @@
+package k
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rpcServer 逆序返回批量结果；add 求和，fail 返回带 data 的错误，skip 不返回结果，notify 作为通知不返回
+func rpcServer(t *testing.T, handled *atomic.Int32) *httptest.Server {
+	t.Helper()
+	handle := func(req map[string]any) map[string]any {
+		handled.Add(1)
+		if req["jsonrpc"] != "2.0" {
+			return map[string]any{"jsonrpc": "2.0", "id": nil, "error": map[string]any{"code": JSONRPCInvalidRequest, "message": "Invalid Request"}}
+		}
+		id, hasID := req["id"]
+		if !hasID || req["method"] == "skip" {
+			return nil
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": id}
+		switch req["method"] {
+		case "add":
+			var sum float64
+			for _, v := range req["params"].([]any) {
+				sum += v.(float64)
+			}
+			resp["result"] = sum
+		case "user":
+			params := req["params"].(map[string]any)
+			resp["result"] = map[string]any{"id": params["id"], "name": "alice"}
+		case "fail":
+			resp["error"] = map[string]any{"code": -32000, "message": "insufficient funds", "data": map[string]any{"need": 5}}
+		default:
+			resp["error"] = map[string]any{"code": JSONRPCMethodNotFound, "message": "Method not found"}
+		}
+		return resp
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		var batch []map[string]any
+		if json.Unmarshal(raw, &batch) == nil {
+			if len(batch) == 0 {
+				json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": nil, "error": map[string]any{"code": JSONRPCInvalidRequest, "message": "empty batch"}})
+				return
+			}
+			out := []any{}
+			for i := len(batch) - 1; i >= 0; i-- {
+				if resp := handle(batch[i]); resp != nil {
+					out = append(out, resp)
+				}
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req map[string]any
+		json.Unmarshal(raw, &req)
+		if resp := handle(req); resp != nil {
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJSONRPCCall(t *testing.T) {
+	var handled atomic.Int32
+	srv := rpcServer(t, &handled)
+	client, _ := NewClient(srv.URL).Build()
+	rpc := NewJSONRPCClient(client, "/rpc")
+	ctx := context.Background()
+
+	sum, err := JSONRPCCall[int](ctx, rpc, "add", []int{1, 2, 3})
+	if err != nil || sum != 6 {
+		t.Fatalf("add = %d, %v", sum, err)
+	}
+
+	type userParams struct {
+		ID string `json:"id"`
+	}
+	var user struct{ ID, Name string }
+	if err := rpc.Call(ctx, "user", userParams{"42"}, &user); err != nil || user.ID != "42" || user.Name != "alice" {
+		t.Fatalf("user = %+v, %v", user, err)
+	}
+
+	_, err = JSONRPCCall[int](ctx, rpc, "fail", nil)
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32000 || string(rpcErr.Data) != `{"need":5}` {
+		t.Fatalf("err = %v", err)
+	}
+	if err.Error() != "jsonrpc error -32000: insufficient funds" {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+
+	_, err = JSONRPCCall[int](ctx, rpc, "nope", nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != JSONRPCMethodNotFound {
+		t.Fatalf("err = %v", err)
+	}
+
+	if err := rpc.Notify(ctx, "notify", []string{"hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if handled.Load() != 5 {
+		t.Fatalf("handled = %d", handled.Load())
+	}
+}
+
+func TestJSONRPCBatchCall(t *testing.T) {
+	var handled atomic.Int32
+	srv := rpcServer(t, &handled)
+	client, _ := NewClient(srv.URL).Build()
+	rpc := NewJSONRPCClient(client, "/rpc")
+
+	var a, b int
+	elems := []*JSONRPCBatchElem{
+		{Method: "add", Params: []int{1, 2}, Result: &a},
+		{Method: "fail"},
+		{Method: "add", Params: []int{10, 20}, Result: &b},
+		{Method: "skip"},
+		{Method: "add", Params: []int{1}}, // 丢弃结果
+	}
+	if err := rpc.BatchCall(context.Background(), elems); err != nil {
+		t.Fatal(err)
+	}
+	if a != 3 || b != 30 || elems[0].Error != nil || elems[2].Error != nil || elems[4].Error != nil {
+		t.Fatalf("results a=%d b=%d errs=%v %v %v", a, b, elems[0].Error, elems[2].Error, elems[4].Error)
+	}
+	var rpcErr *JSONRPCError
+	if !errors.As(elems[1].Error, &rpcErr) || rpcErr.Message != "insufficient funds" {
+		t.Fatalf("fail elem = %v", elems[1].Error)
+	}
+	if !errors.Is(elems[3].Error, ErrJSONRPCNoResponse) {
+		t.Fatalf("skip elem = %v", elems[3].Error)
+	}
+
+	// 结果类型不匹配只影响该项
+	var s string
+	elems = []*JSONRPCBatchElem{{Method: "add", Params: []int{1}, Result: &s}, {Method: "add", Params: []int{2}, Result: &a}}
+	if err := rpc.BatchCall(context.Background(), elems); err != nil || elems[0].Error == nil || elems[1].Error != nil || a != 2 {
+		t.Fatalf("err = %v, elems = %v %v", err, elems[0].Error, elems[1].Error)
+	}
+
+	// 空批次不发请求
+	before := handled.Load()
+	if err := rpc.BatchCall(context.Background(), nil); err != nil || handled.Load() != before {
+		t.Fatalf("empty batch: %v", err)
+	}
+}
+
+func TestJSONRPCBatchRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large"}}`)
+	}))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Build()
+	rpc := NewJSONRPCClient(client, "")
+
+	err := rpc.BatchCall(context.Background(), []*JSONRPCBatchElem{{Method: "a"}, {Method: "b"}})
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != JSONRPCInvalidRequest {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestJSONRPCRetryAndHTTPError(t *testing.T) {
+	var calls, crashes, gateway atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     uint64 `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case req.Method == "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "denied")
+		case req.Method == "overload" && calls.Add(1) < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case req.Method == "gateway" && gateway.Add(1) == 1:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"no healthy upstream"}`)
+		case req.Method == "crash":
+			crashes.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":-32603,"message":"Internal error"}}`, req.ID)
+		case req.Method == "wrong-id":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":1}`, req.ID+100)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"ok"}`, req.ID)
+		}
+	}))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).Build()
+	rpc := NewJSONRPCClient(client, "/rpc")
+	ctx := context.Background()
+
+	if got, err := JSONRPCCall[string](ctx, rpc, "overload", nil); err != nil || got != "ok" || calls.Load() != 3 {
+		t.Fatalf("overload = %q, %v (calls %d)", got, err, calls.Load())
+	}
+
+	_, err := JSONRPCCall[string](ctx, rpc, "forbidden", nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden || string(httpErr.Body) != "denied" {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 以 HTTP 500 携带的 error 对象按 JSON-RPC 错误返回，且不重试
+	_, err = JSONRPCCall[string](ctx, rpc, "crash", nil)
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != JSONRPCInternalError || crashes.Load() != 1 {
+		t.Fatalf("crash err = %v (calls %d)", err, crashes.Load())
+	}
+
+	// 网关以 JSON 返回的 503 不是 JSON-RPC 错误，照常重试
+	if got, err := JSONRPCCall[string](ctx, rpc, "gateway", nil); err != nil || got != "ok" || gateway.Load() != 2 {
+		t.Fatalf("gateway = %q, %v (calls %d)", got, err, gateway.Load())
+	}
+
+	if _, err := JSONRPCCall[int](ctx, rpc, "wrong-id", nil); err == nil {
+		t.Fatal("expected id mismatch error")
+	}
+}
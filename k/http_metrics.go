@@ -0,0 +1,310 @@
+package k
+
+// http_metrics.go —— HTTPClient 的 Prometheus 兼容指标收集器
+//
+// 与 Metrics（三个原子总数）互补：
+//   - 按 method / host / route / code 打标签的请求计数
+//   - 按 method / host / route 打标签的耗时直方图
+//   - 重试次数、缓存命中/未命中、进行中请求数
+//   - 通过 Handler() 以 Prometheus 文本格式暴露，不依赖任何第三方库
+//
+// 快速开始：
+//
+//	mc := NewMetricsCollector()
+//	client, _ := NewClient("https://api.example.com").MetricsCollector(mc).Build()
+//	http.Handle("/metrics", mc.Handler())
+//
+//	// route 标签默认取 URL path，路径中含 ID 时建议显式指定模板，避免标签基数膨胀
+//	client.Get("/users/42", R().Route("/users/:id"))
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 默认耗时直方图分桶上界（单位：秒），与 Prometheus 官方客户端默认值一致
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 指标类型，对应 Prometheus 文本格式中的 # TYPE
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+// MetricsCollector 带标签的指标收集器，并发安全。
+//
+// 创建后可直接修改公开字段（须在注入客户端之前）：
+//
+//	mc := NewMetricsCollector()
+//	mc.Namespace = "payment_client"
+//	mc.Buckets = []float64{0.05, 0.1, 0.5, 1, 3}
+//
+// 暴露的指标（以默认 Namespace "http_client" 为例）：
+//   - http_client_requests_total{method,host,route,code}        请求总数，网络错误时 code="error"，TLS 错误时 code="tls_error"
+//   - http_client_request_duration_seconds{method,host,route}   请求耗时直方图（含重试）
+//   - http_client_retries_total{method,host,route}              重试次数（不含首次尝试）
+//   - http_client_cache_requests_total{host,result}             响应缓存 hit / miss 次数
+//   - http_client_in_flight_requests{host}                      正在进行的请求数
+type MetricsCollector struct {
+	// Namespace 指标名前缀，默认 "http_client"
+	Namespace string
+	// Buckets 耗时直方图分桶上界（秒，升序），默认 DefaultLatencyBuckets
+	Buckets []float64
+
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// metricFamily 同名指标的全部时间序列
+type metricFamily struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*metricSeries
+}
+
+// metricSeries 一组标签值对应的单条时间序列
+type metricSeries struct {
+	labelValues []string
+	value       float64   // counter / gauge 的当前值
+	bounds      []float64 // histogram 分桶上界
+	buckets     []uint64  // histogram 各分桶的非累计计数
+	sum         float64
+	count       uint64
+}
+
+// NewMetricsCollector 创建使用默认配置的指标收集器。
+// 默认值：Namespace="http_client"，Buckets=DefaultLatencyBuckets。
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		Namespace: "http_client",
+		Buckets:   DefaultLatencyBuckets,
+		families:  make(map[string]*metricFamily),
+	}
+}
+
+// Handler 返回以 Prometheus 文本格式输出全部指标的 http.Handler，可直接挂载到 /metrics。
+func (m *MetricsCollector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus 以 Prometheus 文本格式（version 0.0.4）写出全部指标。
+// 指标和时间序列均按名称排序，输出稳定，便于测试比对。
+// 持锁时只复制一份快照，格式化与写出在锁外进行，慢速的抓取连接不会阻塞请求记录指标。
+func (m *MetricsCollector) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range m.snapshot() {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		for _, s := range f.series {
+			if f.kind != metricHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range s.bounds {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), s.count)
+		}
+	}
+	return bw.Flush()
+}
+
+// familySnapshot 某一时刻指标族的副本，series 按标签排序
+type familySnapshot struct {
+	name, help, kind string
+	labels           []string
+	series           []metricSeries
+}
+
+// snapshot 持锁复制全部指标，指标族按名称排序
+func (m *MetricsCollector) snapshot() []familySnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]familySnapshot, 0, len(names))
+	for _, name := range names {
+		f := m.families[name]
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fs := familySnapshot{name: f.name, help: f.help, kind: f.kind, labels: f.labels, series: make([]metricSeries, len(keys))}
+		for i, key := range keys {
+			s := *f.series[key]
+			s.buckets = slices.Clone(s.buckets)
+			fs.series[i] = s
+		}
+		out = append(out, fs)
+	}
+	return out
+}
+
+// ─── 内部记录方法（由 HTTPClient.execute 调用） ──────────
+
+// observeRequest 记录一次完整请求（含全部重试）的结果
+func (m *MetricsCollector) observeRequest(method, host, route string, resp *http.Response, err error, elapsed time.Duration, attempts int) {
+	code := "error"
+	var tlsErr *TLSError
+	switch {
+	case errors.As(err, &tlsErr):
+		code = "tls_error"
+	case err == nil && resp != nil:
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	m.add("requests_total", "Total number of HTTP requests sent by the client.", metricCounter,
+		[]string{"method", "host", "route", "code"}, []string{method, host, route, code}, 1)
+	m.observe("request_duration_seconds", "HTTP request latency in seconds, including retries.",
+		[]string{"method", "host", "route"}, []string{method, host, route}, elapsed.Seconds())
+	if attempts > 1 {
+		m.add("retries_total", "Total number of retried HTTP attempts.", metricCounter,
+			[]string{"method", "host", "route"}, []string{method, host, route}, float64(attempts-1))
+	}
+}
+
+// observeHedge 记录一次对冲：发出对冲请求时 won 为 false，对冲请求胜出时 won 为 true
+func (m *MetricsCollector) observeHedge(method, host, route string, won bool) {
+	name, help := "hedges_total", "Total number of hedged HTTP attempts sent."
+	if won {
+		name, help = "hedge_wins_total", "Total number of hedged HTTP attempts that returned first."
+	}
+	m.add(name, help, metricCounter, []string{"method", "host", "route"}, []string{method, host, route}, 1)
+}
+
+// observeCache 记录一次响应缓存查找结果
+func (m *MetricsCollector) observeCache(host string, hit bool) {
+	m.add("cache_requests_total", "Total number of response cache lookups.", metricCounter,
+		[]string{"host", "result"}, []string{host, If(hit, "hit", "miss")}, 1)
+}
+
+// inFlight 调整进行中请求数，delta 为 +1 或 -1
+func (m *MetricsCollector) inFlight(host string, delta float64) {
+	m.add("in_flight_requests", "Number of HTTP requests currently in flight.", metricGauge,
+		[]string{"host"}, []string{host}, delta)
+}
+
+// add 累加 counter / gauge
+func (m *MetricsCollector) add(name, help, kind string, labels, values []string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesLocked(name, help, kind, labels, values).value += delta
+}
+
+// observe 向 histogram 记录一个观测值
+func (m *MetricsCollector) observe(name, help string, labels, values []string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.seriesLocked(name, help, metricHistogram, labels, values)
+	idx := sort.SearchFloat64s(s.bounds, v) // 第一个 >= v 的上界
+	if idx < len(s.bounds) {
+		s.buckets[idx]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// seriesLocked 获取或创建时间序列，调用方须持有 m.mu
+func (m *MetricsCollector) seriesLocked(name, help, kind string, labels, values []string) *metricSeries {
+	if m.families == nil {
+		m.families = make(map[string]*metricFamily)
+	}
+	fullName := name
+	if m.Namespace != "" {
+		fullName = m.Namespace + "_" + name
+	}
+	f, ok := m.families[fullName]
+	if !ok {
+		f = &metricFamily{name: fullName, help: help, kind: kind, labels: labels, series: make(map[string]*metricSeries)}
+		m.families[fullName] = f
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: append([]string(nil), values...)}
+		if kind == metricHistogram {
+			bounds := m.Buckets
+			if len(bounds) == 0 {
+				bounds = DefaultLatencyBuckets
+			}
+			s.bounds = append([]float64(nil), bounds...)
+			sort.Float64s(s.bounds)
+			s.buckets = make([]uint64, len(s.bounds))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// formatLabels 生成 {k="v",...} 形式的标签串，extraName 非空时追加一个额外标签（如 le）
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(values[i]))
+		sb.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(extraValue)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// escapeLabelValue 按 Prometheus 文本格式转义标签值中的 \、" 和换行
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,155 @@
+package k
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试请求计数、直方图与重试指标
+func TestMetricsCollector_Request(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	mc := NewMetricsCollector()
+	client, _ := NewClient(server.URL).
+		MetricsCollector(mc).
+		Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).
+		Build()
+
+	resp, err := client.Get("/users/42", R().Route("/users/:id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	mc.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	wants := []string{
+		"# TYPE http_client_requests_total counter",
+		`http_client_requests_total{method="GET",host="` + host + `",route="/users/:id",code="200"} 1`,
+		`http_client_retries_total{method="GET",host="` + host + `",route="/users/:id"} 1`,
+		"# TYPE http_client_request_duration_seconds histogram",
+		`http_client_request_duration_seconds_bucket{method="GET",host="` + host + `",route="/users/:id",le="+Inf"} 1`,
+		`http_client_request_duration_seconds_count{method="GET",host="` + host + `",route="/users/:id"} 1`,
+		`http_client_in_flight_requests{host="` + host + `"} 0`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q\n%s", want, out)
+		}
+	}
+}
+
+// 测试缓存命中率指标
+func TestMetricsCollector_Cache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	mc := NewMetricsCollector()
+	client, _ := NewClient(server.URL).
+		MetricsCollector(mc).
+		ResponseCache(NewResponseCache(time.Minute)).
+		Build()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("/cached")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	var sb strings.Builder
+	if err := mc.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	if !strings.Contains(sb.String(), `http_client_cache_requests_total{host="`+host+`",result="hit"} 2`) {
+		t.Errorf("unexpected cache hits:\n%s", sb.String())
+	}
+	if !strings.Contains(sb.String(), `http_client_cache_requests_total{host="`+host+`",result="miss"} 1`) {
+		t.Errorf("unexpected cache misses:\n%s", sb.String())
+	}
+}
+
+// 测试标签值转义与自定义分桶
+func TestMetricsCollector_Format(t *testing.T) {
+	mc := NewMetricsCollector()
+	mc.Namespace = "svc"
+	mc.Buckets = []float64{0.1, 1}
+	mc.observe("latency", "test", []string{"route"}, []string{`a"b\c`}, 0.5)
+
+	var sb strings.Builder
+	_ = mc.WritePrometheus(&sb)
+	out := sb.String()
+	for _, want := range []string{
+		`svc_latency_bucket{route="a\"b\\c",le="0.1"} 0`,
+		`svc_latency_bucket{route="a\"b\\c",le="1"} 1`,
+		`svc_latency_bucket{route="a\"b\\c",le="+Inf"} 1`,
+		`svc_latency_sum{route="a\"b\\c"} 0.5`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q\n%s", want, out)
+		}
+	}
+}
+
+// blockingWriter 第一次写入时阻塞，直到 release 关闭
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return len(p), nil
+}
+
+// 测试写出指标时不持锁：慢速抓取期间仍可记录指标
+func TestMetricsCollector_WriteDoesNotBlockRecording(t *testing.T) {
+	mc := NewMetricsCollector()
+	mc.inFlight("a", 1)
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- mc.WritePrometheus(w) }()
+	<-w.started
+
+	recorded := make(chan struct{})
+	go func() {
+		mc.inFlight("a", 1)
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(time.Second):
+		t.Error("recording blocked by WritePrometheus")
+	}
+	close(w.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	<-recorded
+}
@@ -0,0 +1,278 @@
+package k
+
+// http_oauth2.go —— OAuth2 令牌获取、缓存与刷新
+//
+// 设计目标：
+//   - 支持 client_credentials 与 refresh_token 两种授权方式
+//   - 令牌缓存到过期前 ExpiryDelta，并发请求在令牌失效时只触发一次刷新（singleflight）
+//   - refresh_token 轮换时自动保存新值，并可通过 OnRefresh 回调持久化
+//   - 接入 HTTPClient 后收到 401 会强制刷新令牌并重发一次
+//
+// 示例：
+//
+//	src := NewClientCredentialsSource(OAuth2Config{
+//	    TokenURL:     "https://auth.example.com/oauth/token",
+//	    ClientID:     "id",
+//	    ClientSecret: "secret",
+//	    Scopes:       []string{"orders.read"},
+//	})
+//	client, _ := NewClient("https://api.example.com").OAuth2(src).Build()
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2Token 访问令牌
+type OAuth2Token struct {
+	AccessToken  string
+	TokenType    string    // 通常为 "Bearer"
+	RefreshToken string    // 授权服务器下发的刷新令牌，可能为空
+	Expiry       time.Time // 过期时间，零值表示不过期
+}
+
+// valid 判断令牌在 delta 余量内是否仍可用
+func (t *OAuth2Token) valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry)
+}
+
+// authorization 返回 Authorization 头的值
+func (t *OAuth2Token) authorization() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer" // 部分服务端返回小写 bearer，但要求请求头使用规范大小写
+	}
+	return typ + " " + t.AccessToken
+}
+
+// OAuth2Error 令牌端点返回的错误（RFC 6749 §5.2）
+type OAuth2Error struct {
+	StatusCode  int
+	Code        string // error 字段，例如 "invalid_client"
+	Description string // error_description 字段
+	Body        []byte
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("oauth2: %s: %s (HTTP %d)", e.Code, e.Description, e.StatusCode)
+	}
+	return fmt.Sprintf("oauth2: token endpoint returned HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// OAuth2Config 令牌端点配置
+type OAuth2Config struct {
+	// TokenURL 令牌端点地址
+	TokenURL string
+	// ClientID / ClientSecret 客户端凭证
+	ClientID     string
+	ClientSecret string
+	// Scopes 申请的权限范围，以空格连接
+	Scopes []string
+	// RefreshToken refresh_token 授权方式的初始刷新令牌
+	RefreshToken string
+	// CredentialsInBody 为 true 时以表单参数 client_id / client_secret 传递凭证，默认使用 Basic Auth 头
+	CredentialsInBody bool
+	// EndpointParams 附加的表单参数，例如 audience
+	EndpointParams url.Values
+	// ExpiryDelta 提前多久视为过期，默认 30s
+	ExpiryDelta time.Duration
+	// HTTPClient 请求令牌端点使用的客户端，默认 30s 超时的 http.Client
+	HTTPClient *http.Client
+	// OnRefresh 获取到新令牌后回调，可用于持久化轮换后的 refresh_token
+	OnRefresh func(*OAuth2Token)
+}
+
+// OAuth2TokenSource 并发安全的令牌源，通过 NewClientCredentialsSource / NewRefreshTokenSource 创建。
+type OAuth2TokenSource struct {
+	cfg       OAuth2Config
+	grantType string
+
+	mu           sync.Mutex
+	token        *OAuth2Token
+	refreshToken string
+	inflight     *tokenCall // 正在进行的刷新，singleflight
+}
+
+// tokenCall 一次进行中的令牌请求
+type tokenCall struct {
+	done  chan struct{}
+	token *OAuth2Token
+	err   error
+}
+
+// NewClientCredentialsSource 创建 client_credentials 授权方式的令牌源。
+func NewClientCredentialsSource(cfg OAuth2Config) *OAuth2TokenSource {
+	return newTokenSource(cfg, "client_credentials")
+}
+
+// NewRefreshTokenSource 创建 refresh_token 授权方式的令牌源，cfg.RefreshToken 为初始刷新令牌。
+// 授权服务器轮换 refresh_token 时会自动使用新值。
+func NewRefreshTokenSource(cfg OAuth2Config) *OAuth2TokenSource {
+	return newTokenSource(cfg, "refresh_token")
+}
+
+func newTokenSource(cfg OAuth2Config, grantType string) *OAuth2TokenSource {
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OAuth2TokenSource{cfg: cfg, grantType: grantType, refreshToken: cfg.RefreshToken}
+}
+
+// Token 返回可用的令牌：缓存有效时直接返回，否则请求令牌端点。
+// 多个 goroutine 同时发现令牌失效时只会发出一次请求，其余等待同一结果。
+func (s *OAuth2TokenSource) Token(ctx context.Context) (*OAuth2Token, error) {
+	s.mu.Lock()
+	if s.token.valid(s.cfg.ExpiryDelta) {
+		tok := s.token
+		s.mu.Unlock()
+		return tok, nil
+	}
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		refreshToken := s.refreshToken
+		go s.fetch(call, refreshToken)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate 使当前令牌失效，下次 Token 调用会重新获取。
+// stale 为调用方持有的旧 access token：若缓存已被其他 goroutine 刷新为新令牌则不做处理，
+// 避免多个并发 401 接连作废刚刷新的令牌；传 "" 时无条件作废。
+func (s *OAuth2TokenSource) Invalidate(stale string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && (stale == "" || s.token.AccessToken == stale) {
+		s.token = nil
+	}
+}
+
+// SetToken 手动设置当前令牌（例如从持久化存储恢复），RefreshToken 非空时同时更新刷新令牌
+func (s *OAuth2TokenSource) SetToken(tok *OAuth2Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = tok
+	if tok != nil && tok.RefreshToken != "" {
+		s.refreshToken = tok.RefreshToken
+	}
+}
+
+// fetch 请求令牌端点并唤醒等待者。
+// 使用独立的 context（超时由 HTTPClient.Timeout 控制），避免首个调用方取消影响其他等待者。
+func (s *OAuth2TokenSource) fetch(call *tokenCall, refreshToken string) {
+	tok, err := s.requestToken(context.Background(), refreshToken)
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = tok
+		if tok.RefreshToken != "" {
+			s.refreshToken = tok.RefreshToken
+		} else {
+			tok.RefreshToken = refreshToken
+		}
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	if err == nil && s.cfg.OnRefresh != nil {
+		s.cfg.OnRefresh(tok)
+	}
+	call.token, call.err = tok, err
+	close(call.done)
+}
+
+// requestToken 按 RFC 6749 §4.4 / §6 请求令牌端点
+func (s *OAuth2TokenSource) requestToken(ctx context.Context, refreshToken string) (*OAuth2Token, error) {
+	form := url.Values{"grant_type": {s.grantType}}
+	for k, v := range s.cfg.EndpointParams {
+		form[k] = v
+	}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.grantType == "refresh_token" {
+		if refreshToken == "" {
+			return nil, fmt.Errorf("oauth2: refresh token is empty")
+		}
+		form.Set("refresh_token", refreshToken)
+	}
+	if s.cfg.CredentialsInBody {
+		form.Set("client_id", s.cfg.ClientID)
+		if s.cfg.ClientSecret != "" {
+			form.Set("client_secret", s.cfg.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.CredentialsInBody {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: read token response: %w", err)
+	}
+
+	var payload struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		RefreshToken     string      `json:"refresh_token"`
+		ExpiresIn        json.Number `json:"expires_in"` // 部分服务端以字符串返回
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isForm := mt == "application/x-www-form-urlencoded" || (mt == "text/plain" && !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")))
+	if isForm {
+		// 早期实现（如 GitHub）以表单格式返回
+		vals, _ := url.ParseQuery(string(body))
+		payload.AccessToken, payload.TokenType, payload.RefreshToken = vals.Get("access_token"), vals.Get("token_type"), vals.Get("refresh_token")
+		payload.ExpiresIn, payload.Error, payload.ErrorDescription = json.Number(vals.Get("expires_in")), vals.Get("error"), vals.Get("error_description")
+	} else if err = json.Unmarshal(body, &payload); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("oauth2: decode token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || payload.Error != "" || payload.AccessToken == "" {
+		return nil, &OAuth2Error{StatusCode: resp.StatusCode, Code: payload.Error, Description: payload.ErrorDescription, Body: body}
+	}
+
+	tok := &OAuth2Token{AccessToken: payload.AccessToken, TokenType: payload.TokenType, RefreshToken: payload.RefreshToken}
+	if secs, err := payload.ExpiresIn.Int64(); err == nil && secs > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return tok, nil
+}
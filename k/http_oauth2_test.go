@@ -0,0 +1,119 @@
+package k
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTokenServer 返回一个令牌端点，每次签发 "tok-N"，refresh_token 轮换为 "rt-N"
+func newTokenServer(t *testing.T, issued *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad credentials"}`)
+			return
+		}
+		if r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":"3600","refresh_token":"rt-%d"}`, n, n)
+	}))
+}
+
+// 测试并发获取令牌只请求一次，且令牌被缓存
+func TestOAuth2_ClientCredentialsSingleflight(t *testing.T) {
+	var issued atomic.Int32
+	ts := newTokenServer(t, &issued)
+	defer ts.Close()
+
+	src := NewClientCredentialsSource(OAuth2Config{TokenURL: ts.URL, ClientID: "id", ClientSecret: "secret"})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := src.Token(context.Background())
+			if err != nil || tok.AccessToken != "tok-1" {
+				t.Errorf("token = %v, err = %v", tok, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if issued.Load() != 1 {
+		t.Errorf("token endpoint called %d times", issued.Load())
+	}
+	if tok, _ := src.Token(context.Background()); tok.authorization() != "Bearer tok-1" {
+		t.Errorf("authorization = %q", tok.authorization())
+	}
+
+	bad := NewClientCredentialsSource(OAuth2Config{TokenURL: ts.URL, ClientID: "id", ClientSecret: "wrong"})
+	var oe *OAuth2Error
+	if _, err := bad.Token(context.Background()); !errors.As(err, &oe) || oe.Code != "invalid_client" {
+		t.Errorf("expected invalid_client, got %v", err)
+	}
+}
+
+// 测试 401 时强制刷新令牌并重发一次，refresh_token 轮换
+func TestOAuth2_RetryOn401(t *testing.T) {
+	var issued atomic.Int32
+	ts := newTokenServer(t, &issued)
+	defer ts.Close()
+
+	var rotated []string
+	src := NewRefreshTokenSource(OAuth2Config{
+		TokenURL:     ts.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RefreshToken: "rt-0",
+		OnRefresh:    func(tok *OAuth2Token) { rotated = append(rotated, tok.RefreshToken) },
+	})
+
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "Bearer tok-1" {
+			w.WriteHeader(http.StatusUnauthorized) // 模拟令牌被提前吊销
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer api.Close()
+
+	client, _ := NewClient(api.URL).OAuth2(src).Build()
+	resp, err := client.PostJSON("/orders", map[string]int{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := client.ReadBodyString(resp); resp.StatusCode != 200 || body != "ok" {
+		t.Fatalf("status = %d body = %q", resp.StatusCode, body)
+	}
+	if len(seen) != 2 || seen[1] != "Bearer tok-2" {
+		t.Errorf("authorization headers = %v", seen)
+	}
+	if len(rotated) != 2 || rotated[1] != "rt-2" {
+		t.Errorf("rotated refresh tokens = %v", rotated)
+	}
+
+	// 令牌仍被拒绝时只重发一次，把 401 返回给调用方
+	src.SetToken(&OAuth2Token{AccessToken: "tok-1"})
+	issued.Store(0)
+	resp, err = client.Get("/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(seen) != 4 {
+		t.Errorf("status = %d, calls = %d", resp.StatusCode, len(seen))
+	}
+}
@@ -0,0 +1,429 @@
+package k
+
+// http_paginate.go —— REST 分页迭代器：页码、偏移量、游标与 RFC 8288 Link rel="next"
+//
+// 设计目标：
+//   - Paginate 返回 Go 1.23 的 iter.Seq2[T, error]，调用方以 for range 逐条处理，提前 break 即停止翻页
+//   - 每页请求经过 HTTPClient 的完整流程（限速、熔断、重试、日志、钩子），并受 ctx 取消约束
+//   - 默认按 JSON 解析：整个响应体为数组，或用 ItemsField / CursorField（支持 "data.items" 形式）定位字段；
+//     其他格式通过 Decode 自定义
+//   - 页码分页可设置 Concurrency 并发预取后续页，结果仍按页序产出
+//
+// 示例：
+//
+//	for user, err := range Paginate[User](ctx, client, "/users", &PageOptions[User]{
+//		Style:      PageNumber,
+//		PageSize:   100,
+//		ItemsField: "data",
+//	}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(user.Name)
+//	}
+//
+//	// GitHub 风格：Link: <https://api.github.com/repos?page=2>; rel="next"
+//	repos := Paginate[Repo](ctx, client, "/user/repos", &PageOptions[Repo]{Style: PageLink, SizeParam: "per_page"})
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PageStyle 分页方式
+type PageStyle int
+
+const (
+	PageNumber PageStyle = iota // 页码：?page=1&size=20，返回条数少于 PageSize 时结束
+	PageOffset                  // 偏移量：?offset=0&limit=20，返回条数少于 PageSize 时结束
+	PageCursor                  // 游标：?cursor=xxx&limit=20，响应中的下一页游标为空时结束
+	PageLink                    // RFC 8288：响应头 Link 中没有 rel="next" 时结束；指向 baseURL 之外的链接返回错误
+)
+
+// PageOptions Paginate 的选项，零值字段使用默认值。
+type PageOptions[T any] struct {
+	// Style 分页方式，默认 PageNumber
+	Style PageStyle
+	// Request 每页请求共用的请求级配置（header、query 等），其中的 Context 会被 Paginate 的 ctx 替代
+	Request *RequestBuilder
+	// PageSize 每页条数，默认 20
+	PageSize int
+	// PageParam 页码 / 偏移量 / 游标的 query 参数名，默认 "page" / "offset" / "cursor"
+	PageParam string
+	// SizeParam 每页条数的 query 参数名，默认 "size"（PageNumber）/ "limit"（PageOffset、PageCursor）；
+	// PageLink 默认不发送，仅首页使用
+	SizeParam string
+	// StartPage 起始页码，默认 1（仅 PageNumber）
+	StartPage int
+	// MaxPages 最多请求的页数，0 表示不限制
+	MaxPages int
+	// Concurrency 并发请求的页数（仅 PageNumber），默认 1；到达末页前最多多请求 Concurrency-1 页
+	Concurrency int
+	// ItemsField 条目所在的 JSON 字段，支持以 "." 分隔的路径；为空表示整个响应体为数组
+	ItemsField string
+	// CursorField 下一页游标所在的 JSON 字段（仅 PageCursor），默认 "next_cursor"
+	CursorField string
+	// Decode 自定义解析，返回本页条目与下一页游标 / 链接（PageCursor、PageLink 使用）；设置后 ItemsField、CursorField 不生效
+	Decode func(resp *http.Response) (items []T, next string, err error)
+}
+
+// Paginate 逐页请求 path 并逐条产出条目。请求或解析失败时产出一次错误后结束。
+//
+// 参数：
+//   - ctx:  控制整个分页过程，取消后停止翻页
+//   - c:    发送请求的客户端
+//   - path: 首页路径，与 client.Get 相同
+//   - opts: 分页选项，nil 表示全部使用默认值
+func Paginate[T any](ctx context.Context, c *HTTPClient, path string, opts *PageOptions[T]) iter.Seq2[T, error] {
+	o := PageOptions[T]{}
+	if opts != nil {
+		o = *opts
+	}
+	o.defaults()
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		p := &pager[T]{c: c, o: &o, ctx: ctx}
+		if o.Style == PageNumber && o.Concurrency > 1 {
+			p.concurrent(path, yield)
+			return
+		}
+		p.sequential(path, yield)
+	}
+}
+
+func (o *PageOptions[T]) defaults() {
+	if o.PageSize <= 0 {
+		o.PageSize = 20
+	}
+	if o.StartPage == 0 {
+		o.StartPage = 1
+	}
+	if o.CursorField == "" {
+		o.CursorField = "next_cursor"
+	}
+	page, size := "", ""
+	switch o.Style {
+	case PageNumber:
+		page, size = "page", "size"
+	case PageOffset:
+		page, size = "offset", "limit"
+	case PageCursor:
+		page, size = "cursor", "limit"
+	}
+	if o.PageParam == "" {
+		o.PageParam = page
+	}
+	if o.SizeParam == "" {
+		o.SizeParam = size
+	}
+}
+
+// pager 一次分页迭代的状态
+type pager[T any] struct {
+	c   *HTTPClient
+	o   *PageOptions[T]
+	ctx context.Context
+}
+
+// sequential 逐页请求，适用于全部分页方式
+func (p *pager[T]) sequential(path string, yield func(T, error) bool) {
+	var zero T
+	page, offset, cursor := p.o.StartPage, 0, ""
+	for n := 0; p.o.MaxPages <= 0 || n < p.o.MaxPages; n++ {
+		if err := p.ctx.Err(); err != nil {
+			yield(zero, err)
+			return
+		}
+		params := map[string]string{}
+		switch p.o.Style {
+		case PageNumber:
+			params[p.o.PageParam] = strconv.Itoa(page)
+		case PageOffset:
+			params[p.o.PageParam] = strconv.Itoa(offset)
+		case PageCursor:
+			if cursor != "" {
+				params[p.o.PageParam] = cursor
+			}
+		}
+		// Link 分页的下一页地址已包含全部 query，不再追加
+		first := n == 0 || p.o.Style != PageLink
+		if p.o.SizeParam != "" && first {
+			params[p.o.SizeParam] = strconv.Itoa(p.o.PageSize)
+		}
+
+		items, next, err := p.fetch(path, p.request(params, first))
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+
+		switch p.o.Style {
+		case PageNumber, PageOffset:
+			if len(items) < p.o.PageSize {
+				return
+			}
+			page++
+			offset += len(items)
+		case PageCursor:
+			if next == "" {
+				return
+			}
+			if next == cursor {
+				yield(zero, errors.New("paginate: cursor did not advance"))
+				return
+			}
+			cursor = next
+		case PageLink:
+			if next == "" {
+				return
+			}
+			if path, err = p.c.nextPath(path, next); err != nil {
+				yield(zero, err)
+				return
+			}
+		}
+	}
+}
+
+// concurrent 页码分页：每轮并发请求 Concurrency 页，按页序产出，遇到不满一页的页后结束
+func (p *pager[T]) concurrent(path string, yield func(T, error) bool) {
+	var zero T
+	type result struct {
+		items []T
+		err   error
+	}
+	page, fetched := p.o.StartPage, 0
+	for {
+		if err := p.ctx.Err(); err != nil {
+			yield(zero, err)
+			return
+		}
+		n := p.o.Concurrency
+		if p.o.MaxPages > 0 {
+			n = min(n, p.o.MaxPages-fetched)
+		}
+		if n <= 0 {
+			return
+		}
+		results := make([]result, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rb := p.request(map[string]string{
+					p.o.PageParam: strconv.Itoa(page + i),
+					p.o.SizeParam: strconv.Itoa(p.o.PageSize),
+				}, true)
+				results[i].items, _, results[i].err = p.fetch(path, rb)
+			}()
+		}
+		wg.Wait()
+
+		for _, r := range results {
+			if r.err != nil {
+				yield(zero, r.err)
+				return
+			}
+			for _, item := range r.items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(r.items) < p.o.PageSize {
+				return
+			}
+		}
+		page += n
+		fetched += n
+	}
+}
+
+// request 基于 PageOptions.Request 构造本页的请求配置
+func (p *pager[T]) request(params map[string]string, keepQuery bool) *RequestBuilder {
+	rb := p.o.Request.clone()
+	rb.cfg.ctx = p.ctx
+	if !keepQuery || rb.cfg.queryParams == nil {
+		rb.cfg.queryParams = make(map[string]string, len(params))
+	}
+	for k, v := range params {
+		if k != "" {
+			rb.cfg.queryParams[k] = v
+		}
+	}
+	return rb
+}
+
+// fetch 请求一页并解析条目与下一页游标 / 链接，非 2xx 响应返回 *HTTPError
+func (p *pager[T]) fetch(path string, rb *RequestBuilder) ([]T, string, error) {
+	resp, err := p.c.Get(path, rb)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := p.c.ReadBody(resp)
+		return nil, "", &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+
+	var (
+		items []T
+		next  string
+	)
+	if p.o.Decode != nil {
+		defer resp.Body.Close()
+		if items, next, err = p.o.Decode(resp); err != nil {
+			return nil, "", err
+		}
+	} else {
+		body, err := p.c.ReadBody(resp)
+		if err != nil {
+			return nil, "", err
+		}
+		raw, err := jsonPath(body, p.o.ItemsField)
+		if err != nil {
+			return nil, "", err
+		}
+		if raw != nil {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, "", err
+			}
+		}
+		if p.o.Style == PageCursor {
+			if next, err = jsonCursor(body, p.o.CursorField); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if p.o.Style == PageLink && next == "" {
+		next = nextLink(resp)
+	}
+	return items, next, nil
+}
+
+// jsonPath 按 "a.b.c" 路径取出 JSON 字段，path 为空时返回整个文档，字段不存在时返回 nil
+func jsonPath(doc []byte, path string) (json.RawMessage, error) {
+	raw := json.RawMessage(doc)
+	if path == "" {
+		return raw, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		if raw = obj[key]; raw == nil {
+			return nil, nil
+		}
+	}
+	return raw, nil
+}
+
+// jsonCursor 读取游标字段，支持字符串与数字，null 或缺失时返回空
+func jsonCursor(doc []byte, path string) (string, error) {
+	raw, err := jsonPath(doc, path)
+	if err != nil || raw == nil || bytes.Equal(raw, []byte("null")) {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, nil
+	}
+	return string(raw), nil
+}
+
+// nextLink 从 Link 响应头中取出 rel="next" 的地址，相对地址按请求 URL 解析
+func nextLink(resp *http.Response) string {
+	for _, v := range resp.Header.Values("Link") {
+		for {
+			start := strings.IndexByte(v, '<')
+			end := strings.IndexByte(v, '>')
+			if start < 0 || end < start {
+				break
+			}
+			target, params := v[start+1:end], v[end+1:]
+			v = ""
+			if i := strings.IndexByte(params, '<'); i >= 0 {
+				params, v = params[:i], params[i:]
+			}
+			if !relIsNext(params) {
+				continue
+			}
+			u, err := url.Parse(target)
+			if err != nil {
+				return ""
+			}
+			if resp.Request != nil {
+				u = resp.Request.URL.ResolveReference(u)
+			}
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// relIsNext 判断 Link 参数中的 rel 是否包含 next（rel 可有多个以空格分隔的值）
+func relIsNext(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+			if strings.EqualFold(rel, "next") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextPath 将下一页链接转换为请求路径：位于 baseURL（或负载均衡端点）之下时转换为相对路径，以便沿用客户端的拼接与端点选择；
+// 未设置 baseURL 时只接受与当前页同源（scheme://host）的完整地址。其他地址返回错误，避免把鉴权信息带到第三方站点。
+func (c *HTTPClient) nextPath(cur, next string) (string, error) {
+	bases := []string{c.builder.baseURL}
+	if lb := c.builder.balancer; lb != nil {
+		for _, ep := range lb.endpoints {
+			bases = append(bases, ep.base)
+		}
+	}
+	for _, base := range bases {
+		if base == "" || !strings.HasPrefix(next, base) {
+			continue
+		}
+		if rest := next[len(base):]; rest == "" || rest[0] == '/' || rest[0] == '?' {
+			return rest, nil
+		}
+	}
+	if c.builder.baseURL == "" && c.builder.balancer == nil && sameOrigin(cur, next) {
+		return next, nil
+	}
+	return "", fmt.Errorf("paginate: next link %q is outside the client's base URL", next)
+}
+
+// sameOrigin 判断两个地址的 scheme 与 host 是否相同
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
@@ -0,0 +1,213 @@
+package k
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pageServer 提供 0..total-1 共 total 个整数，支持四种分页方式
+func pageServer(t *testing.T, total int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		q := r.URL.Query()
+		items := func(from, size int) []int {
+			out := []int{}
+			for i := from; i < from+size && i < total; i++ {
+				out = append(out, i)
+			}
+			return out
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/pages":
+			page, _ := strconv.Atoi(q.Get("page"))
+			size, _ := strconv.Atoi(q.Get("size"))
+			json.NewEncoder(w).Encode(map[string]any{"data": items((page-1)*size, size)})
+		case "/offset":
+			off, _ := strconv.Atoi(q.Get("offset"))
+			size, _ := strconv.Atoi(q.Get("limit"))
+			json.NewEncoder(w).Encode(items(off, size))
+		case "/cursor":
+			from, _ := strconv.Atoi(q.Get("cursor"))
+			size, _ := strconv.Atoi(q.Get("limit"))
+			var next any
+			if from+size < total {
+				next = from + size // 数字游标
+			}
+			json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{"items": items(from, size), "paging": map[string]any{"next": next}}})
+		case "/link":
+			if q.Get("tenant") != "t1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			from, _ := strconv.Atoi(q.Get("from"))
+			if from+3 < total {
+				w.Header().Add("Link", `<https://example.com/docs>; rel="help"`)
+				w.Header().Add("Link", fmt.Sprintf(`</link?tenant=t1&from=%d>; rel="next last", <%s/link?from=0>; rel="first"`, from+3, "http://"+r.Host))
+			}
+			json.NewEncoder(w).Encode(items(from, 3))
+		}
+	}))
+}
+
+func collect[T any](t *testing.T, seq func(func(T, error) bool)) []T {
+	t.Helper()
+	var out []T
+	for v, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func wantSeq(t *testing.T, got []int, n int) {
+	t.Helper()
+	if len(got) != n {
+		t.Fatalf("got %d items: %v", len(got), got)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("item %d = %d", i, v)
+		}
+	}
+}
+
+// 测试四种分页方式
+func TestPaginate_Styles(t *testing.T) {
+	var requests atomic.Int32
+	srv := pageServer(t, 23, &requests)
+	defer srv.Close()
+	client, _ := NewClient(srv.URL + "/").Build()
+	ctx := context.Background()
+
+	wantSeq(t, collect(t, Paginate[int](ctx, client, "/pages", &PageOptions[int]{PageSize: 5, ItemsField: "data"})), 23)
+	wantSeq(t, collect(t, Paginate[int](ctx, client, "/offset", &PageOptions[int]{Style: PageOffset, PageSize: 10})), 23)
+	wantSeq(t, collect(t, Paginate[int](ctx, client, "/cursor", &PageOptions[int]{
+		Style: PageCursor, PageSize: 4, ItemsField: "result.items", CursorField: "result.paging.next",
+	})), 23)
+	wantSeq(t, collect(t, Paginate[int](ctx, client, "/link", &PageOptions[int]{
+		Style:   PageLink,
+		Request: R().QueryParams(map[string]string{"tenant": "t1"}),
+	})), 23)
+
+	// 恰好整页时多请求一页空页后结束
+	requests.Store(0)
+	wantSeq(t, collect(t, Paginate[int](ctx, client, "/offset", &PageOptions[int]{Style: PageOffset, PageSize: 23})), 23)
+	if requests.Load() != 2 {
+		t.Errorf("requests = %d", requests.Load())
+	}
+}
+
+// 测试并发页码分页仍按页序产出，并经过限速器
+func TestPaginate_ConcurrentPages(t *testing.T) {
+	var requests atomic.Int32
+	srv := pageServer(t, 95, &requests)
+	defer srv.Close()
+
+	limiter := NewRateLimiter(1000, 1000)
+	client, _ := NewClient(srv.URL).RateLimiter(limiter).Build()
+	got := collect(t, Paginate[int](context.Background(), client, "/pages", &PageOptions[int]{
+		PageSize: 10, ItemsField: "data", Concurrency: 4,
+	}))
+	wantSeq(t, got, 95)
+	if n := requests.Load(); n != 12 { // 10 页数据，最后一轮 9~12 页并发
+		t.Errorf("requests = %d", n)
+	}
+
+	// MaxPages 限制总页数
+	requests.Store(0)
+	got = collect(t, Paginate[int](context.Background(), client, "/pages", &PageOptions[int]{
+		PageSize: 10, ItemsField: "data", Concurrency: 4, MaxPages: 3,
+	}))
+	if len(got) != 30 || requests.Load() != 3 {
+		t.Errorf("items=%d requests=%d", len(got), requests.Load())
+	}
+}
+
+// 测试提前 break、context 取消与错误
+func TestPaginate_StopAndErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := pageServer(t, 1000, &requests)
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Build()
+
+	n := 0
+	for v, err := range Paginate[int](context.Background(), client, "/offset", &PageOptions[int]{Style: PageOffset, PageSize: 10}) {
+		if err != nil || v != n {
+			t.Fatalf("v=%d err=%v", v, err)
+		}
+		if n++; n == 15 {
+			break
+		}
+	}
+	if requests.Load() != 2 {
+		t.Errorf("requests after break = %d", requests.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	slow, _ := NewClient(srv.URL).RateLimiter(NewRateLimiter(20, 1)).Build()
+	var lastErr error
+	for _, err := range Paginate[int](ctx, slow, "/offset", &PageOptions[int]{Style: PageOffset, PageSize: 1}) {
+		lastErr = err
+	}
+	if !errors.Is(lastErr, context.DeadlineExceeded) {
+		t.Errorf("err = %v", lastErr)
+	}
+
+	// 非 2xx 返回 *HTTPError
+	for _, err := range Paginate[int](context.Background(), client, "/link", &PageOptions[int]{Style: PageLink}) {
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("err = %v", err)
+		}
+	}
+}
+
+func TestPaginate_CrossOriginLink(t *testing.T) {
+	var leaked atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Add(1)
+		fmt.Fprint(w, "[99]")
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		} else {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=3>; rel="next"`, other.URL))
+		}
+		fmt.Fprint(w, "[1]")
+	}))
+	defer srv.Close()
+
+	for base, path := range map[string]string{srv.URL: "/items", "": srv.URL + "/items"} {
+		client, _ := NewClient(base).BearerToken(func() string { return "secret" }).Build()
+		var items []int
+		var lastErr error
+		for v, err := range Paginate[int](context.Background(), client, path, &PageOptions[int]{Style: PageLink}) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			items = append(items, v)
+		}
+		if len(items) != 2 || lastErr == nil {
+			t.Errorf("base %q: items = %v, err = %v", base, items, lastErr)
+		}
+	}
+	if leaked.Load() != 0 {
+		t.Fatalf("followed cross-origin link %d times", leaked.Load())
+	}
+}
@@ -0,0 +1,159 @@
+package k
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadProxyURL 返回一个已关闭端口的代理地址，连接会被拒绝
+func deadProxyURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return "http://" + addr
+}
+
+// 测试平滑加权轮询
+func TestProxyPool_Weight(t *testing.T) {
+	pool := NewProxyPool(nil, ProxyStrategyWeight)
+	pool.AddProxy("http://127.0.0.1:7890", 3)
+	pool.AddProxy("http://127.0.0.1:7891", 1)
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		u, err := pool.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[u.Host]++
+	}
+	if counts["127.0.0.1:7890"] != 6 || counts["127.0.0.1:7891"] != 2 {
+		t.Errorf("unexpected distribution: %v", counts)
+	}
+}
+
+// 测试最低延迟策略与 socks5 / 无协议地址解析
+func TestProxyPool_LeastLatency(t *testing.T) {
+	pool := NewProxyPool([]string{"socks5://127.0.0.1:1080", "127.0.0.1:3128"}, ProxyStrategyLeastLatency)
+	pxs := pool.proxies
+	pool.reportSuccess(pxs[0], 80*time.Millisecond)
+	pool.reportSuccess(pxs[1], 20*time.Millisecond)
+
+	u, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != "http://127.0.0.1:3128" {
+		t.Errorf("expected fastest proxy, got %s", u)
+	}
+	if pxs[0].parsed.Scheme != "socks5" {
+		t.Errorf("socks5 scheme lost: %s", pxs[0].parsed)
+	}
+}
+
+// 测试请求失败时被动下线代理，并切换到健康代理
+func TestProxyPool_PassiveMarkDown(t *testing.T) {
+	// 作为 HTTP 正向代理：收到绝对 URI 请求直接应答
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("via proxy"))
+	}))
+	defer good.Close()
+
+	dead := deadProxyURL(t)
+	pool := NewProxyPool([]string{dead, good.URL}, ProxyStrategyRoundRobin)
+	pool.MaxFails = 1
+
+	client, _ := NewClient("http://upstream.invalid").
+		ProxyPool(pool).
+		Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).
+		Build()
+
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for _, st := range pool.Stats() {
+		switch st.URL {
+		case dead:
+			if st.Alive || st.Failures == 0 || st.LastError == "" {
+				t.Errorf("dead proxy should be marked down: %+v", st)
+			}
+		case good.URL:
+			if !st.Alive || st.Successes == 0 {
+				t.Errorf("good proxy should be alive: %+v", st)
+			}
+		}
+	}
+}
+
+// 测试主动健康检查
+func TestProxyPool_HealthCheck(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+
+	dead := deadProxyURL(t)
+	pool := NewProxyPool([]string{dead, good.URL}, ProxyStrategyRoundRobin)
+	pool.MaxFails = 1
+	pool.RecoverAfter = 0
+	stop := pool.StartHealthCheck("http://health.invalid/", time.Hour, time.Second)
+	defer stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := pool.Stats()
+		if !stats[0].Alive && stats[1].Alive && stats[1].Successes > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("health check did not converge: %+v", pool.Stats())
+}
+
+// 测试探测失败同样遵循 MaxFails：连续失败达到阈值才下线
+func TestProxyPool_HealthCheckMaxFails(t *testing.T) {
+	dead := deadProxyURL(t)
+	pool := NewProxyPool([]string{dead}, ProxyStrategyRoundRobin)
+	pool.MaxFails = 3
+
+	for i := 1; i <= 3; i++ {
+		pool.checkAll(context.Background(), "http://health.invalid/", time.Second)
+		st := pool.Stats()[0]
+		if st.ConsecutiveFailures != i || st.Alive != (i < 3) {
+			t.Fatalf("after %d probes: %+v", i, st)
+		}
+	}
+}
+
+// 测试探测进行中被手动 MarkDown 的代理，探测成功后仍保持下线
+func TestProxyPool_HealthCheckKeepsManualDown(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	defer proxy.Close()
+
+	pool := NewProxyPool([]string{proxy.URL}, ProxyStrategyRoundRobin)
+	done := make(chan struct{})
+	go func() {
+		pool.checkAll(context.Background(), "http://health.invalid/", 5*time.Second)
+		close(done)
+	}()
+	<-entered
+	pool.MarkDown(proxy.URL)
+	close(release)
+	<-done
+
+	if st := pool.Stats()[0]; st.Alive || st.Successes != 1 {
+		t.Fatalf("manually downed proxy revived by probe: %+v", st)
+	}
+}
@@ -0,0 +1,275 @@
+package k
+
+// http_ratelimit.go —— 出站限速：进程内令牌桶、基于 store.AdapterCache 的分布式限速、按 host / key 分组
+//
+// 设计目标：
+//   - Limiter 接口：ClientBuilder.RateLimiter 接受任意实现，请求在发出前调用 Acquire
+//   - RateLimiter：进程内令牌桶，多副本部署时每个副本各自拥有完整配额
+//   - CacheRateLimiter：固定时间窗口计数存放在共享缓存（如 Redis），多副本共享同一配额
+//   - KeyedLimiter：按 host 或自定义 key（租户、API Key）为每组请求使用独立的限速器
+//
+// 示例：
+//
+//	// 20 个副本合计每秒最多 100 个请求，每个第三方 host 独立计数
+//	limiter := NewHostLimiter(func(host string) Limiter {
+//	    return NewCacheRateLimiter(redisCache, "ratelimit:"+host, 100, time.Second)
+//	})
+//	client, _ := NewClient("").RateLimiter(limiter).Build()
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// Limiter 出站请求限速器。
+// 对冲请求使用 TryAcquire，无许可时放弃对冲而不是等待。
+type Limiter interface {
+	// Acquire 阻塞直到请求获得许可，req.Context() 取消时返回错误
+	Acquire(req *http.Request) error
+	// TryAcquire 非阻塞地尝试获得许可
+	TryAcquire(req *http.Request) bool
+}
+
+// ─── 进程内令牌桶 ──────────────────────────────────────
+
+// RateLimiter 基于令牌桶算法的限速器，并发安全。
+// 令牌按照 rps 速率持续补充，桶的容量由 burst 决定。
+type RateLimiter struct {
+	mu         sync.Mutex
+	tokens     float64   // 当前令牌数
+	maxTokens  float64   // 桶的最大容量（= burst）
+	refillRate float64   // 每秒补充的令牌数（= rps）
+	lastRefill time.Time // 上次补充时间，用于计算增量
+}
+
+// NewRateLimiter 创建令牌桶限速器。
+//
+// 参数：
+//   - rps:   每秒允许的稳定请求数，例如 100.0 表示每秒 100 个请求
+//   - burst: 瞬时峰值（桶的容量），例如 20 表示瞬间最多 20 个并发请求
+//
+// 示例：
+//
+//	rl := NewRateLimiter(100, 20) // 稳定 100 rps，瞬时最多 20
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		tokens:     float64(burst),
+		maxTokens:  float64(burst),
+		refillRate: rps,
+		lastRefill: time.Now(),
+	}
+}
+
+// Wait 阻塞等待直到获取到令牌，context 取消时立即返回错误。
+// 此方法由 HTTPClient 内部调用，通常不需要外部直接调用。
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.tokens = minFloat(r.maxTokens, r.tokens+now.Sub(r.lastRefill).Seconds()*r.refillRate)
+		r.lastRefill = now
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1-r.tokens)/r.refillRate*1000) * time.Millisecond
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Acquire 实现 Limiter，等价于 Wait(req.Context())
+func (r *RateLimiter) Acquire(req *http.Request) error {
+	return r.Wait(req.Context())
+}
+
+// TryAcquire 实现 Limiter，非阻塞地获取一个令牌
+func (r *RateLimiter) TryAcquire(*http.Request) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.tokens = minFloat(r.maxTokens, r.tokens+now.Sub(r.lastRefill).Seconds()*r.refillRate)
+	r.lastRefill = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ─── 分布式固定窗口 ────────────────────────────────────
+
+// CacheRateLimiter 基于 store.AdapterCache 的分布式限速器，多个副本共享同一配额。
+//
+// 采用固定时间窗口计数：每个窗口一个计数键，超过 limit 后等待下一个窗口。
+// AdapterCache 没有返回新值的原子自增，自增与读取之间存在竞争窗口：
+// 并发时可能多拒绝（双方都读到对方的自增），不会超过配额；
+// 仅在窗口的第一个请求由多个副本同时创建计数键时可能少计一次。
+// 各副本依赖本地时钟划分窗口，需保持时钟同步。
+type CacheRateLimiter struct {
+	// FailOpen 为 true 时缓存不可用则放行请求，默认返回错误
+	FailOpen bool
+
+	cache  store.AdapterCache
+	key    string
+	limit  int
+	window time.Duration
+}
+
+// NewCacheRateLimiter 创建分布式限速器。
+//
+// 参数：
+//   - cache:  共享缓存，如 Redis 适配器；store.NewMemory() 仅适用于单进程测试
+//   - key:    计数键前缀，共享同一配额的副本须使用相同的 key
+//   - limit:  每个窗口允许的请求数
+//   - window: 窗口时长，例如 time.Second
+func NewCacheRateLimiter(cache store.AdapterCache, key string, limit int, window time.Duration) *CacheRateLimiter {
+	if window <= 0 {
+		window = time.Second
+	}
+	return &CacheRateLimiter{cache: cache, key: key, limit: limit, window: window}
+}
+
+// Wait 阻塞直到获得许可，ctx 取消时返回错误
+func (l *CacheRateLimiter) Wait(ctx context.Context) error {
+	for {
+		ok, next, err := l.take()
+		if err != nil {
+			if l.FailOpen {
+				return nil
+			}
+			return err
+		}
+		if ok {
+			return nil
+		}
+		// 等到下一个窗口，加少量随机抖动避免所有副本在窗口边界同时涌入
+		wait := time.Until(next) + time.Duration(rand.Int63n(int64(l.window)/10+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Acquire 实现 Limiter，等价于 Wait(req.Context())
+func (l *CacheRateLimiter) Acquire(req *http.Request) error {
+	return l.Wait(req.Context())
+}
+
+// TryAcquire 实现 Limiter，当前窗口配额已用完时返回 false
+func (l *CacheRateLimiter) TryAcquire(*http.Request) bool {
+	ok, _, err := l.take()
+	if err != nil {
+		return l.FailOpen
+	}
+	return ok
+}
+
+// take 在当前窗口计数加一，返回是否未超过配额以及下一个窗口的开始时间
+func (l *CacheRateLimiter) take() (bool, time.Time, error) {
+	slot := time.Now().UnixNano() / int64(l.window)
+	next := time.Unix(0, (slot+1)*int64(l.window))
+	key := l.key + ":" + strconv.FormatInt(slot, 10)
+	ttl := int(math.Ceil(l.window.Seconds())) + 1
+
+	if err := l.cache.Increase(key); err != nil {
+		// 计数键不存在（Redis 的 INCR 会自动创建，内存实现返回错误），由本次请求创建
+		if err := l.cache.Set(key, 1, ttl); err != nil {
+			return false, next, fmt.Errorf("cache rate limiter: %w", err)
+		}
+		return l.limit >= 1, next, nil
+	}
+	v, err := l.cache.Get(key)
+	if err != nil {
+		return false, next, fmt.Errorf("cache rate limiter: %w", err)
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return false, next, fmt.Errorf("cache rate limiter: bad counter %q: %w", v, err)
+	}
+	if n == 1 {
+		// INCR 创建的键没有过期时间
+		_ = l.cache.Expire(key, time.Duration(ttl)*time.Second)
+	}
+	return n <= l.limit, next, nil
+}
+
+// ─── 分组 ──────────────────────────────────────────────
+
+// KeyedLimiter 按 key 分组的限速器，每个 key 首次出现时通过 factory 创建独立的 Limiter。
+// key 的取值应有限（host、租户），否则限速器数量会持续增长。
+type KeyedLimiter struct {
+	keyFn   func(*http.Request) string
+	factory func(key string) Limiter
+
+	mu    sync.Mutex
+	items map[string]Limiter
+}
+
+// NewKeyedLimiter 创建分组限速器。
+//
+// 参数：
+//   - keyFn:   从请求计算分组 key，例如读取 X-Tenant 头；返回 "" 时该请求不限速
+//   - factory: 为新 key 创建限速器
+func NewKeyedLimiter(keyFn func(*http.Request) string, factory func(key string) Limiter) *KeyedLimiter {
+	return &KeyedLimiter{keyFn: keyFn, factory: factory, items: make(map[string]Limiter)}
+}
+
+// NewHostLimiter 创建按目标 host 分组的限速器
+func NewHostLimiter(factory func(host string) Limiter) *KeyedLimiter {
+	return NewKeyedLimiter(func(req *http.Request) string { return req.URL.Host }, factory)
+}
+
+// Acquire 实现 Limiter
+func (g *KeyedLimiter) Acquire(req *http.Request) error {
+	if l := g.get(req); l != nil {
+		return l.Acquire(req)
+	}
+	return nil
+}
+
+// TryAcquire 实现 Limiter
+func (g *KeyedLimiter) TryAcquire(req *http.Request) bool {
+	if l := g.get(req); l != nil {
+		return l.TryAcquire(req)
+	}
+	return true
+}
+
+// get 返回请求所属分组的限速器，key 为空时返回 nil
+func (g *KeyedLimiter) get(req *http.Request) Limiter {
+	key := g.keyFn(req)
+	if key == "" {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.items[key]
+	if !ok {
+		l = g.factory(key)
+		g.items[key] = l
+	}
+	return l
+}
@@ -0,0 +1,129 @@
+package k
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// alignWindow 等到下一个窗口开始，避免测试跨越窗口边界
+func alignWindow(window time.Duration) {
+	now := time.Now().UnixNano()
+	time.Sleep(time.Duration(int64(window) - now%int64(window)))
+}
+
+// 测试两个副本共享同一配额
+func TestCacheRateLimiter_SharedQuota(t *testing.T) {
+	cache := store.NewMemory()
+	window := 500 * time.Millisecond
+	a := NewCacheRateLimiter(cache, "rl:api", 3, window)
+	b := NewCacheRateLimiter(cache, "rl:api", 3, window)
+	other := NewCacheRateLimiter(cache, "rl:other", 3, window)
+
+	alignWindow(window)
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if a.TryAcquire(req) {
+			allowed++
+		}
+		if b.TryAcquire(req) {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed = %d, want 3", allowed)
+	}
+	if !other.TryAcquire(req) {
+		t.Error("different key should have its own quota")
+	}
+
+	// 配额用完后 Wait 阻塞到下一个窗口
+	start := time.Now()
+	if err := a.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("Wait returned after %v, expected to block until next window", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for a.TryAcquire(req) {
+	}
+	if err := a.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait err = %v", err)
+	}
+}
+
+// 测试按 host 分组，以及 key 为空时不限速
+func TestKeyedLimiter(t *testing.T) {
+	var created atomic.Int32
+	hosts := NewHostLimiter(func(host string) Limiter {
+		created.Add(1)
+		return NewRateLimiter(0.001, 1)
+	})
+	a := httptest.NewRequest(http.MethodGet, "http://a.example.com/", nil)
+	b := httptest.NewRequest(http.MethodGet, "http://b.example.com/", nil)
+	if !hosts.TryAcquire(a) || !hosts.TryAcquire(b) {
+		t.Fatal("each host should have its own bucket")
+	}
+	if hosts.TryAcquire(a) {
+		t.Error("host a should be exhausted")
+	}
+	if created.Load() != 2 {
+		t.Errorf("created = %d", created.Load())
+	}
+
+	tenants := NewKeyedLimiter(func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+		func(string) Limiter { return NewRateLimiter(0.001, 1) })
+	for i := 0; i < 3; i++ {
+		if err := tenants.Acquire(a); err != nil {
+			t.Fatal("request without tenant should not be limited")
+		}
+	}
+}
+
+// 测试作为客户端限速器使用
+func TestClient_CacheRateLimiter(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	window := 300 * time.Millisecond
+	client, _ := NewClient(srv.URL).
+		RateLimiter(NewCacheRateLimiter(store.NewMemory(), "rl", 2, window)).
+		Build()
+
+	alignWindow(window)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if d := time.Since(start); d < window/2 {
+		t.Errorf("4 requests with limit 2/window took %v", d)
+	}
+	if hits.Load() != 4 {
+		t.Errorf("hits = %d", hits.Load())
+	}
+
+	// 兼容传入 nil *RateLimiter
+	var rl *RateLimiter
+	plain, _ := NewClient(srv.URL).RateLimiter(rl).Build()
+	resp, err := plain.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,617 @@
+package k
+
+// http_sign.go —— 内置请求签名方案与服务端校验中间件
+//
+// 客户端签名器均返回 func(*http.Request) error，直接传给 ClientBuilder.Sign，
+// 在每次发送（含重试）前执行，因此时间戳 / nonce 每次尝试都会刷新：
+//   - HMACSigner：通用 HMAC-SHA256，对 method / path / query / body 摘要 / 时间戳 / nonce 签名
+//   - AWSSigV4Signer：AWS Signature Version 4
+//   - ParamsSigner：参数按 key 排序拼接 "&key=密钥" 后取 MD5 / SHA256 / HMAC-SHA256（微信支付 v2 等国内支付接口常见）
+//
+// 服务端：
+//   - HMACVerifier：校验 HMACSigner 的签名，带时间窗口与 nonce 防重放
+//   - SignParams：ParamsSigner 的纯函数版本，服务端可直接用于验签
+//
+// 示例：
+//
+//	client, _ := NewClient("https://api.example.com").Sign(HMACSigner("ak", "sk")).Build()
+//
+//	mux.Handle("/api/", HMACVerifier(func(ak string) (string, bool) {
+//	    sk, ok := secrets[ak]
+//	    return sk, ok
+//	}, nil)(apiHandler))
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuangshp/go-utils/k/store"
+)
+
+// HMACSigner / HMACVerifier 使用的请求头
+const (
+	HeaderAccessKey = "X-Access-Key"
+	HeaderTimestamp = "X-Timestamp" // Unix 秒
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature" // 小写十六进制 HMAC-SHA256
+)
+
+var (
+	ErrSignatureMissing = errors.New("signature headers missing")
+	ErrSignatureInvalid = errors.New("signature mismatch")
+	ErrSignatureExpired = errors.New("signature timestamp out of range")
+	ErrNonceReplayed    = errors.New("nonce already used")
+	ErrUnknownAccessKey = errors.New("unknown access key")
+	ErrSignedBodyTooBig = errors.New("signed request body too large")
+)
+
+// signNow 当前时间，测试中可替换
+var signNow = time.Now
+
+// ═══════════════════════════════════════════════════════
+// HMAC-SHA256
+// ═══════════════════════════════════════════════════════
+
+// HMACSigner 返回通用 HMAC-SHA256 签名器。
+//
+// 待签名串（各行以 "\n" 连接）：
+//
+//	METHOD
+//	PATH（已转义）
+//	按 key 排序并编码的 query
+//	ACCESS_KEY
+//	TIMESTAMP
+//	NONCE
+//	HEX(SHA256(body))
+//
+// 签名结果写入 X-Signature，同时写入 X-Access-Key / X-Timestamp / X-Nonce。
+//
+// 参数：
+//   - accessKey: 公开的访问标识，服务端据此查找密钥
+//   - secretKey: 签名密钥
+func HMACSigner(accessKey, secretKey string) func(*http.Request) error {
+	return func(req *http.Request) error {
+		bodyHash, err := signingBodyHash(req)
+		if err != nil {
+			return err
+		}
+		ts := strconv.FormatInt(signNow().Unix(), 10)
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		sig := hmacSignature(secretKey, hmacCanonical(req, accessKey, ts, nonce, bodyHash))
+
+		req.Header.Set(HeaderAccessKey, accessKey)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderNonce, nonce)
+		req.Header.Set(HeaderSignature, sig)
+		return nil
+	}
+}
+
+// hmacCanonical 构造 HMACSigner 的待签名串，bodyHash 为请求体 SHA-256 的十六进制
+func hmacCanonical(req *http.Request, accessKey, ts, nonce, bodyHash string) string {
+	return strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(), // Encode 按 key 排序
+		accessKey,
+		ts,
+		nonce,
+		bodyHash,
+	}, "\n")
+}
+
+func hmacSignature(secretKey, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NonceStore 记录已使用的 nonce，用于防重放。
+type NonceStore interface {
+	// Add 记录 nonce 并在 ttl 后过期；nonce 已存在时返回 false
+	Add(nonce string, ttl time.Duration) (bool, error)
+}
+
+// memoryNonceStore 进程内 nonce 存储，写入时顺带清理过期项
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	items  map[string]time.Time
+	nextGC time.Time
+}
+
+// NewMemoryNonceStore 创建进程内 nonce 存储，单实例部署时使用。
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{items: make(map[string]time.Time)}
+}
+
+func (m *memoryNonceStore) Add(nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.After(m.nextGC) {
+		for k, exp := range m.items {
+			if now.After(exp) {
+				delete(m.items, k)
+			}
+		}
+		m.nextGC = now.Add(ttl)
+	}
+	if exp, ok := m.items[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.items[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// cacheNonceStore 基于 store.AdapterCache 的 nonce 存储
+type cacheNonceStore struct {
+	cache store.AdapterCache
+}
+
+// NewCacheNonceStore 基于 store.AdapterCache（如 Redis 适配器）创建 nonce 存储，多实例部署时共享。
+// AdapterCache 没有原子的 SETNX，先查后写之间存在极小的竞争窗口。
+func NewCacheNonceStore(cache store.AdapterCache) NonceStore {
+	return &cacheNonceStore{cache: cache}
+}
+
+func (c *cacheNonceStore) Add(nonce string, ttl time.Duration) (bool, error) {
+	key := "sign_nonce:" + nonce
+	if v, err := c.cache.Get(key); err == nil && v != "" {
+		return false, nil
+	}
+	secs := int(ttl / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return true, c.cache.Set(key, "1", secs)
+}
+
+// VerifyOptions HMACVerifier 的可选参数，传 nil 使用默认值。
+type VerifyOptions struct {
+	// MaxSkew 允许的客户端与服务端时间偏差，默认 5 分钟
+	MaxSkew time.Duration
+	// Nonces nonce 存储，默认进程内存储；多实例部署时使用 NewCacheNonceStore
+	Nonces NonceStore
+	// MaxBodySize 校验时读入内存的请求体上限，默认 10 MiB，超出时返回 ErrSignedBodyTooBig
+	MaxBodySize int64
+	// OnError 校验失败时的响应，默认返回 401 与错误信息（请求体超限时返回 413）
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HMACVerifier 返回校验 HMACSigner 签名的 http 中间件。
+//
+// 依次校验：签名头齐全 → 时间戳在 MaxSkew 内 → access key 存在 → 签名正确 → nonce 未使用过。
+// nonce 在签名通过后才记录，保留 2*MaxSkew，超出时间窗口的重放由时间戳校验拒绝。
+//
+// 参数：
+//   - secret: 根据 access key 查找密钥，不存在时返回 false
+//   - opts:   校验选项，可为 nil
+func HMACVerifier(secret func(accessKey string) (string, bool), opts *VerifyOptions) func(http.Handler) http.Handler {
+	o := VerifyOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxSkew <= 0 {
+		o.MaxSkew = 5 * time.Minute
+	}
+	if o.Nonces == nil {
+		o.Nonces = NewMemoryNonceStore()
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = 10 << 20
+	}
+	if o.OnError == nil {
+		o.OnError = func(w http.ResponseWriter, _ *http.Request, err error) {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrSignedBodyTooBig) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := verifyHMAC(r, secret, &o); err != nil {
+				o.OnError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// verifyHMAC 校验单个请求，读取后的 body 会被放回 r.Body
+func verifyHMAC(r *http.Request, secret func(string) (string, bool), o *VerifyOptions) error {
+	ak, ts, nonce, sig := r.Header.Get(HeaderAccessKey), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), r.Header.Get(HeaderSignature)
+	if ak == "" || ts == "" || nonce == "" || sig == "" {
+		return ErrSignatureMissing
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrSignatureExpired
+	}
+	if skew := signNow().Sub(time.Unix(sec, 0)); skew > o.MaxSkew || skew < -o.MaxSkew {
+		return ErrSignatureExpired
+	}
+	sk, ok := secret(ak)
+	if !ok {
+		return ErrUnknownAccessKey
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(io.LimitReader(r.Body, o.MaxBodySize+1)); err != nil {
+			return err
+		}
+		if int64(len(body)) > o.MaxBodySize {
+			return ErrSignedBodyTooBig
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected := hmacSignature(sk, hmacCanonical(r, ak, ts, nonce, sha256Hex(body)))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrSignatureInvalid
+	}
+
+	fresh, err := o.Nonces.Add(ak+":"+nonce, 2*o.MaxSkew)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrNonceReplayed
+	}
+	return nil
+}
+
+// ═══════════════════════════════════════════════════════
+// AWS Signature Version 4
+// ═══════════════════════════════════════════════════════
+
+// AWSCredentials AWS 访问凭证
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // 临时凭证（STS）时填写，写入 X-Amz-Security-Token
+}
+
+// AWSSigV4Signer 返回 AWS Signature Version 4 签名器（Authorization 头方式）。
+// 签名覆盖 host、content-type 与全部 x-amz-* 头；service 为 "s3" 时额外写入
+// X-Amz-Content-Sha256 且路径只编码一次，其余服务按规范对路径二次编码。
+//
+// 参数：
+//   - creds:   访问凭证
+//   - region:  区域，例如 "us-east-1"
+//   - service: 服务名，例如 "s3"、"execute-api"
+func AWSSigV4Signer(creds AWSCredentials, region, service string) func(*http.Request) error {
+	return func(req *http.Request) error {
+		payloadHash, err := signingBodyHash(req)
+		if err != nil {
+			return err
+		}
+		now := signNow().UTC()
+		amzDate := now.Format("20060102T150405Z")
+		date := now.Format("20060102")
+
+		req.Header.Del("Authorization")
+		req.Header.Set("X-Amz-Date", amzDate)
+		if creds.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+		if service == "s3" {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+
+		canonicalHeaders, signedHeaders := awsCanonicalHeaders(req)
+		canonical := strings.Join([]string{
+			req.Method,
+			awsCanonicalPath(req.URL, service != "s3"),
+			awsCanonicalQuery(req.URL.Query()),
+			canonicalHeaders,
+			signedHeaders,
+			payloadHash,
+		}, "\n")
+
+		scope := date + "/" + region + "/" + service + "/aws4_request"
+		stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+		key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+		key = hmacSHA256(key, region)
+		key = hmacSHA256(key, service)
+		key = hmacSHA256(key, "aws4_request")
+		sig := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+		req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			creds.AccessKeyID, scope, signedHeaders, sig))
+		return nil
+	}
+}
+
+// awsCanonicalHeaders 返回规范化头（每行 "name:value\n"）与分号分隔的签名头列表
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			vals := make([]string, len(v))
+			for i, s := range v {
+				vals[i] = strings.Join(strings.Fields(s), " ")
+			}
+			headers[lk] = strings.Join(vals, ",")
+		}
+	}
+	names := Keys(headers)
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, n := range names {
+		sb.WriteString(n + ":" + headers[n] + "\n")
+	}
+	return sb.String(), strings.Join(names, ";")
+}
+
+// awsCanonicalQuery 按 key、value 排序并以 RFC 3986 编码
+func awsCanonicalQuery(q url.Values) string {
+	pairs := make([]string, 0, len(q))
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsCanonicalPath 将路径逐段解码后以 awsURIEncode 编码一次（S3），twice 为 true 时再编码一次（其余服务）；
+// 按转义后的路径分段，段内的 %2F 不会被当作分隔符
+func awsCanonicalPath(u *url.URL, twice bool) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if raw, err := url.PathUnescape(seg); err == nil {
+			seg = raw
+		}
+		seg = awsURIEncode(seg)
+		if twice {
+			seg = awsURIEncode(seg)
+		}
+		segs[i] = seg
+	}
+	return strings.Join(segs, "/")
+}
+
+// awsURIEncode 仅保留 RFC 3986 非保留字符 A-Z a-z 0-9 - _ . ~
+func awsURIEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ═══════════════════════════════════════════════════════
+// 参数排序签名（微信支付 v2 / 国内开放平台风格）
+// ═══════════════════════════════════════════════════════
+
+// SignAlgorithm 参数签名摘要算法
+type SignAlgorithm string
+
+const (
+	SignMD5        SignAlgorithm = "MD5"         // MD5(stringA&key=KEY)
+	SignSHA256     SignAlgorithm = "SHA256"      // SHA256(stringA&key=KEY)
+	SignHMACSHA256 SignAlgorithm = "HMAC-SHA256" // HMAC-SHA256(stringA&key=KEY, KEY)
+)
+
+// SignParams 计算参数签名：去掉空值与 sign 字段后按 key ASCII 排序拼接为 "k1=v1&k2=v2"，
+// 末尾追加 "&key=密钥"，按 algo 取摘要并转大写十六进制。服务端验签可直接调用。
+//
+// 参数：
+//   - params: 参与签名的参数
+//   - key:    商户密钥
+//   - algo:   摘要算法
+//
+// 示例：
+//
+//	SignParams(map[string]string{"appid": "wx123", "nonce_str": "abc"}, "KEY", SignMD5)
+func SignParams(params map[string]string, key string, algo SignAlgorithm) string {
+	filtered := OmitBy(params, func(k, v string) bool { return v == "" || k == "sign" })
+	plain := MapKeySort(filtered) + "&key=" + key
+
+	var h hash.Hash
+	switch algo {
+	case SignSHA256:
+		h = sha256.New()
+	case SignHMACSHA256:
+		h = hmac.New(sha256.New, []byte(key))
+	default:
+		h = md5.New()
+	}
+	h.Write([]byte(plain))
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// ParamsSigner 返回参数排序签名器，签名结果以 "sign" 参数写回请求：
+//   - application/x-www-form-urlencoded 请求体：对表单字段签名并追加 sign 字段
+//   - application/json 请求体：对顶层字段签名（嵌套对象按紧凑 JSON 字符串参与）并写入 sign 字段
+//   - 其余请求：对 query 参数签名并追加 sign 参数
+//
+// 调用方自行提供 nonce_str / timestamp 等业务参数。
+func ParamsSigner(key string, algo SignAlgorithm) func(*http.Request) error {
+	return func(req *http.Request) error {
+		// 只有表单与 JSON 请求体参与签名，其余请求体（如文件上传）不读取
+		mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		var (
+			body []byte
+			err  error
+		)
+		if mt == "application/x-www-form-urlencoded" || mt == "application/json" {
+			if body, err = signingBody(req); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case len(body) > 0 && mt == "application/x-www-form-urlencoded":
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return err
+			}
+			form.Set("sign", SignParams(firstValues(form), key, algo))
+			setSignedBody(req, []byte(form.Encode()))
+
+		case len(body) > 0 && mt == "application/json":
+			var obj map[string]any
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			if err = dec.Decode(&obj); err != nil {
+				return fmt.Errorf("params signer: JSON body must be an object: %w", err)
+			}
+			params := make(map[string]string, len(obj))
+			for k, v := range obj {
+				if s, ok := jsonParamString(v); ok {
+					params[k] = s
+				}
+			}
+			obj["sign"] = SignParams(params, key, algo)
+			signed, err := json.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			setSignedBody(req, signed)
+
+		default:
+			q := req.URL.Query()
+			q.Set("sign", SignParams(firstValues(q), key, algo))
+			req.URL.RawQuery = q.Encode()
+		}
+		return nil
+	}
+}
+
+// firstValues 取每个 key 的第一个值
+func firstValues(v url.Values) map[string]string {
+	m := make(map[string]string, len(v))
+	for k, vs := range v {
+		if len(vs) > 0 {
+			m[k] = vs[0]
+		}
+	}
+	return m
+}
+
+// jsonParamString 将 JSON 顶层字段转为参与签名的字符串，null 不参与
+func jsonParamString(v any) (string, bool) {
+	switch x := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
+
+// ═══════════════════════════════════════════════════════
+// 工具函数
+// ═══════════════════════════════════════════════════════
+
+// newNonce 生成 16 字节随机数的十六进制表示（crypto/rand，并发安全且不可预测）
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// signingBody 通过 GetBody 读取请求体副本，不消费 req.Body
+func signingBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// signingBodyHash 通过 GetBody 流式计算请求体 SHA-256（十六进制），不把请求体读入内存，也不消费 req.Body
+func signingBodyHash(req *http.Request) (string, error) {
+	h := sha256.New()
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		if _, err = io.Copy(h, rc); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setSignedBody 用签名后的内容替换请求体，并同步 GetBody 以便重试重放
+func setSignedBody(req *http.Request, b []byte) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(b))
+}
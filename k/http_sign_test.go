@@ -0,0 +1,194 @@
+package k
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试 AWS SigV4（官方测试套件 get-vanilla）
+func TestAWSSigV4Signer_Vanilla(t *testing.T) {
+	signNow = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	defer func() { signNow = time.Now }()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	sign := AWSSigV4Signer(AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "service")
+	if err := sign(req); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization:\n got  %s\n want %s", got, want)
+	}
+}
+
+// 测试参数排序签名（微信支付 v2 文档示例）
+func TestSignParams(t *testing.T) {
+	params := map[string]string{
+		"appid":       "wxd930ea5d5a258f4f",
+		"mch_id":      "10000100",
+		"device_info": "1000",
+		"body":        "test",
+		"nonce_str":   "ibuaiVcKdpRxkhJA",
+		"empty":       "",
+	}
+	key := "192006250b4c09247ec02edce69f6a2d"
+	if got := SignParams(params, key, SignMD5); got != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+		t.Errorf("MD5 sign = %s", got)
+	}
+	if got := SignParams(params, key, SignHMACSHA256); got != "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6" {
+		t.Errorf("HMAC-SHA256 sign = %s", got)
+	}
+}
+
+// 测试 ParamsSigner 对 JSON / 表单 / query 的签名写回
+func TestParamsSigner(t *testing.T) {
+	var got []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		vals := r.URL.Query()
+		switch r.Header.Get("Content-Type") {
+		case "application/json":
+			var m map[string]any
+			_ = json.Unmarshal(body, &m)
+			vals = url.Values{}
+			for k, v := range m {
+				vals.Set(k, strings.Trim(MapToString(v), `"`))
+			}
+		case "application/x-www-form-urlencoded":
+			vals, _ = url.ParseQuery(string(body))
+		}
+		got = append(got, vals)
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).Sign(ParamsSigner("KEY", SignMD5)).Build()
+	_, _ = client.PostJSON("/json", map[string]any{"amount": 100, "order_no": "A1"})
+	_, _ = client.Post("/form", nil, "", R().FormData(url.Values{"order_no": {"A2"}}))
+	_, _ = client.Get("/query", R().QueryParams(map[string]string{"order_no": "A3"}))
+
+	wants := []map[string]string{
+		{"amount": "100", "order_no": "A1"},
+		{"order_no": "A2"},
+		{"order_no": "A3"},
+	}
+	if len(got) != 3 {
+		t.Fatalf("requests = %d", len(got))
+	}
+	for i, want := range wants {
+		if sign := got[i].Get("sign"); sign != SignParams(want, "KEY", SignMD5) {
+			t.Errorf("request %d: sign = %q, params = %v", i, sign, got[i])
+		}
+	}
+}
+
+// 测试 HMAC 签名、服务端校验、重试刷新 nonce 与重放拒绝
+func TestHMACSigner_Verifier(t *testing.T) {
+	var (
+		calls    atomic.Int32
+		captured http.Header
+	)
+	handler := HMACVerifier(func(ak string) (string, bool) {
+		return "secret", ak == "ak"
+	}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // 首次返回 503 触发重试
+			return
+		}
+		captured = r.Header.Clone()
+		_, _ = w.Write(body)
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).
+		Sign(HMACSigner("ak", "secret")).
+		Retry(WithMaxRetries(2), WithRetryDelay(time.Millisecond)).
+		Build()
+	resp, err := client.PostJSON("/orders?b=2&a=1", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := client.ReadBodyString(resp); resp.StatusCode != 200 || body != `{"id":"1"}` {
+		t.Fatalf("status = %d body = %q", resp.StatusCode, body)
+	}
+
+	// 原样重放同一请求
+	replay, _ := http.NewRequest(http.MethodPost, srv.URL+"/orders?b=2&a=1", strings.NewReader(`{"id":"1"}`))
+	replay.Header = captured
+	resp, err = http.DefaultClient.Do(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(string(body), ErrNonceReplayed.Error()) {
+		t.Errorf("replay: %d %s", resp.StatusCode, body)
+	}
+
+	// 篡改 body
+	captured.Set(HeaderNonce, "other")
+	tampered, _ := http.NewRequest(http.MethodPost, srv.URL+"/orders?b=2&a=1", strings.NewReader(`{"id":"2"}`))
+	tampered.Header = captured
+	resp, err = http.DefaultClient.Do(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), ErrSignatureInvalid.Error()) {
+		t.Errorf("tampered: %d %s", resp.StatusCode, body)
+	}
+}
+
+// 测试 SigV4 规范路径：按段解码后编码，非 S3 服务再编码一次，段内 %2F 不拆分
+func TestAWSCanonicalPath(t *testing.T) {
+	cases := []struct {
+		raw, s3, other string
+	}{
+		{"https://h/", "/", "/"},
+		{"https://h", "/", "/"},
+		{"https://h/a b/c!d", "/a%20b/c%21d", "/a%2520b/c%2521d"},
+		{"https://h/a%2Fb/$x", "/a%2Fb/%24x", "/a%252Fb/%2524x"},
+		{"https://h/%E4%B8%AD", "/%E4%B8%AD", "/%25E4%25B8%25AD"},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.raw)
+		if got := awsCanonicalPath(u, false); got != c.s3 {
+			t.Errorf("s3 %s = %s, want %s", c.raw, got, c.s3)
+		}
+		if got := awsCanonicalPath(u, true); got != c.other {
+			t.Errorf("other %s = %s, want %s", c.raw, got, c.other)
+		}
+	}
+}
+
+// 测试校验端读取请求体有上限
+func TestHMACVerifier_MaxBodySize(t *testing.T) {
+	srv := httptest.NewServer(HMACVerifier(func(string) (string, bool) { return "secret", true }, &VerifyOptions{MaxBodySize: 16})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+	client, _ := NewClient(srv.URL).Sign(HMACSigner("ak", "secret")).Build()
+
+	for body, want := range map[string]int{"small": http.StatusOK, strings.Repeat("x", 17): http.StatusRequestEntityTooLarge} {
+		resp, err := client.Post("/", strings.NewReader(body), "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("body %d bytes: status %d, want %d", len(body), resp.StatusCode, want)
+		}
+	}
+}
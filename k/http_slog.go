@@ -0,0 +1,363 @@
+package k
+
+// http_slog.go —— 基于 log/slog 的结构化请求日志，支持请求 / 响应体捕获与敏感信息脱敏
+//
+// 设计目标：
+//   - 每个请求一条结构化日志：method、url、status、duration、attempts、error
+//   - 每次尝试单独记录：失败的尝试（网络错误、5xx）为 Warn，成功的尝试为 Debug
+//   - 可选记录请求 / 响应头与请求 / 响应体，体按 MaxBodySize 截断，不影响调用方读取
+//   - 脱敏：请求头、query 参数、JSON / 表单字段按名称（不区分大小写）替换为 [REDACTED]
+//
+// 示例：
+//
+//	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+//	client, _ := NewClient("https://api.example.com").
+//	    Slog(logger, &LogOptions{Headers: true, Bodies: true, RedactFields: append(DefaultRedactFields, "bank_account")}).
+//	    Build()
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// redactedValue 脱敏后的占位值
+const redactedValue = "[REDACTED]"
+
+// bodyOmitted 无法可靠脱敏时代替请求 / 响应体记录的内容
+const bodyOmitted = "[body omitted: redaction failed]"
+
+var (
+	// DefaultRedactHeaders 默认脱敏的请求 / 响应头
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token", HeaderSignature}
+	// DefaultRedactQuery 默认脱敏的 query 参数
+	DefaultRedactQuery = []string{"token", "access_token", "refresh_token", "api_key", "apikey", "key", "secret", "password", "sign", "signature"}
+	// DefaultRedactFields 默认脱敏的 JSON / 表单字段
+	DefaultRedactFields = []string{"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token", "id_card", "idcard", "id_card_no", "id_number", "bank_card", "card_no"}
+)
+
+// LogOptions Slog 的可选参数，传 nil 使用默认值。
+type LogOptions struct {
+	// Headers 为 true 时记录请求头与响应头
+	Headers bool
+	// Bodies 为 true 时记录请求体与响应体（流式响应与 multipart 请求体除外）
+	Bodies bool
+	// MaxBodySize 记录的请求 / 响应体最大字节数，超出部分截断，默认 4096
+	MaxBodySize int
+	// RedactHeaders 脱敏的头名称，为 nil 时使用 DefaultRedactHeaders
+	RedactHeaders []string
+	// RedactQuery 脱敏的 query 参数名，为 nil 时使用 DefaultRedactQuery
+	RedactQuery []string
+	// RedactFields 脱敏的 JSON / 表单字段名，为 nil 时使用 DefaultRedactFields
+	RedactFields []string
+}
+
+// requestLogger 编译后的日志配置
+type requestLogger struct {
+	logger  *slog.Logger
+	opts    LogOptions
+	headers map[string]bool // 规范化的头名称
+	query   map[string]bool // 小写
+	fields  map[string]bool // 小写
+	jsonRe  *regexp.Regexp
+	// nestedRe 敏感字段的值为对象 / 数组，正则无法替换
+	nestedRe *regexp.Regexp
+}
+
+// Slog 设置结构化日志，与 Logger 可同时使用。
+//
+// 参数：
+//   - l:    slog.Logger，为 nil 时关闭
+//   - opts: 日志选项，可为 nil
+func (b *ClientBuilder) Slog(l *slog.Logger, opts *LogOptions) *ClientBuilder {
+	if l == nil {
+		b.slog = nil
+		return b
+	}
+	b.slog = newRequestLogger(l, opts)
+	return b
+}
+
+func newRequestLogger(l *slog.Logger, opts *LogOptions) *requestLogger {
+	o := LogOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = 4096
+	}
+	if o.RedactHeaders == nil {
+		o.RedactHeaders = DefaultRedactHeaders
+	}
+	if o.RedactQuery == nil {
+		o.RedactQuery = DefaultRedactQuery
+	}
+	if o.RedactFields == nil {
+		o.RedactFields = DefaultRedactFields
+	}
+	rl := &requestLogger{logger: l, opts: o, headers: map[string]bool{}, query: map[string]bool{}, fields: map[string]bool{}}
+	for _, h := range o.RedactHeaders {
+		rl.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, q := range o.RedactQuery {
+		rl.query[strings.ToLower(q)] = true
+	}
+	names := make([]string, 0, len(o.RedactFields))
+	for _, f := range o.RedactFields {
+		rl.fields[strings.ToLower(f)] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+	if len(names) > 0 {
+		// 匹配 "field": 值（字符串、数字、布尔、null），字符串值在截断处可能缺少结尾引号；
+		// 只用于截断或非法的 JSON，完整 JSON 解析后逐层脱敏
+		rl.jsonRe = regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|-?[0-9][0-9.eE+-]*|true|false|null)`)
+		rl.nestedRe = regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"\s*:\s*[\[{]`)
+	}
+	return rl
+}
+
+// logRequest 记录一个请求（含全部重试）的结果。
+// 开启 Bodies 时预读响应体的前 MaxBodySize 字节并重新装填，调用方仍可完整读取；压缩的响应体经 decode 解压后再脱敏。
+func (l *requestLogger) logRequest(req *http.Request, stream bool, resp *http.Response, err error, elapsed time.Duration, attempts int, decode func(*http.Response) (io.ReadCloser, error)) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", l.redactURL(req.URL)),
+		slog.Duration("duration", elapsed),
+		slog.Int("attempts", attempts),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			level = slog.LevelError
+		} else if resp.StatusCode >= 400 {
+			level = slog.LevelWarn
+		}
+	}
+	if l.opts.Headers {
+		attrs = append(attrs, l.headerGroup("request_headers", req.Header))
+		if resp != nil {
+			attrs = append(attrs, l.headerGroup("response_headers", resp.Header))
+		}
+	}
+	if l.opts.Bodies {
+		if body, ok := l.requestBody(req); ok {
+			attrs = append(attrs, slog.String("request_body", body))
+		}
+		if resp != nil && !stream {
+			attrs = append(attrs, slog.String("response_body", l.responseBody(resp, decode)))
+		}
+	}
+	l.logger.LogAttrs(req.Context(), level, "http request", attrs...)
+}
+
+// logAttempt 记录单次尝试，失败的尝试为 Warn，其余为 Debug
+func (l *requestLogger) logAttempt(req *http.Request, attempt int, resp *http.Response, err error, elapsed time.Duration) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", l.redactURL(req.URL)),
+		slog.Int("attempt", attempt),
+		slog.Duration("duration", elapsed),
+	}
+	level, msg := slog.LevelDebug, "http attempt"
+	if err != nil {
+		level, msg = slog.LevelWarn, "http attempt failed"
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			level, msg = slog.LevelWarn, "http attempt failed"
+		}
+	}
+	l.logger.LogAttrs(req.Context(), level, msg, attrs...)
+}
+
+// redactURL 返回脱敏后的 URL：敏感 query 参数与 userinfo 中的密码替换为占位值
+func (l *requestLogger) redactURL(u *url.URL) string {
+	return redactURL(u, l.query)
+}
+
+// redactURL 供 printf 风格的 Logger 共用，names 为小写参数名
+func redactURL(u *url.URL, names map[string]bool) string {
+	cp := *u
+	if cp.RawQuery != "" {
+		q := cp.Query()
+		changed := false
+		for k, vs := range q {
+			if names[strings.ToLower(k)] {
+				for i := range vs {
+					vs[i] = redactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			cp.RawQuery = q.Encode()
+		}
+	}
+	return cp.Redacted()
+}
+
+// defaultRedactQuery DefaultRedactQuery 的小写集合，printf 风格的 Logger 使用
+var defaultRedactQuery = func() map[string]bool {
+	m := make(map[string]bool, len(DefaultRedactQuery))
+	for _, q := range DefaultRedactQuery {
+		m[q] = true
+	}
+	return m
+}()
+
+// headerGroup 将 header 转为 slog 分组，敏感头替换为占位值
+func (l *requestLogger) headerGroup(name string, h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for k, vs := range h {
+		v := strings.Join(vs, ", ")
+		if l.headers[http.CanonicalHeaderKey(k)] {
+			v = redactedValue
+		}
+		attrs = append(attrs, slog.String(k, v))
+	}
+	return slog.Group(name, attrs...)
+}
+
+// requestBody 通过 GetBody 读取请求体的前 MaxBodySize 字节，multipart 不读取（可能是大文件）
+func (l *requestLogger) requestBody(req *http.Request) (string, bool) {
+	if req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return "", false
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		return "[multipart omitted]", true
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer rc.Close()
+	b, _ := io.ReadAll(io.LimitReader(rc, int64(l.opts.MaxBodySize)+1))
+	return l.formatBody(b, req.Header.Get("Content-Type")), true
+}
+
+// responseBody 预读响应体的前 MaxBodySize 字节，并把已读的原始字节拼回 resp.Body。
+// 按 Content-Encoding 解压后再脱敏，否则压缩字节中找不到敏感字段；无法解压时不记录响应体。
+func (l *requestLogger) responseBody(resp *http.Response, decode func(*http.Response) (io.ReadCloser, error)) string {
+	var raw bytes.Buffer
+	orig := resp.Body
+	tee := *resp
+	tee.Body = io.NopCloser(io.TeeReader(orig, &raw))
+	rc, err := decode(&tee)
+	var b []byte
+	if err == nil {
+		b, err = io.ReadAll(io.LimitReader(rc, int64(l.opts.MaxBodySize)+1))
+		rc.Close()
+	}
+	resp.Body = &prefixedBody{Reader: io.MultiReader(&raw, orig), Closer: orig}
+	if err != nil {
+		return bodyOmitted
+	}
+	return l.formatBody(b, resp.Header.Get("Content-Type"))
+}
+
+// formatBody 脱敏并截断，超出 MaxBodySize 时追加截断标记；无法确定已脱敏时不记录请求体
+func (l *requestLogger) formatBody(b []byte, contentType string) string {
+	truncated := len(b) > l.opts.MaxBodySize
+	if truncated {
+		b = b[:l.opts.MaxBodySize]
+	}
+	s, ok := string(b), true
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == "application/x-www-form-urlencoded" {
+		s, ok = l.redactForm(s)
+	} else if l.jsonRe != nil {
+		s, ok = l.redactJSON(b)
+	}
+	if !ok {
+		return bodyOmitted
+	}
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// redactForm 按 & / ; 逐对脱敏，不做严格解析（非法转义、截断的请求体同样处理），其余部分原样保留。
+// 参数名无法解码时无法判断是否敏感，返回 false。
+func (l *requestLogger) redactForm(s string) (string, bool) {
+	var sb strings.Builder
+	for s != "" {
+		pair, sep := s, ""
+		if i := strings.IndexAny(s, "&;"); i >= 0 {
+			pair, sep, s = s[:i], s[i:i+1], s[i+1:]
+		} else {
+			s = ""
+		}
+		name, _, hasValue := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(name)
+		if err != nil {
+			return "", false
+		}
+		if lk := strings.ToLower(key); hasValue && (l.fields[lk] || l.query[lk]) {
+			pair = name + "=" + redactedValue
+		}
+		sb.WriteString(pair)
+		sb.WriteString(sep)
+	}
+	return sb.String(), true
+}
+
+// redactJSON 完整的 JSON 解析后逐层脱敏（字段值为对象、数组时整体替换）；
+// 截断或非法的 JSON 用正则替换标量值，敏感字段的值为对象 / 数组时无法可靠替换，返回 false。
+func (l *requestLogger) redactJSON(b []byte) (string, bool) {
+	if json.Valid(b) {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err == nil {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(l.redactValue(v)); err == nil {
+				return strings.TrimSuffix(buf.String(), "\n"), true
+			}
+		}
+	}
+	s := l.jsonRe.ReplaceAllString(string(b), `"$1"$2"`+redactedValue+`"`)
+	if l.nestedRe.MatchString(s) {
+		return "", false
+	}
+	return s, true
+}
+
+// redactValue 递归替换敏感字段的值
+func (l *requestLogger) redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, fv := range t {
+			if l.fields[strings.ToLower(k)] {
+				t[k] = redactedValue
+			} else {
+				t[k] = l.redactValue(fv)
+			}
+		}
+	case []any:
+		for i := range t {
+			t[i] = l.redactValue(t[i])
+		}
+	}
+	return v
+}
+
+// prefixedBody 拼接预读部分与剩余响应体，Close 关闭原始响应体
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
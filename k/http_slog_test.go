@@ -0,0 +1,208 @@
+package k
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// logRecords 解析 JSONHandler 输出的每一行
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// 测试结构化字段、头与体的捕获和脱敏
+func TestSlog_RequestFieldsAndRedaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "sid=abc")
+		io.WriteString(w, `{"id":1,"access_token":"tok-123","profile":{"id_card":"110101199003070000"}}`)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client, _ := NewClient(srv.URL).
+		BearerToken(func() string { return "secret-bearer" }).
+		Slog(logger, &LogOptions{Headers: true, Bodies: true}).
+		Build()
+
+	resp, err := client.PostJSON("/login?token=qs-secret&page=2", map[string]any{"user": "bob", "password": "hunter2", "age": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "tok-123") {
+		t.Fatalf("caller should receive the full body, got %q", body)
+	}
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("records = %d", len(recs))
+	}
+	rec := recs[0]
+	if rec["msg"] != "http request" || rec["method"] != "POST" || rec["status"] != float64(200) || rec["attempts"] != float64(1) {
+		t.Errorf("record = %v", rec)
+	}
+	line := buf.String()
+	for _, secret := range []string{"qs-secret", "hunter2", "secret-bearer", "tok-123", "110101199003070000", "sid=abc"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log leaks %q: %s", secret, line)
+		}
+	}
+	for _, want := range []string{"page=2", `\"user\":\"bob\"`, `\"age\":3`, `\"id\":1`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log missing %q: %s", want, line)
+		}
+	}
+	if h := rec["request_headers"].(map[string]any); h["Authorization"] != redactedValue {
+		t.Errorf("request_headers = %v", h)
+	}
+}
+
+// 测试体截断与表单脱敏
+func TestSlog_TruncateAndForm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	client, _ := NewClient(srv.URL).Slog(slog.New(slog.NewJSONHandler(&buf, nil)), &LogOptions{Bodies: true, MaxBodySize: 10}).Build()
+	resp, err := client.Post("/", nil, "", R().FormData(url.Values{"username": {"bob"}, "password": {"hunter2"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 100 {
+		t.Fatalf("body len = %d", len(body))
+	}
+	rec := logRecords(t, &buf)[0]
+	if rec["response_body"] != "xxxxxxxxxx...(truncated)" {
+		t.Errorf("response_body = %v", rec["response_body"])
+	}
+	if rb := fmt.Sprint(rec["request_body"]); strings.Contains(rb, "hunter2") || !strings.HasPrefix(rb, "password=") {
+		t.Errorf("request_body = %v", rb)
+	}
+}
+
+// 测试压缩的响应体解压后再脱敏，调用方仍可读取完整的原始响应
+func TestSlog_CompressedResponseBody(t *testing.T) {
+	const payload = `{"user":"bob","password":"hunter2","id_card":"110101199003070000"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, payload)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	client, _ := NewClient(srv.URL).Compression().Slog(slog.New(slog.NewJSONHandler(&buf, nil)), &LogOptions{Bodies: true}).Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := client.ReadBodyString(resp); err != nil || body != payload {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	rec := logRecords(t, &buf)[0]
+	if got := rec["response_body"]; got != `{"id_card":"[REDACTED]","password":"[REDACTED]","user":"bob"}` {
+		t.Errorf("response_body = %v", got)
+	}
+}
+
+// 测试宽松的表单脱敏、嵌套 JSON 脱敏，以及无法可靠脱敏时不记录请求体
+func TestSlog_RedactBodyEdgeCases(t *testing.T) {
+	l := newRequestLogger(slog.Default(), &LogOptions{Bodies: true, MaxBodySize: 80})
+	const form = "application/x-www-form-urlencoded"
+	cases := []struct {
+		body, contentType, want string
+	}{
+		{"password=hunter2&note=100%", form, "password=[REDACTED]&note=100%"},
+		{"a=1;Password=hunter2", form, "a=1;Password=[REDACTED]"},
+		{"user=bob&password=hun%2", form, "user=bob&password=[REDACTED]"},
+		{"user=bob&pass%7word=hunter2", form, bodyOmitted},
+		{`{"password":{"v":"x"},"token":["abc"],"user":{"secret":1,"name":"bob"}}`, "application/json",
+			`{"password":"[REDACTED]","token":"[REDACTED]","user":{"name":"bob","secret":"[REDACTED]"}}`},
+		{`{"user":"bob","password":"hun`, "application/json", `{"user":"bob","password":"[REDACTED]"`},
+		{`{"user":"bob","token":["ab`, "application/json", bodyOmitted},
+		{`{"password": {"v": "x"}, "pad": "` + strings.Repeat("x", 80) + `"}`, "application/json", bodyOmitted},
+	}
+	for _, c := range cases {
+		if got := l.formatBody([]byte(c.body), c.contentType); got != c.want {
+			t.Errorf("formatBody(%q)\n got %q\nwant %q", c.body, got, c.want)
+		}
+	}
+}
+
+// 测试逐次尝试日志
+func TestSlog_Attempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, _ := NewClient(srv.URL).Slog(logger, nil).Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var msgs []string
+	for _, rec := range logRecords(t, &buf) {
+		msgs = append(msgs, fmt.Sprintf("%v/%v", rec["msg"], rec["attempt"]))
+	}
+	want := "http attempt failed/1 http attempt failed/2 http attempt/3 http request/<nil>"
+	if got := strings.Join(msgs, " "); got != want {
+		t.Errorf("records = %s", got)
+	}
+}
+
+// 测试 printf 风格 Logger 对 query 脱敏
+func TestLogger_RedactsQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var line string
+	client, _ := NewClient(srv.URL).Logger(func(format string, args ...any) { line = fmt.Sprintf(format, args...) }).Build()
+	resp, err := client.Get("/cb?access_token=abc&state=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if strings.Contains(line, "abc") || !strings.Contains(line, "state=1") {
+		t.Errorf("log line = %q", line)
+	}
+}
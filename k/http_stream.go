@@ -0,0 +1,324 @@
+package k
+
+// http_stream.go —— 流式响应消费：Server-Sent Events 与 NDJSON
+//
+// 设计目标：
+//   - 以 Go 1.23 iter.Seq2 逐条产出事件 / 对象，调用方 break 时自动关闭连接
+//   - 连接建立走完整的 do → execute 流水线（鉴权、签名、限速、熔断、重试、日志、指标）
+//   - 不受客户端 Timeout 限制，由请求 context 控制生命周期
+//   - SSE 断线后按服务端 retry 字段等待并携带 Last-Event-ID 自动重连，重连失败时指数退避后再试
+//   - 请求 Accept-Encoding: identity，启用 Compression 时事件流也不会被压缩
+//
+// 示例：
+//
+//	for ev, err := range client.StreamSSE("/v1/chat", &StreamOptions{Method: http.MethodPost, Body: req}) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Print(ev.Data)
+//	}
+//
+//	for line, err := range StreamNDJSON[LogLine](client, "/logs/tail", nil) {
+//	    ...
+//	}
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent 一条 Server-Sent Event
+type SSEEvent struct {
+	ID    string        // 最近一次 id 字段（跨事件保留），断线重连时作为 Last-Event-ID 发送
+	Event string        // event 字段，未指定时为 "message"
+	Data  string        // data 字段，多行 data 以 "\n" 连接
+	Retry time.Duration // retry 字段，服务端建议的重连间隔，未指定时为 0
+}
+
+// StreamOptions 流式请求的可选参数，传 nil 使用默认值。
+type StreamOptions struct {
+	// Method HTTP 方法，默认 GET
+	Method string
+	// Body 非 nil 时序列化为 JSON 作为请求体（如大模型网关的 POST 流式接口）
+	Body any
+	// Request 附加的请求级配置（header、query、context 等），不需要时为 nil
+	Request *RequestBuilder
+	// MaxReconnects SSE 连续断线重连的最大次数（含连接失败的重连），默认 3，收到事件后计数清零；设为负值禁用重连
+	MaxReconnects int
+	// ReconnectDelay SSE 重连前的等待时间，默认 3s，服务端 retry 字段会覆盖此值
+	ReconnectDelay time.Duration
+}
+
+// StreamSSE 以 text/event-stream 方式消费服务端推送事件。
+//
+// 连接正常结束或中途断开时，会等待重连间隔后携带 Last-Event-ID 重新连接，
+// 直到超过 MaxReconnects、服务端返回 204、context 取消或调用方停止迭代。
+// 重连时的网络错误或 5xx 计入 MaxReconnects，等待间隔逐次翻倍（最多 1 分钟）；
+// 首次连接失败与非 2xx 响应（*HTTPError）直接产出错误并结束迭代。
+//
+// 参数：
+//   - path: 请求路径
+//   - opts: 流式请求选项，可为 nil
+func (c *HTTPClient) StreamSSE(path string, opts *StreamOptions) iter.Seq2[*SSEEvent, error] {
+	return func(yield func(*SSEEvent, error) bool) {
+		if opts == nil {
+			opts = &StreamOptions{}
+		}
+		maxReconnects := opts.MaxReconnects
+		if maxReconnects == 0 {
+			maxReconnects = 3
+		}
+		delay := opts.ReconnectDelay
+		if delay <= 0 {
+			delay = 3 * time.Second
+		}
+
+		lastID, connected := "", false
+		for reconnects := 0; ; reconnects++ {
+			headers := map[string]string{"Accept": "text/event-stream", "Cache-Control": "no-cache"}
+			if lastID != "" {
+				headers["Last-Event-ID"] = lastID
+			}
+			resp, ctx, err := c.openStream(path, opts, headers)
+			if err != nil {
+				var httpErr *HTTPError
+				if !connected || errors.As(err, &httpErr) || ctx.Err() != nil || maxReconnects < 0 || reconnects >= maxReconnects {
+					yield(nil, err)
+					return
+				}
+				// 重连失败：退避后再试
+				backoff := delay
+				for i := 0; i < reconnects && backoff < time.Minute; i++ {
+					backoff *= 2
+				}
+				select {
+				case <-ctx.Done():
+					yield(nil, ctx.Err())
+					return
+				case <-time.After(min(backoff, time.Minute)):
+				}
+				continue
+			}
+			connected = true
+			if resp.StatusCode == http.StatusNoContent {
+				resp.Body.Close()
+				return // 服务端要求停止重连
+			}
+			if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "text/event-stream" {
+				resp.Body.Close()
+				yield(nil, fmt.Errorf("sse: unexpected Content-Type %q", resp.Header.Get("Content-Type")))
+				return
+			}
+
+			reader := &sseReader{r: bufio.NewReader(resp.Body), lastID: lastID}
+			var readErr error
+			for {
+				ev, err := reader.next()
+				if err != nil {
+					readErr = err
+					break
+				}
+				reconnects = 0
+				if !yield(ev, nil) {
+					resp.Body.Close()
+					return
+				}
+			}
+			resp.Body.Close()
+			lastID = reader.lastID
+			if reader.retry > 0 {
+				delay = reader.retry
+			}
+
+			if ctx.Err() != nil {
+				yield(nil, ctx.Err())
+				return
+			}
+			if maxReconnects < 0 || reconnects >= maxReconnects {
+				if !errors.Is(readErr, io.EOF) {
+					yield(nil, readErr)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// StreamNDJSON 以换行分隔 JSON（application/x-ndjson、JSON Lines）方式逐条解码响应体。
+// 泛型函数无法作为方法，因此以客户端作为第一个参数。
+//
+// 参数：
+//   - c:    HTTPClient
+//   - path: 请求路径
+//   - opts: 流式请求选项，可为 nil；MaxReconnects / ReconnectDelay 对 NDJSON 不生效
+//
+// 示例：
+//
+//	for item, err := range StreamNDJSON[Order](client, "/orders/export", nil) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    handle(item)
+//	}
+func StreamNDJSON[T any](c *HTTPClient, path string, opts *StreamOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if opts == nil {
+			opts = &StreamOptions{}
+		}
+		resp, _, err := c.openStream(path, opts, map[string]string{"Accept": "application/x-ndjson"})
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var item T
+			if err = dec.Decode(&item); err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(zero, err)
+				}
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// openStream 以流式模式发送请求，非 2xx 响应读取 body 后返回 *HTTPError
+func (c *HTTPClient) openStream(path string, opts *StreamOptions, headers map[string]string) (*http.Response, context.Context, error) {
+	r := opts.Request.clone()
+	for k, v := range headers {
+		if _, ok := r.cfg.headers[k]; !ok {
+			r.cfg.headers[k] = v
+		}
+	}
+	// 流按行增量解析，不接受压缩编码（与 Download 一致）
+	r.cfg.headers["Accept-Encoding"] = "identity"
+	r.cfg.stream = true
+	r.cfg.expectStatus = nil
+	ctx := r.cfg.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if opts.Body != nil {
+		b, err := json.Marshal(opts.Body)
+		if err != nil {
+			return nil, ctx, err
+		}
+		body, contentType = bytes.NewReader(b), "application/json"
+	}
+
+	resp, err := c.do(method, path, body, contentType, r)
+	if err != nil {
+		return nil, ctx, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, ctx, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: respBody}
+	}
+	return resp, ctx, nil
+}
+
+// sseReader 按 WHATWG EventSource 规范解析事件流
+type sseReader struct {
+	r      *bufio.Reader
+	lastID string        // 最近一次 id 字段，跨事件保留
+	retry  time.Duration // 最近一次 retry 字段
+}
+
+// next 读取下一条事件，流结束时返回 io.EOF（未以空行结尾的残余事件按规范丢弃）
+func (s *sseReader) next() (*SSEEvent, error) {
+	var (
+		ev      SSEEvent
+		data    strings.Builder
+		hasData bool
+	)
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && line == "" {
+				return nil, io.EOF
+			}
+			if !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if err != nil {
+				return nil, io.EOF
+			}
+			if !hasData {
+				ev = SSEEvent{} // 没有 data 的事件不派发
+				continue
+			}
+			ev.ID = s.lastID
+			ev.Data = data.String()
+			if ev.Event == "" {
+				ev.Event = "message"
+			}
+			return &ev, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // 注释 / 心跳
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastID = value
+			}
+		case "retry":
+			if ms, convErr := strconv.ParseInt(value, 10, 64); convErr == nil {
+				ev.Retry = time.Duration(ms) * time.Millisecond
+				s.retry = ev.Retry
+			}
+		}
+		if err != nil {
+			return nil, io.EOF
+		}
+	}
+}
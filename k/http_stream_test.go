@@ -0,0 +1,132 @@
+package k
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试 SSE 解析与携带 Last-Event-ID 的自动重连
+func TestClient_StreamSSE(t *testing.T) {
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		if r.Header.Get("Last-Event-ID") == "" {
+			fmt.Fprint(w, ": heartbeat\n\nretry: 10\nid: 1\nevent: delta\ndata: hello\ndata: world\n\n")
+			return // 服务端断开，客户端应重连
+		}
+		if r.Header.Get("Last-Event-ID") == "1" {
+			fmt.Fprint(w, "id: 2\r\ndata: done\r\n\r\n")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Build()
+	var events []*SSEEvent
+	for ev, err := range client.StreamSSE("/events", &StreamOptions{ReconnectDelay: time.Millisecond}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if e := events[0]; e.ID != "1" || e.Event != "delta" || e.Data != "hello\nworld" || e.Retry != 10*time.Millisecond {
+		t.Errorf("unexpected first event: %+v", e)
+	}
+	if e := events[1]; e.ID != "2" || e.Event != "message" || e.Data != "done" {
+		t.Errorf("unexpected second event: %+v", e)
+	}
+	if fmt.Sprint(lastEventIDs) != "[ 1 2]" {
+		t.Errorf("unexpected Last-Event-ID sequence: %q", lastEventIDs)
+	}
+}
+
+// 测试 NDJSON 逐行解码与提前终止迭代
+func TestStreamNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/x-ndjson" {
+			t.Errorf("unexpected Accept %q", r.Header.Get("Accept"))
+		}
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "{\"n\":%d}\n", i)
+		}
+	}))
+	defer server.Close()
+
+	type item struct {
+		N int `json:"n"`
+	}
+	client, _ := NewClient(server.URL).Build()
+	sum := 0
+	for it, err := range StreamNDJSON[item](client, "/", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += it.N
+		if it.N == 3 {
+			break
+		}
+	}
+	if sum != 6 {
+		t.Errorf("sum=%d", sum)
+	}
+}
+
+// 测试重连失败计入 MaxReconnects 并继续重试，以及启用压缩时事件流仍可解析
+func TestClient_StreamSSEReconnectFailure(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Last-Event-ID"))
+		if len(requests) == 2 || len(requests) == 3 {
+			w.WriteHeader(http.StatusServiceUnavailable) // 重连时服务端暂不可用
+			return
+		}
+		if len(requests) > 4 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		var out io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+		fmt.Fprintf(out, "id: %d\ndata: event %d\n\n", len(requests), len(requests))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).Compression().Build()
+	var data []string
+	for ev, err := range client.StreamSSE("/events", &StreamOptions{ReconnectDelay: time.Millisecond}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, ev.Data)
+	}
+	if fmt.Sprint(data) != "[event 1 event 4]" || fmt.Sprint(requests) != "[ 1 1 1 4]" {
+		t.Errorf("events = %q, Last-Event-ID sequence = %q", data, requests)
+	}
+
+	// 超过 MaxReconnects 后产出最后的错误
+	requests = nil
+	var lastErr error
+	for _, err := range client.StreamSSE("/events", &StreamOptions{ReconnectDelay: time.Millisecond, MaxReconnects: 1}) {
+		lastErr = err
+	}
+	if lastErr == nil || len(requests) != 2 {
+		t.Errorf("err = %v, requests = %d", lastErr, len(requests))
+	}
+}
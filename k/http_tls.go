@@ -0,0 +1,421 @@
+package k
+
+// http_tls.go —— TLS 辅助：mTLS 证书加载、CA 证书包、SPKI 公钥固定、证书热加载、TLS 错误分类
+//
+// 设计目标：
+//   - 客户端证书 / 私钥与 CA 证书包可来自 PEM 文件或字节，无需手工拼装 tls.Config
+//   - 文件来源的证书按 ReloadInterval 检查修改时间，变化后在下一次握手时生效，证书轮换无需重启
+//   - PinnedSPKI 固定证书链中任一证书的公钥 SHA-256（与 HPKP / curl --pinnedpubkey 格式一致）
+//   - 可配置最低 / 最高 TLS 版本与 TLS 1.2 的密码套件
+//   - 握手、证书校验、公钥固定失败统一包装为 *TLSError；证书校验失败不重试，指标 code 记为 "tls_error"
+//
+// 示例：
+//
+//	client, err := NewClient("https://payment.internal").
+//		TLSOptions(&TLSOptions{
+//			CertFile:   "/etc/certs/client.crt",
+//			KeyFile:    "/etc/certs/client.key",
+//			CAFile:     "/etc/certs/ca.pem",
+//			PinnedSPKI: []string{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+//			MinVersion: tls.VersionTLS13,
+//		}).
+//		Build()
+//
+//	var tlsErr *TLSError
+//	if errors.As(err, &tlsErr) && tlsErr.Verification { ... }
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPinMismatch 证书链中没有与 PinnedSPKI 匹配的公钥
+var ErrPinMismatch = errors.New("tls: no certificate in chain matches pinned public keys")
+
+// defaultCertReloadInterval 文件来源证书的默认检查间隔
+const defaultCertReloadInterval = time.Minute
+
+// TLSOptions TLS 配置选项，用于 ClientBuilder.TLSOptions 或 NewTLSConfig。
+type TLSOptions struct {
+	// CertFile / KeyFile 客户端证书与私钥 PEM 文件（mTLS），文件变化后自动重新加载
+	CertFile string
+	KeyFile  string
+	// CertPEM / KeyPEM 客户端证书与私钥 PEM 内容，与 CertFile / KeyFile 二选一
+	CertPEM []byte
+	KeyPEM  []byte
+
+	// CAFile 信任的 CA 证书包 PEM 文件，文件变化后自动重新加载（新证书池对之后建立的连接生效）；设置后默认只信任这些 CA
+	CAFile string
+	// CAPEM 信任的 CA 证书包 PEM 内容，与 CAFile 二选一
+	CAPEM []byte
+	// SystemRoots 为 true 时在系统根证书的基础上追加 CA，而不是替换
+	SystemRoots bool
+
+	// PinnedSPKI 固定的公钥哈希：证书 SubjectPublicKeyInfo 的 SHA-256 的 base64，可带 "sha256/" 前缀。
+	// 已校验证书链中任一证书匹配即通过（关闭校验时只比对叶子证书）；为空表示不固定。
+	PinnedSPKI []string
+
+	// ServerName 校验证书时使用的主机名，默认取请求 URL 的主机名
+	ServerName string
+	// MinVersion 最低 TLS 版本，默认 tls.VersionTLS12
+	MinVersion uint16
+	// MaxVersion 最高 TLS 版本，0 表示不限制
+	MaxVersion uint16
+	// CipherSuites TLS 1.2 可用的密码套件（TLS 1.3 的套件不可配置），为空时使用 Go 的默认列表
+	CipherSuites []uint16
+
+	// ReloadInterval 检查证书文件变化的最小间隔，默认 1 分钟，负值表示不重新加载
+	ReloadInterval time.Duration
+	// OnReloadError 重新加载失败时回调，此时继续使用旧证书
+	OnReloadError func(error)
+}
+
+// TLSError 握手、证书校验或公钥固定失败时返回，可用 errors.As 识别，Unwrap 返回底层错误。
+type TLSError struct {
+	Host         string // 请求的 host:port
+	Verification bool   // true 表示证书校验或公钥固定失败（重试无意义），false 表示握手 / 协议错误
+	Err          error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("tls error (%s): %v", e.Host, e.Err)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// TLSOptions 使用 TLSOptions 配置 TLS，在 TLS(cfg) 设置的配置（如有）之上生效。
+// 证书加载失败等错误在 Build 时返回。
+func (b *ClientBuilder) TLSOptions(opts *TLSOptions) *ClientBuilder {
+	b.tlsOptions = opts
+	return b
+}
+
+// NewTLSConfig 按 TLSOptions 创建 *tls.Config，可用于其他 http.Transport 或 gRPC 等客户端。
+// 客户端证书文件仍会热加载；CAFile 只在创建时读取一次，CA 热加载仅在 ClientBuilder.TLSOptions 中生效。
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	cfg, _, err := opts.build(nil)
+	return cfg, err
+}
+
+// build 在 base 的副本上应用选项，base 为 nil 时从空配置开始。
+// 设置了 CAFile 且需要热加载时同时返回 certReloader，由调用方用 caReloadTransport 包装 Transport。
+func (o *TLSOptions) build(base *tls.Config) (*tls.Config, *certReloader, error) {
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if o == nil {
+		return cfg, nil, nil
+	}
+	if o.ServerName != "" {
+		cfg.ServerName = o.ServerName
+	}
+	if o.MinVersion != 0 {
+		cfg.MinVersion = o.MinVersion
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if o.MaxVersion != 0 {
+		cfg.MaxVersion = o.MaxVersion
+	}
+	if len(o.CipherSuites) > 0 {
+		for _, id := range o.CipherSuites {
+			if !knownCipherSuite(id) {
+				return nil, nil, fmt.Errorf("tls: unknown cipher suite 0x%04x", id)
+			}
+		}
+		cfg.CipherSuites = slices.Clone(o.CipherSuites)
+	}
+
+	pins, err := parsePins(o.PinnedSPKI)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := &certReloader{opts: o, interval: o.ReloadInterval}
+	if r.interval == 0 {
+		r.interval = defaultCertReloadInterval
+	}
+
+	// 客户端证书
+	switch {
+	case o.CertFile != "" || o.KeyFile != "":
+		if err := r.loadCert(); err != nil {
+			return nil, nil, err
+		}
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.maybeReload()
+			return r.certificate(), nil
+		}
+	case len(o.CertPEM) > 0 || len(o.KeyPEM) > 0:
+		cert, err := tls.X509KeyPair(o.CertPEM, o.KeyPEM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	// CA：由 Go 按请求主机名完成标准校验；文件来源的证书池变化后由 caReloadTransport 换用新的 Transport
+	var caReloader *certReloader
+	switch {
+	case o.CAFile != "":
+		if err := r.loadCA(); err != nil {
+			return nil, nil, err
+		}
+		cfg.RootCAs = r.roots()
+		if r.interval >= 0 && !cfg.InsecureSkipVerify {
+			caReloader = r
+		}
+	case len(o.CAPEM) > 0:
+		pool, err := caPool(o.CAPEM, o.SystemRoots)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(pins) == 0 {
+		return cfg, caReloader, nil
+	}
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		chains := cs.VerifiedChains
+		if len(chains) == 0 {
+			// 未校验证书链（TLS(cfg) 中 InsecureSkipVerify）时，握手只证明对端持有叶子证书的私钥，只能比对叶子证书
+			if len(cs.PeerCertificates) == 0 {
+				return ErrPinMismatch
+			}
+			chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if slices.ContainsFunc(pins, func(p []byte) bool { return bytes.Equal(p, sum[:]) }) {
+					return nil
+				}
+			}
+		}
+		return ErrPinMismatch
+	}
+	return cfg, caReloader, nil
+}
+
+// caReloadTransport 在 CA 证书包重新加载后克隆 Transport 并换上新的 RootCAs，
+// 旧 Transport 的空闲连接随即关闭，进行中的请求不受影响
+type caReloadTransport struct {
+	r *certReloader
+
+	mu  sync.Mutex
+	cur *http.Transport
+}
+
+func (t *caReloadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.r.maybeReload()
+	return t.current().RoundTrip(req)
+}
+
+// CloseIdleConnections 供 http.Client.CloseIdleConnections 调用
+func (t *caReloadTransport) CloseIdleConnections() {
+	t.current().CloseIdleConnections()
+}
+
+func (t *caReloadTransport) current() *http.Transport {
+	pool := t.r.roots()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur.TLSClientConfig.RootCAs != pool {
+		old := t.cur
+		t.cur = old.Clone()
+		t.cur.TLSClientConfig.RootCAs = pool
+		old.CloseIdleConnections()
+	}
+	return t.cur
+}
+
+// parsePins 解析 base64 编码的 SPKI SHA-256，允许 "sha256/" 前缀
+func parsePins(pins []string) ([][]byte, error) {
+	out := make([][]byte, 0, len(pins))
+	for _, p := range pins {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(p), "sha256/"))
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("tls: invalid SPKI pin %q", p)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// SPKIPin 计算证书的公钥固定值（"sha256/" + base64），用于填写 PinnedSPKI
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func knownCipherSuite(id uint16) bool {
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// caPool 由 PEM 证书包构建证书池，system 为 true 时追加到系统根证书
+func caPool(pem []byte, system bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if system {
+		if sys, err := x509.SystemCertPool(); err == nil {
+			pool = sys
+		}
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("tls: no valid certificates in CA bundle")
+	}
+	return pool, nil
+}
+
+// ─── 证书热加载 ────────────────────────────────────────
+
+// certReloader 在握手时按间隔检查证书文件的修改时间与大小，变化后重新加载；加载失败时保留旧证书
+type certReloader struct {
+	opts     *TLSOptions
+	interval time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	certVer string // 证书与私钥文件的修改时间 + 大小
+	caVer   string
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func (r *certReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+func (r *certReloader) roots() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pool
+}
+
+func (r *certReloader) loadCert() error {
+	ver, err := fileVersion(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load client certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load client certificate: %w", err)
+	}
+	r.mu.Lock()
+	r.cert, r.certVer = &cert, ver
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) loadCA() error {
+	ver, err := fileVersion(r.opts.CAFile)
+	if err != nil {
+		return fmt.Errorf("tls: load CA bundle: %w", err)
+	}
+	data, err := os.ReadFile(r.opts.CAFile)
+	if err != nil {
+		return fmt.Errorf("tls: load CA bundle: %w", err)
+	}
+	pool, err := caPool(data, r.opts.SystemRoots)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.pool, r.caVer = pool, ver
+	r.mu.Unlock()
+	return nil
+}
+
+// maybeReload 距上次检查超过 interval 时比较文件版本，变化则重新加载
+func (r *certReloader) maybeReload() {
+	if r.interval < 0 {
+		return
+	}
+	r.mu.Lock()
+	if time.Since(r.checked) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	certVer, caVer := r.certVer, r.caVer
+	r.mu.Unlock()
+
+	var errs []error
+	if r.opts.CertFile != "" {
+		if ver, err := fileVersion(r.opts.CertFile, r.opts.KeyFile); err != nil || ver != certVer {
+			errs = append(errs, r.loadCert())
+		}
+	}
+	if r.opts.CAFile != "" {
+		if ver, err := fileVersion(r.opts.CAFile); err != nil || ver != caVer {
+			errs = append(errs, r.loadCA())
+		}
+	}
+	if err := errors.Join(errs...); err != nil && r.opts.OnReloadError != nil {
+		r.opts.OnReloadError(err)
+	}
+}
+
+// fileVersion 以修改时间与大小标识文件内容版本
+func fileVersion(paths ...string) (string, error) {
+	var sb strings.Builder
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%d:%d;", fi.ModTime().UnixNano(), fi.Size())
+	}
+	return sb.String(), nil
+}
+
+// ─── 错误分类 ──────────────────────────────────────────
+
+// asTLSError 将握手、证书校验与公钥固定错误包装为 *TLSError，其余错误原样返回
+func asTLSError(host string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		tlsErr   *TLSError
+		verify   *tls.CertificateVerificationError
+		unknown  x509.UnknownAuthorityError
+		hostname x509.HostnameError
+		invalid  x509.CertificateInvalidError
+		record   tls.RecordHeaderError
+		alert    tls.AlertError
+	)
+	switch {
+	case errors.As(err, &tlsErr):
+		return err
+	case errors.Is(err, ErrPinMismatch), errors.As(err, &verify), errors.As(err, &unknown),
+		errors.As(err, &hostname), errors.As(err, &invalid):
+		return &TLSError{Host: host, Verification: true, Err: err}
+	case errors.As(err, &record), errors.As(err, &alert),
+		strings.Contains(err.Error(), "tls: "), strings.Contains(err.Error(), "TLS handshake"):
+		return &TLSError{Host: host, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,321 @@
+package k
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert 测试用证书与 PEM
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert 签发证书，parent 为 nil 时生成自签名 CA；服务端证书默认签给 127.0.0.1，hosts 可替换
+func newTestCert(t *testing.T, cn string, parent *testCert, server bool, hosts ...string) *testCert {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := tpl, key
+	switch {
+	case parent == nil:
+		tpl.IsCA, tpl.BasicConstraintsValid = true, true
+		tpl.KeyUsage = x509.KeyUsageCertSign
+	case server:
+		if len(hosts) == 0 {
+			hosts = []string{"127.0.0.1"}
+		}
+		for _, h := range hosts {
+			if ip := net.ParseIP(h); ip != nil {
+				tpl.IPAddresses = append(tpl.IPAddresses, ip)
+			} else {
+				tpl.DNSNames = append(tpl.DNSNames, h)
+			}
+		}
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		signer, signerKey = parent.cert, parent.key
+	default:
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newMTLSServer 启动要求客户端证书的 HTTPS 服务，响应客户端证书的 CN
+func newMTLSServer(t *testing.T, ca, server *testCert) *httptest.Server {
+	t.Helper()
+	srvCert, _ := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close") // 每个请求重新握手
+		if len(r.TLS.PeerCertificates) > 0 {
+			io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{srvCert}, ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	return srv
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试从文件加载 mTLS 证书与 CA，并在文件变化后热加载
+func TestTLSOptions_MTLSFilesAndReload(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	srv := newMTLSServer(t, ca, newTestCert(t, "server", ca, true))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "c.crt"), filepath.Join(dir, "c.key"), filepath.Join(dir, "ca.pem")
+	alice := newTestCert(t, "alice", ca, false)
+	writeFile(t, certFile, alice.certPEM)
+	writeFile(t, keyFile, alice.keyPEM)
+	writeFile(t, caFile, ca.certPEM)
+
+	client, err := NewClient(srv.URL).TLSOptions(&TLSOptions{
+		CertFile: certFile, KeyFile: keyFile, CAFile: caFile,
+		ReloadInterval: time.Millisecond,
+	}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() string {
+		t.Helper()
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := client.ReadBodyString(resp)
+		return s
+	}
+	if cn := get(); cn != "alice" {
+		t.Fatalf("cn = %q", cn)
+	}
+
+	bob := newTestCert(t, "bob", ca, false)
+	writeFile(t, certFile, bob.certPEM)
+	writeFile(t, keyFile, bob.keyPEM)
+	time.Sleep(5 * time.Millisecond)
+	if cn := get(); cn != "bob" {
+		t.Errorf("after reload cn = %q", cn)
+	}
+
+	if _, err := NewClient(srv.URL).TLSOptions(&TLSOptions{CertFile: filepath.Join(dir, "missing"), KeyFile: keyFile}).Build(); err == nil {
+		t.Error("expected Build error for missing certificate")
+	}
+}
+
+// 测试 CAFile 按请求主机名校验证书，并在 CA 文件变化后换用新的证书池
+func TestTLSOptions_CAFileHostnameAndReload(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.certPEM)
+
+	// 证书由受信 CA 签发，但只签给 other.example，不含 127.0.0.1
+	wrongHost := newMTLSServer(t, ca, newTestCert(t, "server", ca, true, "other.example"))
+	defer wrongHost.Close()
+	client, err := NewClient(wrongHost.URL).TLSOptions(&TLSOptions{CAFile: caFile}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Get("/")
+	var tlsErr *TLSError
+	if !errors.As(err, &tlsErr) || !tlsErr.Verification {
+		t.Fatalf("hostname mismatch err = %v", err)
+	}
+
+	// CA 文件先写入无关 CA，校验失败；换成正确 CA 后下一次请求生效
+	srv := newMTLSServer(t, ca, newTestCert(t, "server", ca, true))
+	defer srv.Close()
+	other := newTestCert(t, "other-ca", nil, false)
+	writeFile(t, caFile, other.certPEM)
+	client, _ = NewClient(srv.URL).TLSOptions(&TLSOptions{CAFile: caFile, ReloadInterval: time.Millisecond}).Build()
+	if _, err := client.Get("/"); !errors.As(err, &tlsErr) {
+		t.Fatalf("unknown CA err = %v", err)
+	}
+	writeFile(t, caFile, append(ca.certPEM, '\n'))
+	time.Sleep(5 * time.Millisecond)
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatalf("after CA reload: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// 测试 CA 不匹配时返回不可重试的 *TLSError
+func TestTLSOptions_UnknownCA(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	srv := newMTLSServer(t, ca, newTestCert(t, "server", ca, true))
+	defer srv.Close()
+	other := newTestCert(t, "other-ca", nil, false)
+
+	mc := NewMetricsCollector()
+	client, _ := NewClient(srv.URL).
+		TLSOptions(&TLSOptions{CAPEM: other.certPEM}).
+		Retry(WithMaxRetries(3), WithRetryDelay(time.Millisecond)).
+		MetricsCollector(mc).
+		Build()
+	_, err := client.Get("/")
+	var tlsErr *TLSError
+	if !errors.As(err, &tlsErr) || !tlsErr.Verification || !IsNonRetryable(err) {
+		t.Fatalf("err = %v", err)
+	}
+	var buf strings.Builder
+	mc.WritePrometheus(&buf)
+	if !strings.Contains(buf.String(), `code="tls_error"} 1`) {
+		t.Errorf("metrics = %s", buf.String())
+	}
+}
+
+// 测试公钥固定
+func TestTLSOptions_PinnedSPKI(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	server := newTestCert(t, "server", ca, true)
+	srv := newMTLSServer(t, ca, server)
+	defer srv.Close()
+
+	carol := newTestCert(t, "carol", ca, false)
+	if _, err := NewClient(srv.URL).TLSOptions(&TLSOptions{CertPEM: carol.certPEM}).Build(); err == nil {
+		t.Error("expected error: CertPEM without KeyPEM")
+	}
+
+	// 第一个固定值不匹配，第二个匹配服务端证书
+	client, err := NewClient(srv.URL).TLSOptions(&TLSOptions{
+		CertPEM: carol.certPEM, KeyPEM: carol.keyPEM, CAPEM: ca.certPEM,
+		PinnedSPKI: []string{SPKIPin(newTestCert(t, "x", ca, true).cert), SPKIPin(server.cert)},
+	}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn, _ := client.ReadBodyString(resp); cn != "carol" {
+		t.Errorf("cn = %q", cn)
+	}
+
+	// 固定 CA 公钥同样通过；固定无关公钥失败
+	client, _ = NewClient(srv.URL).TLSOptions(&TLSOptions{CAPEM: ca.certPEM, PinnedSPKI: []string{SPKIPin(ca.cert)}}).Build()
+	if resp, err := client.Get("/"); err != nil {
+		t.Errorf("ca pin: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	client, _ = NewClient(srv.URL).TLSOptions(&TLSOptions{CAPEM: ca.certPEM, PinnedSPKI: []string{SPKIPin(carol.cert)}}).Build()
+	if _, err := client.Get("/"); !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("pin mismatch err = %v", err)
+	}
+
+	if _, err := NewClient(srv.URL).TLSOptions(&TLSOptions{PinnedSPKI: []string{"sha256/abc"}}).Build(); err == nil {
+		t.Error("expected error for malformed pin")
+	}
+}
+
+// 测试关闭证书校验时公钥固定只比对叶子证书：在链中附带被固定的证书无法通过
+func TestTLSOptions_PinnedSPKIUnverifiedChain(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	pinned := newTestCert(t, "server", ca, true)
+	attacker := newTestCert(t, "attacker", newTestCert(t, "evil-ca", nil, false), true)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{attacker.cert.Raw, pinned.cert.Raw},
+		PrivateKey:  attacker.key,
+	}}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	build := func(pin *testCert) *HTTPClient {
+		client, err := NewClient(srv.URL).
+			TLS(&tls.Config{InsecureSkipVerify: true}).
+			TLSOptions(&TLSOptions{PinnedSPKI: []string{SPKIPin(pin.cert)}}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	if _, err := build(pinned).Get("/"); !errors.Is(err, ErrPinMismatch) {
+		t.Fatalf("pin on non-leaf err = %v", err)
+	}
+	resp, err := build(attacker).Get("/")
+	if err != nil {
+		t.Fatalf("leaf pin: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// 测试 TLS 版本与密码套件
+func TestTLSOptions_VersionPolicy(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, false)
+	srv := newMTLSServer(t, ca, newTestCert(t, "server", ca, true))
+	srv.TLS.MaxVersion = tls.VersionTLS12
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL).TLSOptions(&TLSOptions{CAPEM: ca.certPEM, MinVersion: tls.VersionTLS13}).Build()
+	_, err := client.Get("/")
+	var tlsErr *TLSError
+	if !errors.As(err, &tlsErr) || tlsErr.Verification {
+		t.Fatalf("err = %v", err)
+	}
+
+	client, _ = NewClient(srv.URL).TLSOptions(&TLSOptions{
+		CAPEM:        ca.certPEM,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}).Build()
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS.CipherSuite != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("cipher = %s", tls.CipherSuiteName(resp.TLS.CipherSuite))
+	}
+	resp.Body.Close()
+
+	if _, err := NewClient(srv.URL).TLSOptions(&TLSOptions{CipherSuites: []uint16{0xffff}}).Build(); err == nil {
+		t.Error("expected error for unknown cipher suite")
+	}
+}
@@ -0,0 +1,267 @@
+package k
+
+// http_upload.go —— multipart/form-data 流式上传
+//
+// 设计目标：
+//   - 请求体通过 io.Pipe 边读边写，File.Path 指向的大文件不会整体读入内存
+//   - 通过 http.Request.GetBody 实现重放，重试时重新打开文件而不是缓存字节
+//   - 文件大小可知时预先计算 Content-Length，避免 chunked 编码不被部分服务端接受
+//   - 支持单请求多文件与上传进度回调
+//
+// 示例：
+//
+//	resp, err := client.UploadFiles("/upload", []*File{
+//	    {FieldName: "a", FileName: "a.csv", Path: "/data/a.csv"},
+//	    {FieldName: "b", FileName: "b.csv", Path: "/data/b.csv"},
+//	}, url.Values{"desc": {"daily export"}}, R().UploadProgress(func(sent, total int64) {
+//	    fmt.Printf("%d/%d\n", sent, total)
+//	}))
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+)
+
+// File 描述一个待上传的文件，由 Content 或 Path 二选一提供内容来源。
+// Content 非 nil 时优先使用，Path 用于从磁盘流式读取大文件（每次重试都会重新打开）。
+type File struct {
+	FieldName string // multipart 表单字段名，例如 "avatar"
+	FileName  string // 文件名，例如 "photo.png"（显示在服务端）
+	Content   []byte // 直接提供文件字节内容（优先于 Path）
+	Path      string // 从磁盘读取的文件路径，例如 "/tmp/upload.csv"
+}
+
+// size 返回文件内容长度，无法确定时返回 -1
+func (f *File) size() int64 {
+	if f.Content != nil {
+		return int64(len(f.Content))
+	}
+	if f.Path == "" {
+		return 0
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// open 打开文件内容来源，调用方负责关闭
+func (f *File) open() (io.ReadCloser, error) {
+	switch {
+	case f.Content != nil:
+		return io.NopCloser(bytes.NewReader(f.Content)), nil
+	case f.Path != "":
+		return os.Open(f.Path)
+	}
+	return http.NoBody, nil
+}
+
+// ProgressFunc 传输进度回调。
+//
+// 参数：
+//   - transferred: 已传输字节数
+//   - total:       总字节数，未知时为 -1
+type ProgressFunc func(transferred, total int64)
+
+// UploadProgress 设置上传进度回调，仅对 multipart 文件上传生效。
+// 回调在写入请求体的 goroutine 中同步执行，不应阻塞；重试时进度从 0 重新开始。
+//
+// 参数：
+//   - fn: 进度回调，total 为整个 multipart 请求体的长度（含表单字段与分隔符），未知时为 -1。
+func (r *RequestBuilder) UploadProgress(fn ProgressFunc) *RequestBuilder {
+	r.cfg.uploadProgress = fn
+	return r
+}
+
+// UploadFiles 以 multipart/form-data 格式在一个请求中上传多个文件。
+//
+// 参数：
+//   - path:        上传接口路径
+//   - files:       待上传文件列表，字段名可以重复
+//   - extraFields: 附带的表单字段，无额外字段时传 nil
+//   - rb:          可选请求配置
+func (c *HTTPClient) UploadFiles(path string, files []*File, extraFields url.Values, rb ...*RequestBuilder) (*http.Response, error) {
+	r := first(rb)
+	if r == nil {
+		r = R()
+	}
+	r.FormData(extraFields).Files(files...)
+	return c.do(http.MethodPost, path, nil, "", r)
+}
+
+// multipartBody 可重复生成的流式 multipart 请求体
+type multipartBody struct {
+	fields   url.Values
+	files    []*File
+	boundary string
+	progress ProgressFunc
+	length   int64 // 请求体总长度，未知时为 -1
+}
+
+func newMultipartBody(fields url.Values, files []*File, progress ProgressFunc) *multipartBody {
+	m := &multipartBody{
+		fields:   fields,
+		files:    files,
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		progress: progress,
+	}
+	m.length = m.computeLength()
+	return m
+}
+
+// contentType 返回带 boundary 的 Content-Type
+func (m *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// computeLength 以不写文件内容的方式"预演"一遍编码，加上各文件大小得出总长度
+func (m *multipartBody) computeLength() int64 {
+	var total int64
+	for _, f := range m.files {
+		n := f.size()
+		if n < 0 {
+			return -1
+		}
+		total += n
+	}
+	cw := &countingWriter{}
+	if err := m.write(cw, false); err != nil {
+		return -1
+	}
+	return total + cw.n
+}
+
+// write 按固定顺序写出表单字段与文件，withContent 为 false 时只写结构不写文件内容
+func (m *multipartBody) write(w io.Writer, withContent bool) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m.fields))
+	for k := range m.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range m.fields[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range m.files {
+		part, err := mw.CreateFormFile(f.FieldName, f.FileName)
+		if err != nil {
+			return err
+		}
+		if !withContent {
+			continue
+		}
+		src, err := f.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// open 返回一个新的流式请求体，可作为 http.Request.GetBody 反复调用。
+// 首次 Read 时才启动后台 goroutine 将 multipart 内容写入管道，请求在发送前失败（鉴权、钩子、熔断等）
+// 时不会打开文件；读端被关闭（请求结束或失败）时写端随之退出。
+func (m *multipartBody) open() (io.ReadCloser, error) {
+	body := &multipartReader{m: m}
+	if m.progress == nil {
+		return body, nil
+	}
+	return &progressReader{ReadCloser: body, total: m.length, fn: m.progress}, nil
+}
+
+// multipartReader 延迟启动写入 goroutine 的管道读端
+type multipartReader struct {
+	m      *multipartBody
+	mu     sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if r.pr == nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(r.m.write(pw, true))
+		}()
+		r.pr = pr
+	}
+	pr := r.pr
+	r.mu.Unlock()
+	return pr.Read(p)
+}
+
+// Close 关闭读端；尚未开始读取时不会启动写入 goroutine
+func (r *multipartReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.pr != nil {
+		return r.pr.Close()
+	}
+	return nil
+}
+
+// attachFiles 将表单字段与文件以流式 multipart 写入请求，并设置 GetBody 供重试重放
+func attachFiles(req *http.Request, values url.Values, files []*File, progress ProgressFunc) error {
+	mb := newMultipartBody(values, files, progress)
+	body, err := mb.open()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	req.GetBody = mb.open
+	req.ContentLength = mb.length
+	req.Header.Set("Content-Type", mb.contentType())
+	return nil
+}
+
+// countingWriter 只统计写入字节数的 io.Writer
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// progressReader 在读取时累计字节数并触发进度回调
+type progressReader struct {
+	io.ReadCloser
+	read  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.fn(r.read, r.total)
+	}
+	return n, err
+}
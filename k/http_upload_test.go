@@ -0,0 +1,95 @@
+package k
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试多文件流式上传、Content-Length 预计算与重试时重新打开文件
+func TestClient_UploadFiles(t *testing.T) {
+	dir := t.TempDir()
+	bigPath := filepath.Join(dir, "big.csv")
+	if err := os.WriteFile(bigPath, []byte(strings.Repeat("a,b,c\n", 10000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.ContentLength <= 0 {
+			t.Errorf("expected known content length, got %d", r.ContentLength)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart: %v", err)
+			return
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if got := r.FormValue("desc"); got != "export" {
+			t.Errorf("desc=%q", got)
+		}
+		if n := len(r.MultipartForm.File["file"]); n != 2 {
+			t.Errorf("expected 2 files, got %d", n)
+		}
+		f, _ := r.MultipartForm.File["file"][0].Open()
+		b, _ := io.ReadAll(f)
+		if len(b) != 60000 {
+			t.Errorf("big file size=%d", len(b))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL).
+		Retry(WithMaxRetries(2), WithRetryDelay(time.Millisecond)).
+		Build()
+
+	var lastSent, lastTotal int64
+	resp, err := client.UploadFiles("/upload", []*File{
+		{FieldName: "file", FileName: "big.csv", Path: bigPath},
+		{FieldName: "file", FileName: "small.txt", Content: []byte("hello")},
+	}, url.Values{"desc": {"export"}}, R().ExpectStatus(http.StatusCreated).UploadProgress(func(sent, total int64) {
+		lastSent, lastTotal = sent, total
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+	if lastTotal <= 0 || lastSent != lastTotal {
+		t.Errorf("progress sent=%d total=%d", lastSent, lastTotal)
+	}
+}
+
+// 请求在发送前失败时不应启动写入 goroutine（否则永远阻塞在管道写入上）
+func TestClient_UploadFilesEarlyReturn(t *testing.T) {
+	denied := errors.New("denied")
+	client, _ := NewClient("http://127.0.0.1:1").
+		OnRequest(func(req *http.Request) error { return denied }).
+		Build()
+	for range 3 {
+		_, err := client.UploadFiles("/upload", []*File{{FieldName: "file", FileName: "a.txt", Content: []byte("hello")}}, nil)
+		if !errors.Is(err, denied) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	buf := make([]byte, 1<<20)
+	if stacks := string(buf[:runtime.Stack(buf, true)]); strings.Contains(stacks, "multipartBody).write") {
+		t.Fatalf("multipart writer goroutine leaked:\n%s", stacks)
+	}
+}
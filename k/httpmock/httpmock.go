@@ -0,0 +1,417 @@
+// Package httpmock 为 k.HTTPClient 的使用方提供无需启动 httptest.Server 的测试替身。
+//
+//   - Transport：可编程的 http.RoundTripper，按方法、路径、query、header、body 匹配请求，
+//     支持按顺序返回多个响应、限制匹配次数以及调用断言
+//   - Recorder：录制真实请求 / 响应到 golden 文件，之后的测试离线回放
+//
+// 两者都通过 ClientBuilder.Transport 注入：
+//
+//	mock := httpmock.New()
+//	mock.On(http.MethodGet, "/users/42").ReplyJSON(200, map[string]any{"id": 42})
+//	client, _ := k.NewClient("https://api.example.com").Transport(mock).Build()
+//	...
+//	mock.AssertExpectations(t)
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrNoMatch 请求没有匹配到任何路由（或 golden 文件中没有对应记录）时返回的错误
+var ErrNoMatch = errors.New("httpmock: no matching route")
+
+// TB testing.TB 的最小子集，便于在非测试代码中声明断言方法
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Call 一次被 Transport 接收的请求
+type Call struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	Route  *Route // 匹配到的路由，未匹配时为 nil
+}
+
+// Transport 可编程的 mock http.RoundTripper，并发安全。
+// 路由按注册顺序匹配，第一条满足全部条件且未用尽次数的路由生效。
+type Transport struct {
+	mu     sync.Mutex
+	routes []*Route
+	calls  []*Call
+}
+
+// New 创建一个空的 mock Transport
+func New() *Transport {
+	return &Transport{}
+}
+
+// On 注册一条路由。
+//
+// 参数：
+//   - method: HTTP 方法，传 "" 或 "*" 匹配任意方法
+//   - path:   URL path，精确匹配；以 "*" 结尾时按前缀匹配，例如 "/users/*"
+func (t *Transport) On(method, path string) *Route {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := &Route{t: t, method: strings.ToUpper(method), path: path}
+	t.routes = append(t.routes, r)
+	return r
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	call := &Call{Method: req.Method, URL: req.URL, Header: req.Header.Clone(), Body: body}
+	t.calls = append(t.calls, call)
+	var matched *Route
+	for _, r := range t.routes {
+		if r.matches(req, body) {
+			matched = r
+			break
+		}
+	}
+	var res responder
+	if matched != nil {
+		call.Route = matched
+		res = matched.next()
+	}
+	t.mu.Unlock()
+
+	if matched == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoMatch, req.Method, req.URL)
+	}
+	if res == nil {
+		return newResponse(req, http.StatusOK, nil, nil), nil
+	}
+	return res(req)
+}
+
+// Calls 返回已接收请求的快照（含未匹配的请求）
+func (t *Transport) Calls() []*Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Call(nil), t.calls...)
+}
+
+// CallCount 返回指定方法与路径（精确匹配）被请求的次数，method 为 "" 时不区分方法
+func (t *Transport) CallCount(method, path string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, c := range t.calls {
+		if (method == "" || strings.EqualFold(c.Method, method)) && c.URL.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// AssertExpectations 断言：设置了 Times 的路由恰好被调用 n 次，其余路由至少被调用一次，
+// 且不存在未匹配的请求。返回是否全部满足。
+func (t *Transport) AssertExpectations(tb TB) bool {
+	tb.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ok := true
+	for _, r := range t.routes {
+		switch {
+		case r.times > 0 && r.calls != r.times:
+			tb.Errorf("httpmock: %s expected %d call(s), got %d", r, r.times, r.calls)
+			ok = false
+		case r.times == 0 && r.calls == 0:
+			tb.Errorf("httpmock: %s was never called", r)
+			ok = false
+		}
+	}
+	for _, c := range t.calls {
+		if c.Route == nil {
+			tb.Errorf("httpmock: unexpected request %s %s", c.Method, c.URL)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Reset 清空全部路由与调用记录
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes, t.calls = nil, nil
+}
+
+// ═══════════════════════════════════════════════════════
+// Route
+// ═══════════════════════════════════════════════════════
+
+// responder 根据请求生成一个响应
+type responder func(*http.Request) (*http.Response, error)
+
+// Route 一条 mock 路由：匹配条件 + 响应序列。
+// 通过 Transport.On 创建后链式调用，Reply 系列方法可多次调用形成响应序列：
+// 第 n 次匹配返回第 n 个响应，序列用尽后重复最后一个。
+type Route struct {
+	t        *Transport
+	method   string
+	path     string
+	query    url.Values
+	headers  http.Header
+	body     []byte
+	hasBody  bool
+	jsonBody any
+	hasJSON  bool
+	matchers []func(*http.Request, []byte) bool
+
+	responses []responder
+	times     int // 最多匹配次数，0 表示不限
+	calls     int
+}
+
+// String 返回路由的可读描述，用于断言信息
+func (r *Route) String() string {
+	m := r.method
+	if m == "" {
+		m = "*"
+	}
+	s := m + " " + r.path
+	if len(r.query) > 0 {
+		s += "?" + r.query.Encode()
+	}
+	return s
+}
+
+// WithQuery 要求请求 query 中 key 包含 value，可多次调用（子集匹配，请求可以有额外参数）
+func (r *Route) WithQuery(key, value string) *Route {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// WithHeader 要求请求头 key 的值等于 value
+func (r *Route) WithHeader(key, value string) *Route {
+	if r.headers == nil {
+		r.headers = http.Header{}
+	}
+	r.headers.Add(key, value)
+	return r
+}
+
+// WithBody 要求请求体与 body 完全一致
+func (r *Route) WithBody(body string) *Route {
+	r.body, r.hasBody = []byte(body), true
+	return r
+}
+
+// WithJSON 要求请求体是与 v 语义相等的 JSON（忽略字段顺序与空白）
+func (r *Route) WithJSON(v any) *Route {
+	r.jsonBody, r.hasJSON = normalizeJSON(v), true
+	return r
+}
+
+// WithBodyContains 要求请求体包含子串 s
+func (r *Route) WithBodyContains(s string) *Route {
+	return r.Match(func(_ *http.Request, body []byte) bool {
+		return bytes.Contains(body, []byte(s))
+	})
+}
+
+// Match 添加自定义匹配条件，body 为已读取的请求体
+func (r *Route) Match(fn func(req *http.Request, body []byte) bool) *Route {
+	r.matchers = append(r.matchers, fn)
+	return r
+}
+
+// Times 限制路由最多匹配 n 次，用尽后请求会继续尝试后续路由；
+// 同时作为 AssertExpectations 的期望调用次数
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 等价于 Times(1)
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Reply 追加一个文本响应
+func (r *Route) Reply(status int, body string) *Route {
+	return r.ReplyWithHeader(status, nil, []byte(body))
+}
+
+// ReplyJSON 追加一个 JSON 响应，v 会被序列化，Content-Type 为 application/json
+func (r *Route) ReplyJSON(status int, v any) *Route {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return r.ReplyError(err)
+	}
+	return r.ReplyWithHeader(status, http.Header{"Content-Type": {"application/json"}}, b)
+}
+
+// ReplyWithHeader 追加一个带自定义响应头的响应
+func (r *Route) ReplyWithHeader(status int, header http.Header, body []byte) *Route {
+	r.responses = append(r.responses, func(req *http.Request) (*http.Response, error) {
+		return newResponse(req, status, header, body), nil
+	})
+	return r
+}
+
+// ReplyError 追加一个传输层错误（模拟连接失败、超时等）
+func (r *Route) ReplyError(err error) *Route {
+	r.responses = append(r.responses, func(*http.Request) (*http.Response, error) {
+		return nil, err
+	})
+	return r
+}
+
+// ReplyFunc 追加一个动态响应
+func (r *Route) ReplyFunc(fn func(*http.Request) (*http.Response, error)) *Route {
+	r.responses = append(r.responses, fn)
+	return r
+}
+
+// Calls 返回路由已匹配的次数
+func (r *Route) Calls() int {
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+	return r.calls
+}
+
+// matches 判断请求是否满足全部条件，调用方持有 Transport 锁
+func (r *Route) matches(req *http.Request, body []byte) bool {
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != "*" && r.method != req.Method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.path, "*"); ok {
+		if !strings.HasPrefix(req.URL.Path, prefix) {
+			return false
+		}
+	} else if r.path != req.URL.Path {
+		return false
+	}
+	if len(r.query) > 0 {
+		q := req.URL.Query()
+		for k, want := range r.query {
+			for _, v := range want {
+				if !contains(q[k], v) {
+					return false
+				}
+			}
+		}
+	}
+	for k, want := range r.headers {
+		for _, v := range want {
+			if !contains(req.Header.Values(k), v) {
+				return false
+			}
+		}
+	}
+	if r.hasBody && !bytes.Equal(r.body, body) {
+		return false
+	}
+	if r.hasJSON {
+		var got any
+		if json.Unmarshal(body, &got) != nil || !reflect.DeepEqual(r.jsonBody, got) {
+			return false
+		}
+	}
+	for _, fn := range r.matchers {
+		if !fn(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// next 计数并返回本次应使用的响应，调用方持有 Transport 锁
+func (r *Route) next() responder {
+	r.calls++
+	if len(r.responses) == 0 {
+		return nil
+	}
+	i := r.calls - 1
+	if i >= len(r.responses) {
+		i = len(r.responses) - 1
+	}
+	return r.responses[i]
+}
+
+// ═══════════════════════════════════════════════════════
+// 工具函数
+// ═══════════════════════════════════════════════════════
+
+// readBody 读取并关闭请求体（RoundTripper 负责关闭请求体）
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// newResponse 构造一个完整的 *http.Response
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	h := header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// normalizeJSON 将任意值经过一次 JSON 编解码，得到可与请求体解码结果 DeepEqual 比较的形式
+func normalizeJSON(v any) any {
+	var b []byte
+	switch x := v.(type) {
+	case string:
+		b = []byte(x)
+	case []byte:
+		b = x
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	}
+	var out any
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
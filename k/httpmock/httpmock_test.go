@@ -0,0 +1,160 @@
+package httpmock_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kuangshp/go-utils/k"
+	"github.com/kuangshp/go-utils/k/httpmock"
+)
+
+// fakeTB 收集断言失败信息
+type fakeTB struct{ errs []string }
+
+func (f *fakeTB) Helper() {}
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errs = append(f.errs, fmt.Sprintf(format, args...))
+}
+
+// 测试按方法、路径、query、JSON body 匹配
+func TestTransport_Match(t *testing.T) {
+	mock := httpmock.New()
+	mock.On(http.MethodGet, "/users").WithQuery("page", "2").ReplyJSON(200, []int{3, 4})
+	mock.On(http.MethodGet, "/users").ReplyJSON(200, []int{1, 2})
+	mock.On(http.MethodPost, "/users").WithJSON(map[string]any{"name": "tom", "age": 18}).Reply(201, "created")
+	mock.On("*", "/files/*").Reply(200, "file")
+
+	client, _ := k.NewClient("https://api.example.com").Transport(mock).Build()
+
+	var page []int
+	resp, err := client.Get("/users", k.R().QueryParams(map[string]string{"page": "2", "size": "10"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.ReadJSON(resp, &page); err != nil || len(page) != 2 || page[0] != 3 {
+		t.Fatalf("query route not matched: %v %v", page, err)
+	}
+
+	resp, err = client.PostJSON("/users", map[string]any{"age": 18, "name": "tom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := client.ReadBodyString(resp); resp.StatusCode != 201 || body != "created" {
+		t.Fatalf("json route not matched: %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = client.Delete("/files/a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if _, err = client.Get("/orders"); !errors.Is(err, httpmock.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+	if n := mock.CallCount(http.MethodGet, "/users"); n != 1 {
+		t.Errorf("CallCount = %d", n)
+	}
+
+	tb := &fakeTB{}
+	if mock.AssertExpectations(tb) {
+		t.Fatal("expected assertion failure")
+	}
+	if len(tb.errs) != 2 || !strings.Contains(tb.errs[0], "never called") || !strings.Contains(tb.errs[1], "/orders") {
+		t.Errorf("unexpected assertion messages: %v", tb.errs)
+	}
+}
+
+// 测试响应序列与 Times，配合客户端重试
+func TestTransport_Sequence(t *testing.T) {
+	mock := httpmock.New()
+	route := mock.On(http.MethodGet, "/flaky").
+		ReplyError(errors.New("connection reset")).
+		Reply(503, "busy").
+		Reply(200, "ok").
+		Times(3)
+
+	client, _ := k.NewClient("https://api.example.com").
+		Transport(mock).
+		Retry(k.WithMaxRetries(3), k.WithRetryDelay(0)).
+		Build()
+
+	resp, err := client.Get("/flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := client.ReadBodyString(resp); body != "ok" {
+		t.Fatalf("body = %q", body)
+	}
+	if route.Calls() != 3 {
+		t.Errorf("calls = %d", route.Calls())
+	}
+	mock.AssertExpectations(t)
+
+	// Times 用尽后不再匹配
+	plain, _ := k.NewClient("https://api.example.com").Transport(mock).Build()
+	if _, err = plain.Get("/flaky"); !errors.Is(err, httpmock.ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch after Times exhausted, got %v", err)
+	}
+}
+
+// 测试录制后离线回放
+func TestRecorder_RecordReplay(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		w.Header().Set("X-Hit", fmt.Sprint(n))
+		fmt.Fprintf(w, "%s %s #%d", r.Method, r.URL.Path, n)
+	}))
+	golden := filepath.Join(t.TempDir(), "testdata", "golden.json")
+
+	rec, err := httpmock.NewRecorder(golden, httpmock.ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Recording() {
+		t.Fatal("expected recording mode when golden file is missing")
+	}
+	rec.Filter = func(it *httpmock.Interaction) { it.Response.Header.Del("Date") }
+	client, _ := k.NewClient(srv.URL).Transport(rec).Build()
+	for _, p := range []string{"/a", "/a", "/b"} {
+		resp, err := client.Get(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	srv.Close()
+
+	rec, err = httpmock.NewRecorder(golden, httpmock.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ = k.NewClient(srv.URL).Transport(rec).Build()
+	want := []string{"GET /a #1", "GET /a #2", "GET /b #3", "GET /a #2"}
+	for i, p := range []string{"/a", "/a", "/b", "/a"} {
+		resp, err := client.Get(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := client.ReadBodyString(resp); body != want[i] {
+			t.Errorf("replay %d: got %q, want %q", i, body, want[i])
+		}
+		if resp.Header.Get("Date") != "" {
+			t.Error("Filter should have removed Date header")
+		}
+	}
+	if hits.Load() != 3 {
+		t.Errorf("server hits = %d", hits.Load())
+	}
+
+	if _, err = client.Get("/c"); !errors.Is(err, httpmock.ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+}
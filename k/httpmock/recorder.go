@@ -0,0 +1,227 @@
+package httpmock
+
+// recorder.go —— 录制 / 回放
+//
+// 首次运行（或设置环境变量 HTTPMOCK_RECORD=1）时请求真实服务并把每次交互写入 golden 文件，
+// 之后的测试直接从文件回放，无需网络。golden 文件为缩进 JSON，便于代码评审时查看差异。
+//
+// 示例：
+//
+//	rec, err := httpmock.NewRecorder("testdata/users.json", httpmock.ModeAuto, nil)
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	client, _ := k.NewClient("https://api.example.com").Transport(rec).Build()
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode 录制器工作模式
+type Mode int
+
+const (
+	ModeAuto   Mode = iota // golden 文件存在时回放，否则录制；HTTPMOCK_RECORD=1 时强制录制
+	ModeReplay             // 只回放，文件不存在时 NewRecorder 返回错误
+	ModeRecord             // 总是请求真实服务并覆盖 golden 文件
+)
+
+// Interaction golden 文件中的一次请求 / 响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 录制的请求，回放时按 Method + URL + Body 匹配
+type RecordedRequest struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"body_encoding,omitempty"` // 非 UTF-8 内容为 "base64"
+}
+
+// RecordedResponse 录制的响应
+type RecordedResponse struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"` // 非 UTF-8 内容为 "base64"
+}
+
+// Recorder 录制 / 回放模式的 http.RoundTripper，并发安全。
+type Recorder struct {
+	// Filter 录制时在写入文件前调用，可用于脱敏（删除 Set-Cookie、替换 token 等）
+	Filter func(*Interaction)
+
+	path      string
+	recording bool
+	real      http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewRecorder 创建录制器。
+//
+// 参数：
+//   - path: golden 文件路径，例如 "testdata/users.json"，目录不存在时录制会自动创建
+//   - mode: 工作模式
+//   - real: 录制时使用的真实传输层，为 nil 时使用 http.DefaultTransport
+func NewRecorder(path string, mode Mode, real http.RoundTripper) (*Recorder, error) {
+	if real == nil {
+		real = http.DefaultTransport
+	}
+	r := &Recorder{path: path, real: real}
+
+	switch mode {
+	case ModeRecord:
+		r.recording = true
+		return r, nil
+	case ModeAuto:
+		if os.Getenv("HTTPMOCK_RECORD") == "1" {
+			r.recording = true
+			return r, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && mode == ModeAuto {
+		r.recording = true
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &r.interactions); err != nil {
+		return nil, fmt.Errorf("httpmock: invalid golden file %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+// Recording 返回是否处于录制状态
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.recording {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+// replay 按 Method + URL + Body 查找首个未使用的记录；同一请求的记录用尽后重复最后一条
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	method, rawURL := req.Method, req.URL.String()
+	reqBody, reqEnc := encodeBody(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := -1
+	for i, it := range r.interactions {
+		q := it.Request
+		if q.Method != method || q.URL != rawURL || q.Body != reqBody || q.BodyEncoding != reqEnc {
+			continue
+		}
+		last = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("%w: %s %s not found in %s", ErrNoMatch, method, rawURL, r.path)
+	}
+	r.used[last] = true
+
+	res := r.interactions[last].Response
+	respBody, err := decodeBody(res.Body, res.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(req, res.Status, res.Header, respBody), nil
+}
+
+// record 发送真实请求，把交互追加到 golden 文件
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	resp, err := r.real.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	resp.ContentLength = int64(len(respBody))
+
+	it := &Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String()},
+		Response: RecordedResponse{Status: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+	it.Request.Body, it.Request.BodyEncoding = encodeBody(body)
+	it.Response.Body, it.Response.BodyEncoding = encodeBody(respBody)
+	if r.Filter != nil {
+		r.Filter(it)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, it)
+	r.used = append(r.used, true)
+	if err = r.saveLocked(); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// saveLocked 将全部交互写入 golden 文件，调用方持有锁
+func (r *Recorder) saveLocked() error {
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// encodeBody UTF-8 内容原样保存，其余以 base64 保存
+func encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func decodeBody(s, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}